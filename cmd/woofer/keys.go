@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/lib/tokens"
+)
+
+// runRotateKeys runs the 'rotate-keys' subcommand.
+func runRotateKeys() error {
+	if *keysetPath == "" {
+		return errors.New("-keyset should be set to rotate keys")
+	}
+	err := tokens.RotateKeyset(*keysetPath, *keysetKeep)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Added a new signing key to %v; send SIGHUP to running servers to use it", *keysetPath)
+	return nil
+}
+
+// newIssuer creates a token issuer if tokens are enabled by the flags,
+// returning nil otherwise.
+// The keyset is reloaded on SIGHUP.
+func newIssuer() (*tokens.Issuer, error) {
+	if *keysetPath == "" {
+		return nil, nil
+	}
+	keys, err := tokens.LoadKeyset(*keysetPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "keyset init failed")
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			err := keys.Reload()
+			if err != nil {
+				logrus.Error(err)
+				continue
+			}
+			logrus.Info("Reloaded signing keys")
+		}
+	}()
+
+	return tokens.NewIssuer(keys, *accessTTL, *sessionTTL, *sessionMaxAge), nil
+}
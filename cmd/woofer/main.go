@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"net/http"
 	"time"
 
@@ -9,60 +10,241 @@ import (
 	"github.com/Sirupsen/logrus"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/pkg/errors"
 	"github.com/utrack/woofer/interface/ihttp"
+	"github.com/utrack/woofer/lib/migrator"
+	"github.com/utrack/woofer/lib/session"
 	"github.com/utrack/woofer/lib/session/inmemsessions"
+	"github.com/utrack/woofer/lib/session/redissessions"
+	"github.com/utrack/woofer/lib/session/sqlitesessions"
 	"github.com/utrack/woofer/service"
 )
 
 var (
 	listenPort   = flag.String("listen", ":3333", "HTTP address to listen on")
-	migrations   = flag.String("migrations", "../../migrations", "Path to migrations")
+	storageType  = flag.String("storage", service.StorageSQLite, "Storage backend (sqlite, postgres or inmem)")
+	migrations   = flag.String("migrations", "", "Path to SQLite migrations (embedded ones are used if empty)")
 	sqlitestring = flag.String("sqlitedb", "./db.sqlite", "Path to SQLite DB")
+	pgMigrations = flag.String("pgmigrations", "", "Path to PostgreSQL migrations (embedded ones are used if empty)")
+	pgstring     = flag.String("pgdb", "postgres://localhost/woofer?sslmode=disable", "PostgreSQL connection string")
+	fanoutLimit  = flag.Uint("fanout", 0, "Max subscriber count to deliver tweets to timelines on write (0 disables fan-out-on-write)")
+
+	sessionsType  = flag.String("sessions", sessionsInmem, "Session storage backend (inmem, sqlite or redis); sqlite keeps sessions in -sqlitedb")
+	redisURL      = flag.String("redis", "redis://localhost:6379/0", "Redis URL for the redis session storage")
+	sessionTTL    = flag.Duration("session-ttl", 14*24*time.Hour, "Sessions expire if they're not used for this long")
+	sessionMaxAge = flag.Duration("session-max-age", 90*24*time.Hour, "Sessions expire after this long since login regardless of their use (0 disables)")
+	sessionPurge  = flag.Duration("session-purge", time.Hour, "Interval between purges of expired sessions in sqlite")
+
+	cookieHTTPOnly = flag.Bool("cookie-httponly", true, "Hide session cookies from scripts")
+	cookieSecure   = flag.Bool("cookie-secure", false, "Send session cookies over HTTPS only")
+	cookieSameSite = flag.String("cookie-samesite", "lax", "SameSite attribute of session cookies (lax, strict or none)")
+
+	keysetPath = flag.String("keyset", "", "Path to the token signing keyset; enables signed token authentication")
+	keysetKeep = flag.Int("keyset-keep", 3, "Number of signing keys kept by rotate-keys")
+	accessTTL  = flag.Duration("access-ttl", 15*time.Minute, "Lifetime of access tokens; refresh tokens live as long as sessions")
+)
+
+// Session storage backends.
+const (
+	sessionsInmem  = "inmem"
+	sessionsSQLite = "sqlite"
+	sessionsRedis  = "redis"
 )
 
 func main() {
 	logrus.Info("Wiring up services...")
 	flag.Parse()
-	svc, err := service.Bootstrap(
-		service.Config{
-			SQLiteConnString: *sqlitestring,
-			SQLiteMigrations: *migrations,
-		},
-	)
+	cfg := service.Config{
+		Storage:            *storageType,
+		SQLiteConnString:   *sqlitestring,
+		SQLiteMigrations:   *migrations,
+		PostgresConnString: *pgstring,
+		PostgresMigrations: *pgMigrations,
+		FanoutLimit:        *fanoutLimit,
+	}
+
+	if flag.Arg(0) == "migrate" {
+		err := runMigrate(cfg, flag.Args()[1:])
+		if err == errMigrateUsage {
+			printMigrateUsage()
+		}
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
+	if flag.Arg(0) == "rotate-keys" {
+		err := runRotateKeys()
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+
+	svc, err := service.Bootstrap(cfg)
+	if errors.Cause(err) == migrator.ErrOutdated {
+		logrus.Fatalf("%v; use 'woofer migrate' to update the schema", err)
+	}
 	if err != nil {
 		logrus.Fatal(err)
 	}
 
-	sess := inmemsessions.New(time.Hour * 24)
-	hdl := ihttp.NewHandler(svc, sess)
+	if flag.Arg(0) == "index-hashtags" {
+		n, err := svc.IndexHashtags(context.Background())
+		if err != nil {
+			logrus.Fatal(err)
+		}
+		logrus.Infof("Indexed hashtags of %v tweets", n)
+		return
+	}
+
+	sess, err := newSessions()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	cookies, err := cookiePolicy()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	iss, err := newIssuer()
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	hdl := ihttp.NewHandler(svc, sess, cookies, iss)
 
 	r := chi.NewRouter()
-	r.Use(middleware.Timeout(time.Second * 10))
 	r.Use(middleware.RequestID)
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
 	r.Use(ihttp.UserAuthCtx(sess))
+	if iss != nil {
+		r.Use(ihttp.TokenAuthCtx(iss, sess))
+	}
+
+	// streams are long-lived, so they're not limited by the timeout
+	r.With(ihttp.RequireAuth).Get("/stream", hdl.Stream)
+
+	r.Group(func(r chi.Router) {
+		r.Use(middleware.Timeout(time.Second * 10))
+		routes(r, hdl)
+	})
+	logrus.Info("Listening on " + *listenPort)
+	http.ListenAndServe(*listenPort, r)
+}
+
+// newSessions creates a session storage chosen by the flags.
+func newSessions() (session.Storage, error) {
+	switch *sessionsType {
+	case sessionsInmem:
+		return inmemsessions.New(*sessionTTL, *sessionMaxAge), nil
+	case sessionsSQLite:
+		s, err := sqlitesessions.New(*sqlitestring, *sessionTTL, *sessionMaxAge)
+		if err != nil {
+			return nil, errors.Wrap(err, "session storage init failed")
+		}
+		go s.PurgeEvery(*sessionPurge, nil)
+		return s, nil
+	case sessionsRedis:
+		s, err := redissessions.New(*redisURL, *sessionTTL, *sessionMaxAge)
+		if err != nil {
+			return nil, errors.Wrap(err, "session storage init failed")
+		}
+		return s, nil
+	}
+	return nil, errors.Errorf("unknown session storage backend %q", *sessionsType)
+}
 
+// sessionLifetime returns the longest time a session may live for.
+func sessionLifetime() time.Duration {
+	if *sessionMaxAge != 0 {
+		return *sessionMaxAge
+	}
+	return *sessionTTL
+}
+
+// cookiePolicy returns a session cookie policy set by the flags.
+// Cookies live as long as the longest session may.
+func cookiePolicy() (ihttp.CookiePolicy, error) {
+	ret := ihttp.CookiePolicy{
+		TTL:      sessionLifetime(),
+		HTTPOnly: *cookieHTTPOnly,
+		Secure:   *cookieSecure,
+	}
+
+	switch *cookieSameSite {
+	case "lax":
+		ret.SameSite = http.SameSiteLaxMode
+	case "strict":
+		ret.SameSite = http.SameSiteStrictMode
+	case "none":
+		if !ret.Secure {
+			return ret, errors.New("browsers reject SameSite=None cookies without -cookie-secure")
+		}
+		ret.SameSite = http.SameSiteNoneMode
+	default:
+		return ret, errors.Errorf("unknown SameSite mode %q", *cookieSameSite)
+	}
+	return ret, nil
+}
+
+// routes registers request-response endpoints.
+func routes(r chi.Router, hdl *ihttp.Handler) {
 	r.Get("/", func(http.ResponseWriter, *http.Request) {
 		// 200 healthcheck
 	})
 
 	r.Post("/user/create", hdl.UserCreate)
 	r.Post("/auth", hdl.Login)
+	r.Post("/auth/refresh", hdl.RefreshTokens)
+	r.Get("/.well-known/jwks.json", hdl.JWKS)
 	r.Route("/", func(r chi.Router) {
 		r.Use(ihttp.RequireAuth)
+		r.Post("/logout", hdl.Logout)
+		r.Get("/sessions", hdl.Sessions)
+		r.Delete("/sessions/{id}", hdl.RevokeSession)
+		r.Post("/user/modify", hdl.UserModify)
 		r.Post("/tweet", hdl.Tweet)
+		r.Patch("/tweet/{id}", hdl.EditTweet)
+		r.Delete("/tweet/{id}", hdl.DeleteTweet)
+		r.Get("/tweet/{id}/history", hdl.TweetHistory)
+		r.Get("/tweet/{id}/thread", hdl.GetThread)
+		r.Post("/tweet/{id}/retweet", hdl.Retweet)
+		r.Post("/tweet/{id}/like", hdl.Like)
+		r.Delete("/tweet/{id}/like", hdl.Unlike)
 		r.Get("/posts", hdl.GetTweetPage)
+		r.Get("/mentions", hdl.GetMentions)
+		r.Get("/notifications", hdl.GetNotifications)
+		r.Post("/notifications/read", hdl.ReadNotifications)
+		r.Get("/tag/{tag}", hdl.GetTaggedTweets)
+		r.Get("/search/tweets", hdl.SearchTweets)
+		r.Get("/search/users", hdl.SearchUsers)
+		r.Route("/conversations", func(r chi.Router) {
+			r.Get("/", hdl.Conversations)
+			r.Post("/", hdl.StartConversation)
+			r.Get("/{id}", hdl.Conversation)
+			r.Get("/{id}/messages", hdl.GetMessages)
+			r.Post("/{id}/messages", hdl.SendMessage)
+			r.Post("/{id}/read", hdl.ReadConversation)
+		})
 		r.Route("/u/{nickname}", func(r chi.Router) {
 			r.Get("/", hdl.GetUser)
 			r.Get("/tweets", hdl.GetProfileTweets)
+			r.Get("/likes", hdl.GetLikedTweets)
 			r.Get("/subscribe", hdl.Subscribe)
 			r.Get("/unsubscribe", hdl.Unsubscribe)
+			r.Get("/approve", hdl.ApproveFollow)
+			r.Get("/reject", hdl.RejectFollow)
+			r.Get("/block", hdl.Block)
+			r.Get("/unblock", hdl.Unblock)
+			r.Get("/mute", hdl.Mute)
+			r.Get("/unmute", hdl.Unmute)
 		})
 		r.Get("/subscriptions", hdl.Subscriptions)
 		r.Get("/subscribers", hdl.Subscribers)
+		r.Get("/requests", hdl.FollowRequests)
+		r.Get("/blocked", hdl.BlockedUsers)
+		r.Get("/muted", hdl.MutedUsers)
 	})
-	logrus.Info("Listening on " + *listenPort)
-	http.ListenAndServe(*listenPort, r)
 }
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/service"
+)
+
+const migrateUsage = `usage: woofer [flags] migrate <command>
+
+Commands:
+  up         apply all pending migrations
+  down N     roll back N last migrations
+  status     print current schema version
+  goto V     migrate up or down to version V
+  force V    set version V without running migrations (use -1 for none)`
+
+// errMigrateUsage is returned by runMigrate if its args are malformed.
+var errMigrateUsage = errors.New("incorrect migrate command")
+
+// runMigrate runs the 'migrate' subcommand.
+func runMigrate(cfg service.Config, args []string) error {
+	if len(args) == 0 {
+		return errMigrateUsage
+	}
+
+	m, err := service.NewMigrator(cfg)
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down":
+		var n uint64
+		n, err = uintArg(args)
+		if err == nil {
+			err = m.Down(int(n))
+		}
+	case "goto":
+		var v uint64
+		v, err = uintArg(args)
+		if err == nil {
+			err = m.Goto(uint(v))
+		}
+	case "force":
+		if len(args) != 2 {
+			return errMigrateUsage
+		}
+		var v int
+		v, err = strconv.Atoi(args[1])
+		if err != nil {
+			return errors.Wrap(err, "couldn't parse version")
+		}
+		err = m.Force(v)
+	case "status":
+	default:
+		return errMigrateUsage
+	}
+	if err != nil {
+		return err
+	}
+
+	st, err := m.Status()
+	if err != nil {
+		return err
+	}
+	fmt.Println(st)
+	return nil
+}
+
+// uintArg parses a single numeric argument of a command.
+func uintArg(args []string) (uint64, error) {
+	if len(args) != 2 {
+		return 0, errMigrateUsage
+	}
+	ret, err := strconv.ParseUint(args[1], 10, 0)
+	return ret, errors.Wrap(err, "couldn't parse argument")
+}
+
+// printMigrateUsage prints usage of the 'migrate' subcommand and exits.
+func printMigrateUsage() {
+	fmt.Fprintln(os.Stderr, migrateUsage)
+	os.Exit(2)
+}
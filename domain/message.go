@@ -0,0 +1,45 @@
+package domain
+
+import "time"
+
+// Message is a direct message sent to a conversation.
+type Message struct {
+	ID             uint64
+	ConversationID uint64
+	From           UserID
+	At             time.Time
+	Text           string
+}
+
+// Conversation is a direct messages thread between a few users,
+// as seen by one of them.
+type Conversation struct {
+	ID uint64
+	// Members are all users taking part in the conversation,
+	// including the viewer.
+	Members []UserID
+	// LastMessage is the latest message, nil if there's none yet.
+	LastMessage *Message `json:",omitempty"`
+	// LastRead is an ID of the latest message read by the viewer.
+	LastRead uint64
+	// Unread is a number of other members' messages the viewer
+	// hasn't read yet.
+	Unread uint64
+}
+
+// ConversationWithUsers shadows Members field with members' profiles.
+type ConversationWithUsers struct {
+	Conversation
+	Members []User
+}
+
+// MessagePage is a page of conversation's messages, ordered from
+// the newest messages to the oldest ones.
+type MessagePage struct {
+	Messages []Message
+	// Next is a cursor pointing to older messages.
+	// It's empty if there's no older messages.
+	Next string
+	// Prev is a cursor pointing to newer messages.
+	Prev string
+}
@@ -0,0 +1,47 @@
+package domain
+
+import "time"
+
+// NotificationKind describes what a user is notified about.
+type NotificationKind string
+
+const (
+	// NotifySubscribed is sent when someone subscribes to the user.
+	NotifySubscribed NotificationKind = "subscribe"
+	// NotifyFollowRequested is sent when someone requests to subscribe
+	// to the protected user.
+	NotifyFollowRequested NotificationKind = "follow_request"
+	// NotifyMentioned is sent when someone mentions the user in a tweet.
+	NotifyMentioned NotificationKind = "mention"
+	// NotifyReplied is sent when someone replies to the user's tweet.
+	NotifyReplied NotificationKind = "reply"
+	// NotifyLiked is sent when someone likes the user's tweet.
+	NotifyLiked NotificationKind = "like"
+)
+
+// Notification tells a user about other user's action.
+type Notification struct {
+	ID   uint64
+	Kind NotificationKind
+	// From is a nickname of the user who caused the notification.
+	From string
+	// TweetID is a tweet the notification is about: the reply
+	// or the tweet with a mention, or the liked tweet.
+	// It's 0 for notifications about subscriptions.
+	TweetID uint64 `json:",omitempty"`
+	At      time.Time
+	Read    bool
+}
+
+// NotificationPage is a page of user's notifications, ordered from
+// the newest ones to the oldest ones.
+type NotificationPage struct {
+	Notifications []Notification
+	// Unread is a number of all unread notifications of the user.
+	Unread uint64
+	// Next is a cursor pointing to older notifications.
+	// It's empty if there's no older notifications.
+	Next string
+	// Prev is a cursor pointing to newer notifications.
+	Prev string
+}
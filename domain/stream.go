@@ -0,0 +1,20 @@
+package domain
+
+// StreamEvent is an update pushed to user's connected clients.
+// Exactly one of its payload fields is set.
+type StreamEvent struct {
+	// ID is a cursor to resume the stream after this event.
+	ID string
+	// Tweet is a new tweet of the user's home timeline.
+	Tweet *TweetWithUsername `json:",omitempty"`
+	// Notification is a new notification of the user.
+	Notification *Notification `json:",omitempty"`
+}
+
+// Type returns a name of the event's payload: "tweet" or "notification".
+func (e StreamEvent) Type() string {
+	if e.Notification != nil {
+		return "notification"
+	}
+	return "tweet"
+}
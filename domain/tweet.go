@@ -7,10 +7,73 @@ type Tweet struct {
 	From UserID
 	At   time.Time
 	Text string
+	// InReplyTo is an ID of a tweet this one replies to, 0 if it's not a reply.
+	InReplyTo uint64
+	// RetweetOf is an ID of a tweet reshared by this one, 0 if it's not a retweet.
+	// Text of a plain retweet is empty; quote tweets have a comment in Text.
+	RetweetOf uint64
+	// EditedAt is a time of the last edit, nil if the tweet wasn't edited.
+	EditedAt *time.Time `json:",omitempty"`
+}
+
+// IsPlainRetweet returns true if the tweet reshares another one
+// without a comment.
+func (t Tweet) IsPlainRetweet() bool {
+	return t.RetweetOf != 0 && t.Text == ""
+}
+
+// TweetRevision is a version of an edited tweet.
+type TweetRevision struct {
+	Text string
+	// At is a time this version was published at.
+	At time.Time
 }
 
 // TweetWithUsername shadows From field with username of a tweeter.
 type TweetWithUsername struct {
 	Tweet
 	From string
+	// Original is a tweet reshared by this one if it's a retweet.
+	Original *TweetWithUsername `json:",omitempty"`
+
+	// Likes is a number of users who liked the tweet.
+	Likes uint64
+	// LikedByMe is true if the tweet is liked by the current user.
+	LikedByMe bool
+
+	// Mentions are users mentioned in the tweet's text.
+	Mentions []Mention `json:",omitempty"`
+}
+
+// Mention is a reference to a user in a tweet's text, like @nickname.
+type Mention struct {
+	UserID   UserID
+	Nickname string
+	// Start and End are offsets of the mention in the tweet's text
+	// including the '@', counted in characters (not bytes).
+	// End is exclusive.
+	Start uint
+	End   uint
+}
+
+// TweetPage is a page of a tweet list, ordered from the newest tweets
+// to the oldest ones.
+type TweetPage struct {
+	Tweets []TweetWithUsername
+	// Next is a cursor pointing to older tweets.
+	// It's empty if there's no older tweets.
+	Next string
+	// Prev is a cursor pointing to newer tweets.
+	Prev string
+}
+
+// Thread is a conversation around a tweet.
+type Thread struct {
+	Tweet TweetWithUsername
+	// Ancestors is a chain of tweets that Tweet replies to,
+	// from the conversation's root to the Tweet's parent.
+	Ancestors []TweetWithUsername
+	// Replies is a page of all the Tweet's descendants.
+	// Every reply refers to its parent with InReplyTo.
+	Replies TweetPage
 }
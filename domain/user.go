@@ -7,6 +7,8 @@ type User struct {
 	ID       UserID
 	Nickname string
 	RealName string
+	// Protected accounts' tweets are visible to approved subscribers only.
+	Protected bool
 }
 
 // UserWithPassword is a user with embedded password field.
@@ -21,8 +21,12 @@ func renderError(w http.ResponseWriter, err error, retCode int) {
 		switch code {
 		case bizerr.ErrorUserInput:
 			retCode = 400
+		case bizerr.ErrorUnauthorized:
+			retCode = http.StatusForbidden
 		case bizerr.ErrorConflict:
 			retCode = http.StatusConflict
+		case bizerr.ErrorNotFound:
+			retCode = http.StatusNotFound
 		}
 	}
 
@@ -2,15 +2,17 @@ package ihttp
 
 import (
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
-	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/go-chi/chi"
 	"github.com/pkg/errors"
 	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/auth"
 	"github.com/utrack/woofer/lib/session"
+	"github.com/utrack/woofer/lib/tokens"
 	"github.com/utrack/woofer/service"
 )
 
@@ -18,14 +20,25 @@ import (
 type Handler struct {
 	svc  *service.Woofer
 	sess session.Storage
+	// cookies is a policy of session cookies.
+	cookies CookiePolicy
+	// tokens issues signed tokens; nil if they're disabled.
+	tokens *tokens.Issuer
 }
 
 // NewHandler creates a new Handler using services provided.
-func NewHandler(svc *service.Woofer, sess session.Storage) *Handler {
-	return &Handler{svc: svc, sess: sess}
+// Session cookies are set according to the policy.
+// Login issues signed tokens on request if iss is not nil.
+func NewHandler(svc *service.Woofer, sess session.Storage, cookies CookiePolicy, iss *tokens.Issuer) *Handler {
+	return &Handler{svc: svc, sess: sess, cookies: cookies, tokens: iss}
 }
 
 type tweetRequest struct {
+	Text      string `json:"text"`
+	InReplyTo uint64 `json:"in_reply_to"`
+}
+
+type retweetRequest struct {
 	Text string `json:"text"`
 }
 
@@ -33,6 +46,10 @@ type tweetResponse struct {
 	TweetID uint64 `json:"tweet_id"`
 }
 
+type subscribeResponse struct {
+	Pending bool `json:"pending"`
+}
+
 type userCreateResponse struct {
 	UserID domain.UserID `json:"user_id"`
 }
@@ -47,7 +64,7 @@ func (h Handler) Tweet(w http.ResponseWriter, r *http.Request) {
 		renderError(w, errors.Wrap(err, "error when parsing JSON body"), 400)
 		return
 	}
-	id, err := h.svc.Tweet(r.Context(), req.Text)
+	id, err := h.svc.Tweet(r.Context(), req.Text, req.InReplyTo)
 	if err != nil {
 		renderError(w, err, 500)
 		return
@@ -55,33 +72,299 @@ func (h Handler) Tweet(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(tweetResponse{TweetID: id})
 }
 
-// GetTweetPage is a GET request that has ?from URI param.
+// Retweet is a POST request that has tweet's id path URI param.
+// It can contain retweetRequest with a comment to make a quote tweet.
+// Returns tweetResponse.
+func (h Handler) Retweet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "couldn't parse tweet ID"), 400)
+		return
+	}
+	var req retweetRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		renderError(w, errors.Wrap(err, "error when parsing JSON body"), 400)
+		return
+	}
+	retweetID, err := h.svc.Retweet(r.Context(), id, req.Text)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(tweetResponse{TweetID: retweetID})
+}
+
+// EditTweet is a PATCH request that has tweet's id path URI param
+// and contains tweetRequest with a new text.
+func (h Handler) EditTweet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "couldn't parse tweet ID"), 400)
+		return
+	}
+	var req tweetRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "error when parsing JSON body"), 400)
+		return
+	}
+	err = h.svc.EditTweet(r.Context(), id, req.Text)
+	renderError(w, err, 500)
+}
+
+// DeleteTweet is a DELETE request that has tweet's id path URI param.
+func (h Handler) DeleteTweet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "couldn't parse tweet ID"), 400)
+		return
+	}
+	err = h.svc.DeleteTweet(r.Context(), id)
+	renderError(w, err, 500)
+}
+
+// TweetHistory is a GET request that has tweet's id path URI param.
+// Returns a list of domain.TweetRevision, oldest first.
+func (h Handler) TweetHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "couldn't parse tweet ID"), 400)
+		return
+	}
+	revs, err := h.svc.TweetHistory(r.Context(), id)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(revs)
+}
+
+// Like is a POST request that has tweet's id path URI param.
+func (h Handler) Like(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "couldn't parse tweet ID"), 400)
+		return
+	}
+	err = h.svc.Like(r.Context(), id)
+	renderError(w, err, 500)
+}
+
+// Unlike is a DELETE request that has tweet's id path URI param.
+func (h Handler) Unlike(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "couldn't parse tweet ID"), 400)
+		return
+	}
+	err = h.svc.Unlike(r.Context(), id)
+	renderError(w, err, 500)
+}
+
+// GetTweetPage is a GET request that has ?before, ?after and ?limit URI params.
+// Returns domain.TweetPage; its Next and Prev cursors should be passed
+// as before and after params to get the neighbour pages.
 func (h Handler) GetTweetPage(w http.ResponseWriter, r *http.Request) {
-	from := r.URL.Query().Get("from")
-	fromUint, _ := strconv.ParseUint(from, 10, 0)
-	tweets, err := h.svc.GetTweetPage(r.Context(), fromUint)
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	tweets, err := h.svc.GetTweetPage(r.Context(), q)
 	if err != nil {
 		renderError(w, err, 500)
+		return
 	}
 	json.NewEncoder(w).Encode(tweets)
 }
 
-// GetProfileTweets is a GET request that has ?from URI param and nickname path URI param.
+// GetProfileTweets is a GET request that has ?before, ?after and ?limit
+// URI params and nickname path URI param.
+// Returns domain.TweetPage, see GetTweetPage.
 func (h Handler) GetProfileTweets(w http.ResponseWriter, r *http.Request) {
 	targetStr := chi.URLParam(r, "nickname")
-	from := r.URL.Query().Get("from")
-	fromUint, _ := strconv.ParseUint(from, 10, 0)
-	tweets, err := h.svc.GetTweetsForProfile(r.Context(), targetStr, fromUint)
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	tweets, err := h.svc.GetTweetsForProfile(r.Context(), targetStr, q)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(tweets)
+}
+
+// GetLikedTweets is a GET request that has ?before, ?after and ?limit
+// URI params and nickname path URI param.
+// Returns domain.TweetPage of tweets in the order they were liked,
+// see GetTweetPage.
+func (h Handler) GetLikedTweets(w http.ResponseWriter, r *http.Request) {
+	targetStr := chi.URLParam(r, "nickname")
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	tweets, err := h.svc.GetLikedTweets(r.Context(), targetStr, q)
 	if err != nil {
 		renderError(w, err, 500)
+		return
 	}
 	json.NewEncoder(w).Encode(tweets)
 }
 
+// GetMentions is a GET request that has ?before, ?after and ?limit URI params.
+// Returns domain.TweetPage of tweets mentioning the current user,
+// see GetTweetPage.
+func (h Handler) GetMentions(w http.ResponseWriter, r *http.Request) {
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	tweets, err := h.svc.GetMentions(r.Context(), q)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(tweets)
+}
+
+// GetTaggedTweets is a GET request that has ?before, ?after and ?limit
+// URI params and tag path URI param.
+// Returns domain.TweetPage, see GetTweetPage.
+func (h Handler) GetTaggedTweets(w http.ResponseWriter, r *http.Request) {
+	tag := chi.URLParam(r, "tag")
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	tweets, err := h.svc.GetTaggedTweets(r.Context(), tag, q)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(tweets)
+}
+
+// SearchTweets is a GET request that has ?q search query URI param
+// and ?before, ?after and ?limit URI params.
+// Returns domain.TweetPage, see GetTweetPage and service.Woofer.SearchTweets.
+func (h Handler) SearchTweets(w http.ResponseWriter, r *http.Request) {
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	tweets, err := h.svc.SearchTweets(r.Context(), r.URL.Query().Get("q"), q)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(tweets)
+}
+
+// SearchUsers is a GET request that has ?q search query and ?limit URI params.
+// Returns a list of domain.User, the best matches first.
+func (h Handler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	v := r.URL.Query()
+	var limit uint64
+	if l := v.Get("limit"); l != "" {
+		var err error
+		limit, err = strconv.ParseUint(l, 10, 0)
+		if err != nil {
+			renderError(w, errors.Wrap(err, "couldn't parse limit"), 400)
+			return
+		}
+	}
+	users, err := h.svc.SearchUsers(r.Context(), v.Get("q"), uint(limit))
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
+// GetThread is a GET request that has tweet's id path URI param
+// and ?before, ?after and ?limit URI params for the replies.
+// Returns domain.Thread.
+func (h Handler) GetThread(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "couldn't parse tweet ID"), 400)
+		return
+	}
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	thread, err := h.svc.GetThread(r.Context(), id, q)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(thread)
+}
+
+// pageQuery reads ?before, ?after and ?limit URI params.
+func pageQuery(r *http.Request) (service.PageQuery, error) {
+	v := r.URL.Query()
+	ret := service.PageQuery{
+		Before: v.Get("before"),
+		After:  v.Get("after"),
+	}
+	if limit := v.Get("limit"); limit != "" {
+		l, err := strconv.ParseUint(limit, 10, 0)
+		if err != nil {
+			return ret, errors.Wrap(err, "couldn't parse limit")
+		}
+		ret.Limit = uint(l)
+	}
+	return ret, nil
+}
+
 // Subscribe is a GET request that has path URI param 'nickname'.
+// Responds with 202 Accepted and subscribeResponse if the user
+// is protected and the follow request awaits approval.
 func (h Handler) Subscribe(w http.ResponseWriter, r *http.Request) {
 	targetStr := chi.URLParam(r, "nickname")
-	err := h.svc.Subscribe(r.Context(), targetStr)
+	pending, err := h.svc.Subscribe(r.Context(), targetStr)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	if pending {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(subscribeResponse{Pending: pending})
+}
+
+// FollowRequests is a GET request without any parameters.
+func (h Handler) FollowRequests(w http.ResponseWriter, r *http.Request) {
+	users, err := h.svc.FollowRequests(r.Context())
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
+// ApproveFollow is a GET request that has path URI param 'nickname'.
+func (h Handler) ApproveFollow(w http.ResponseWriter, r *http.Request) {
+	targetStr := chi.URLParam(r, "nickname")
+	err := h.svc.ApproveFollow(r.Context(), targetStr)
+	renderError(w, err, 500)
+}
+
+// RejectFollow is a GET request that has path URI param 'nickname'.
+func (h Handler) RejectFollow(w http.ResponseWriter, r *http.Request) {
+	targetStr := chi.URLParam(r, "nickname")
+	err := h.svc.RejectFollow(r.Context(), targetStr)
 	renderError(w, err, 500)
 }
 
@@ -92,6 +375,34 @@ func (h Handler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
 	renderError(w, err, 500)
 }
 
+// Block is a GET request that has path URI param 'nickname'.
+func (h Handler) Block(w http.ResponseWriter, r *http.Request) {
+	targetStr := chi.URLParam(r, "nickname")
+	err := h.svc.Block(r.Context(), targetStr)
+	renderError(w, err, 500)
+}
+
+// Unblock is a GET request that has path URI param 'nickname'.
+func (h Handler) Unblock(w http.ResponseWriter, r *http.Request) {
+	targetStr := chi.URLParam(r, "nickname")
+	err := h.svc.Unblock(r.Context(), targetStr)
+	renderError(w, err, 500)
+}
+
+// Mute is a GET request that has path URI param 'nickname'.
+func (h Handler) Mute(w http.ResponseWriter, r *http.Request) {
+	targetStr := chi.URLParam(r, "nickname")
+	err := h.svc.Mute(r.Context(), targetStr)
+	renderError(w, err, 500)
+}
+
+// Unmute is a GET request that has path URI param 'nickname'.
+func (h Handler) Unmute(w http.ResponseWriter, r *http.Request) {
+	targetStr := chi.URLParam(r, "nickname")
+	err := h.svc.Unmute(r.Context(), targetStr)
+	renderError(w, err, 500)
+}
+
 // GetUser is a GET request that has path URI param 'nickname'.
 func (h Handler) GetUser(w http.ResponseWriter, r *http.Request) {
 	targetStr := chi.URLParam(r, "nickname")
@@ -121,6 +432,26 @@ func (h Handler) Subscribers(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(subs)
 }
 
+// BlockedUsers is a GET request without any parameters.
+func (h Handler) BlockedUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.svc.BlockedUsers(r.Context())
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
+// MutedUsers is a GET request without any parameters.
+func (h Handler) MutedUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.svc.MutedUsers(r.Context())
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(users)
+}
+
 // UserCreate is a POST request that should contain domain.UserWithPassword
 // JSON in its body.
 func (h Handler) UserCreate(w http.ResponseWriter, r *http.Request) {
@@ -138,7 +469,37 @@ func (h Handler) UserCreate(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(userCreateResponse{UserID: id})
 }
 
+// UserModify is a POST request that should contain domain.User
+// JSON in its body. User's ID and nickname are not changed.
+func (h Handler) UserModify(w http.ResponseWriter, r *http.Request) {
+	var req domain.User
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "error when parsing JSON body"), 400)
+		return
+	}
+	err = h.svc.UserModify(r.Context(), req)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	// account's privacy has changed, issue a fresh session ID;
+	// requests authenticated by tokens don't carry sessions to rotate
+	if auth.SessionID(r.Context()) == "" {
+		return
+	}
+	uid, err := auth.UserID(r.Context())
+	if err != nil {
+		renderError(w, err, 403)
+		return
+	}
+	err = h.startSession(w, r, uid)
+	renderError(w, err, 500)
+}
+
 // Login is a POST form with username and password as form params.
+// It starts a cookie session, or returns tokenResponse if the mode param
+// is "token".
 func (h Handler) Login(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 
@@ -160,13 +521,10 @@ func (h Handler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	expiration := time.Now().Add(14 * 24 * time.Hour)
-	sessID, err := h.sess.SaveID(userObj.ID)
-	if err != nil {
-		renderError(w, err, 500)
+	if r.FormValue("mode") == loginModeToken {
+		h.issueTokens(w, userObj.ID)
 		return
 	}
-	cookie := http.Cookie{Name: cookieSessID, Value: sessID, Expires: expiration}
-	http.SetCookie(w, &cookie)
-
+	err = h.startSession(w, r, userObj.ID)
+	renderError(w, err, 500)
 }
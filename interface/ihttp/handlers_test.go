@@ -0,0 +1,145 @@
+package ihttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/session"
+	"github.com/utrack/woofer/lib/session/inmemsessions"
+	"github.com/utrack/woofer/lib/tokens"
+	"github.com/utrack/woofer/service"
+)
+
+func newTestIssuer(t *testing.T) *tokens.Issuer {
+	path := filepath.Join(t.TempDir(), "keyset.json")
+	err := tokens.RotateKeyset(path, 1)
+	if err != nil {
+		t.Fatalf("RotateKeyset: %v", err)
+	}
+	keys, err := tokens.LoadKeyset(path)
+	if err != nil {
+		t.Fatalf("LoadKeyset: %v", err)
+	}
+	return tokens.NewIssuer(keys, time.Minute, time.Hour, 2*time.Hour)
+}
+
+func TestRefreshTokenIsSingleUse(t *testing.T) {
+	iss := newTestIssuer(t)
+	sess := inmemsessions.New(time.Hour, 0)
+	h := NewHandler(nil, sess, CookiePolicy{TTL: time.Hour}, iss)
+	pair, err := iss.Issue(1)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	const n = 8
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			form := url.Values{"refresh_token": {pair.RefreshToken}}
+			r := httptest.NewRequest("POST", "/tokens/refresh", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			h.RefreshTokens(w, r)
+			codes <- w.Code
+		}()
+	}
+	wg.Wait()
+	close(codes)
+
+	var ok int
+	for code := range codes {
+		switch code {
+		case http.StatusOK:
+			ok++
+		case http.StatusForbidden:
+		default:
+			t.Errorf("RefreshTokens returned %v", code)
+		}
+	}
+	if ok != 1 {
+		t.Errorf("refresh token was used %v times, want once", ok)
+	}
+}
+
+func TestUserModifyRotatesCookieSessionsOnly(t *testing.T) {
+	svc, err := service.Bootstrap(service.Config{Storage: service.StorageInmem})
+	if err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	uid, err := svc.UserCreate(context.Background(), domain.UserWithPassword{
+		User:     domain.User{Nickname: "alice"},
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatalf("UserCreate: %v", err)
+	}
+
+	iss := newTestIssuer(t)
+	sess := inmemsessions.New(time.Hour, 0)
+	h := NewHandler(svc, sess, CookiePolicy{TTL: time.Hour}, iss)
+	handler := UserAuthCtx(sess)(TokenAuthCtx(iss, sess)(http.HandlerFunc(h.UserModify)))
+
+	modify := func(auth func(*http.Request)) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/user", strings.NewReader(`{"Protected":true}`))
+		auth(r)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("UserModify returned %v: %v", w.Code, w.Body)
+		}
+		return w
+	}
+
+	// cookie sessions are rotated
+	sessID, err := sess.SaveID(uid, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	w := modify(func(r *http.Request) {
+		r.AddCookie(&http.Cookie{Name: cookieSessID, Value: sessID})
+	})
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != cookieSessID || cookies[0].Value == sessID {
+		t.Errorf("cookie request got cookies %v, want a new session cookie", cookies)
+	}
+	_, err = sess.IDForSession(sessID)
+	if err != session.ErrNotFound {
+		t.Errorf("old session wasn't ended: %v", err)
+	}
+
+	// token requests get no cookies nor sessions
+	before, err := sess.Sessions(uid)
+	if err != nil {
+		t.Fatalf("Sessions: %v", err)
+	}
+	pair, err := iss.Issue(uid)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	w = modify(func(r *http.Request) {
+		r.Header.Set("Authorization", bearerPrefix+pair.AccessToken)
+	})
+	if cookies := w.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("token request got cookies %v", cookies)
+	}
+	after, err := sess.Sessions(uid)
+	if err != nil {
+		t.Fatalf("Sessions: %v", err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("token request created a session: %v sessions before, %v after", len(before), len(after))
+	}
+}
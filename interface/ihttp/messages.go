@@ -0,0 +1,134 @@
+package ihttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+	"github.com/pkg/errors"
+)
+
+type conversationRequest struct {
+	// Members are nicknames of users to talk to.
+	Members []string `json:"members"`
+}
+
+type conversationResponse struct {
+	ConversationID uint64 `json:"conversation_id"`
+}
+
+type messageRequest struct {
+	Text string `json:"text"`
+}
+
+type messageResponse struct {
+	MessageID uint64 `json:"message_id"`
+}
+
+// StartConversation is a POST request containing conversationRequest.
+// Returns conversationResponse.
+func (h Handler) StartConversation(w http.ResponseWriter, r *http.Request) {
+	var req conversationRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "error when parsing JSON body"), 400)
+		return
+	}
+	id, err := h.svc.StartConversation(r.Context(), req.Members)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(conversationResponse{ConversationID: id})
+}
+
+// Conversations is a GET request without any parameters.
+// Returns a list of domain.ConversationWithUsers.
+func (h Handler) Conversations(w http.ResponseWriter, r *http.Request) {
+	convs, err := h.svc.Conversations(r.Context())
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(convs)
+}
+
+// Conversation is a GET request that has conversation's id path URI param.
+// Returns domain.ConversationWithUsers.
+func (h Handler) Conversation(w http.ResponseWriter, r *http.Request) {
+	id, err := conversationID(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	conv, err := h.svc.Conversation(r.Context(), id)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(conv)
+}
+
+// GetMessages is a GET request that has ?before, ?after and ?limit
+// URI params and conversation's id path URI param.
+// Returns domain.MessagePage.
+func (h Handler) GetMessages(w http.ResponseWriter, r *http.Request) {
+	id, err := conversationID(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	msgs, err := h.svc.GetMessages(r.Context(), id, q)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(msgs)
+}
+
+// SendMessage is a POST request containing messageRequest that has
+// conversation's id path URI param.
+// Returns messageResponse.
+func (h Handler) SendMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := conversationID(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	var req messageRequest
+	err = json.NewDecoder(r.Body).Decode(&req)
+	if err != nil {
+		renderError(w, errors.Wrap(err, "error when parsing JSON body"), 400)
+		return
+	}
+	msgID, err := h.svc.SendMessage(r.Context(), id, req.Text)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(messageResponse{MessageID: msgID})
+}
+
+// ReadConversation is a POST request that has conversation's id
+// path URI param. It marks all the conversation's messages as read.
+func (h Handler) ReadConversation(w http.ResponseWriter, r *http.Request) {
+	id, err := conversationID(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	err = h.svc.ReadConversation(r.Context(), id)
+	renderError(w, err, 500)
+}
+
+// conversationID reads conversation's id path URI param.
+func conversationID(r *http.Request) (uint64, error) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 0)
+	return id, errors.Wrap(err, "couldn't parse conversation ID")
+}
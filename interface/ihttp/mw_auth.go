@@ -3,20 +3,24 @@ package ihttp
 import (
 	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/utrack/woofer/lib/auth"
 	"github.com/utrack/woofer/lib/session"
+	"github.com/utrack/woofer/lib/tokens"
 )
 
 const cookieSessID = "sessid"
 
+const bearerPrefix = "Bearer "
+
+var errNoToken = errors.New("no token in the request")
+
 // UserAuthCtx injects user ID to the context.
 func UserAuthCtx(sessStorage session.Storage) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// If we need to store more data than current user ID -
-			// JWT+JWT.id existence check in sessstorage might be more useful.
-			// exists check is needed to logout users reliably.
+			// Signed tokens are checked by TokenAuthCtx.
 
 			sessionID, err := r.Cookie(cookieSessID)
 			if err != nil {
@@ -26,7 +30,7 @@ func UserAuthCtx(sessStorage session.Storage) func(http.Handler) http.Handler {
 
 			uid, err := sessStorage.IDForSession(sessionID.Value)
 			if err == nil {
-				r = r.WithContext(auth.SetUserID(r.Context(), uid))
+				r = r.WithContext(auth.SetSession(r.Context(), uid, sessionID.Value))
 			} else {
 				// TODO log problem if err != ErrNotFound
 			}
@@ -35,6 +39,44 @@ func UserAuthCtx(sessStorage session.Storage) func(http.Handler) http.Handler {
 	}
 }
 
+// TokenAuthCtx injects user ID to the context if the request carries a
+// valid access token in its Authorization header.
+// Tokens revoked through sessStorage are ignored.
+func TokenAuthCtx(iss *tokens.Issuer, sessStorage session.Storage) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, err := accessClaims(iss, sessStorage, r)
+			if err == nil {
+				uid, err := claims.UserID()
+				if err == nil {
+					r = r.WithContext(auth.SetUserID(r.Context(), uid))
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// accessClaims returns claims of a valid access token carried by the request.
+func accessClaims(iss *tokens.Issuer, sessStorage session.Storage, r *http.Request) (tokens.Claims, error) {
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, bearerPrefix) {
+		return tokens.Claims{}, errNoToken
+	}
+	claims, err := iss.Verify(strings.TrimPrefix(h, bearerPrefix), tokens.TypeAccess)
+	if err != nil {
+		return claims, err
+	}
+	denied, err := sessStorage.TokenDenied(claims.Id)
+	if err != nil {
+		return claims, err
+	}
+	if denied {
+		return claims, tokens.ErrInvalid
+	}
+	return claims, nil
+}
+
 // RequireAuth blocks access to a handler if user is not logged in.
 func RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
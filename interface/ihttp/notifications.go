@@ -0,0 +1,45 @@
+package ihttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+type readNotificationsRequest struct {
+	// UpTo is an ID of the latest notification seen by the user.
+	// All notifications are marked as read if it's zero.
+	UpTo uint64 `json:"up_to"`
+}
+
+// GetNotifications is a GET request that has ?before, ?after and ?limit
+// URI params.
+// Returns domain.NotificationPage.
+func (h Handler) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	q, err := pageQuery(r)
+	if err != nil {
+		renderError(w, err, 400)
+		return
+	}
+	ns, err := h.svc.GetNotifications(r.Context(), q)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	json.NewEncoder(w).Encode(ns)
+}
+
+// ReadNotifications is a POST request that can contain
+// readNotificationsRequest; all notifications are marked as read otherwise.
+func (h Handler) ReadNotifications(w http.ResponseWriter, r *http.Request) {
+	var req readNotificationsRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
+	if err != nil && err != io.EOF {
+		renderError(w, errors.Wrap(err, "error when parsing JSON body"), 400)
+		return
+	}
+	err = h.svc.ReadNotifications(r.Context(), req.UpTo)
+	renderError(w, err, 500)
+}
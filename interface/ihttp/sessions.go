@@ -0,0 +1,168 @@
+package ihttp
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/auth"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/lib/session"
+)
+
+// sessionResponse describes one of user's active sessions.
+type sessionResponse struct {
+	// ID is a public ID of the session, see session.PublicID.
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	// Current is true for the session the request was made with.
+	Current bool `json:"current"`
+}
+
+// CookiePolicy configures session cookies.
+type CookiePolicy struct {
+	// TTL is a lifetime of session cookies.
+	TTL time.Duration
+	// HTTPOnly hides cookies from scripts.
+	HTTPOnly bool
+	// Secure makes browsers send cookies over HTTPS only.
+	Secure bool
+	// SameSite restricts sending cookies with cross-site requests.
+	SameSite http.SameSite
+}
+
+// cookie returns a session cookie carrying sessID.
+func (p CookiePolicy) cookie(sessID string) *http.Cookie {
+	return &http.Cookie{
+		Name:     cookieSessID,
+		Value:    sessID,
+		Path:     "/",
+		Expires:  time.Now().Add(p.TTL),
+		HttpOnly: p.HTTPOnly,
+		Secure:   p.Secure,
+		SameSite: p.SameSite,
+	}
+}
+
+// expiredCookie returns a cookie that removes the session cookie.
+func (p CookiePolicy) expiredCookie() *http.Cookie {
+	ret := p.cookie("")
+	ret.Expires = time.Unix(0, 0)
+	ret.MaxAge = -1
+	return ret
+}
+
+var errSessionNotFound = bizerr.New("session not found", bizerr.ErrorNotFound)
+
+// sessionMeta describes a client sending the request.
+func sessionMeta(r *http.Request) session.Meta {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return session.Meta{IP: ip, UserAgent: r.UserAgent()}
+}
+
+// currentSession returns an ID of the session the request was made with.
+func currentSession(r *http.Request) string {
+	c, err := r.Cookie(cookieSessID)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
+
+// startSession creates a new session for the user and sets its cookie.
+// The session the request was made with is ended, so that session IDs
+// are never reused across logins or privilege changes (which would allow
+// session fixation).
+func (h Handler) startSession(w http.ResponseWriter, r *http.Request, uid domain.UserID) error {
+	if cur := currentSession(r); cur != "" {
+		err := h.sess.Delete(cur)
+		if err != nil {
+			return err
+		}
+	}
+	sessID, err := h.sess.SaveID(uid, sessionMeta(r))
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, h.cookies.cookie(sessID))
+	return nil
+}
+
+// Logout is a POST request that ends current session and clears its cookie.
+// Tokens the request was made with are revoked.
+func (h Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	err := h.revokeToken(r)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	err = h.sess.Delete(currentSession(r))
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	http.SetCookie(w, h.cookies.expiredCookie())
+}
+
+// Sessions is a GET request that returns a list of sessionResponse,
+// newest first.
+func (h Handler) Sessions(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.UserID(r.Context())
+	if err != nil {
+		renderError(w, err, 403)
+		return
+	}
+	ss, err := h.sess.Sessions(uid)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+
+	cur := currentSession(r)
+	ret := make([]sessionResponse, len(ss))
+	for i, s := range ss {
+		ret[i] = sessionResponse{
+			ID:        session.PublicID(s.ID),
+			CreatedAt: s.CreatedAt,
+			LastSeen:  s.LastSeen,
+			IP:        s.IP,
+			UserAgent: s.UserAgent,
+			Current:   s.ID == cur,
+		}
+	}
+	json.NewEncoder(w).Encode(ret)
+}
+
+// RevokeSession is a DELETE request that ends user's session by its
+// public ID.
+func (h Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	uid, err := auth.UserID(r.Context())
+	if err != nil {
+		renderError(w, err, 403)
+		return
+	}
+	ss, err := h.sess.Sessions(uid)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	for _, s := range ss {
+		if session.PublicID(s.ID) == id {
+			err = h.sess.Delete(s.ID)
+			renderError(w, err, 500)
+			return
+		}
+	}
+	renderError(w, errSessionNotFound, 404)
+}
@@ -0,0 +1,137 @@
+package ihttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+)
+
+const (
+	// streamPingInterval is an interval of keep-alive messages
+	// of idle streams.
+	streamPingInterval = 30 * time.Second
+	// streamWriteTimeout limits writes to WebSocket streams.
+	streamWriteTimeout = 10 * time.Second
+)
+
+// upgrader upgrades stream requests to WebSockets. Its default origin
+// check rejects cross-site requests, which would be authenticated
+// by the session cookie otherwise.
+var upgrader = websocket.Upgrader{}
+
+// Stream is a GET request pushing domain.StreamEvent objects
+// as they happen.
+//
+// It's served as Server-Sent Events: every event has its type
+// ("tweet" or "notification"), ID and JSON-encoded domain.StreamEvent
+// as data. Requests asking for a WebSocket upgrade get the same
+// domain.StreamEvent objects as WebSocket text messages instead.
+//
+// Stream is resumed from the Last-Event-ID header or ?last_event_id
+// URI param, since browsers can't set headers of WebSocket requests.
+func (h Handler) Stream(w http.ResponseWriter, r *http.Request) {
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+
+	if websocket.IsWebSocketUpgrade(r) {
+		h.streamWebSocket(w, r, lastID)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		renderError(w, errors.New("streaming is not supported"), 500)
+		return
+	}
+	stream, err := h.svc.Stream(r.Context(), lastID)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e, ok := <-stream:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				logrus.Error(errors.Wrap(err, "couldn't encode stream event"))
+				return
+			}
+			fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type(), data)
+		case <-ping.C:
+			fmt.Fprint(w, ": ping\n\n")
+		}
+		flusher.Flush()
+	}
+}
+
+// streamWebSocket serves the stream over a WebSocket.
+func (h Handler) streamWebSocket(w http.ResponseWriter, r *http.Request, lastID string) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	stream, err := h.svc.Stream(ctx, lastID)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// upgrader has responded already
+		return
+	}
+	defer conn.Close()
+
+	// client messages are not expected, but reading is needed
+	// to process control frames and notice a closed connection
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(streamPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case e, ok := <-stream:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "stream is lagging behind, resume it"),
+					time.Now().Add(streamWriteTimeout))
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			if err := conn.WriteJSON(e); err != nil {
+				return
+			}
+		case <-ping.C:
+			err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(streamWriteTimeout))
+			if err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
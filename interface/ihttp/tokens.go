@@ -0,0 +1,109 @@
+package ihttp
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/lib/session"
+	"github.com/utrack/woofer/lib/tokens"
+)
+
+// loginModeToken is a Login mode issuing signed tokens instead of
+// a session cookie.
+const loginModeToken = "token"
+
+var errTokensDisabled = bizerr.New("token authentication is disabled", bizerr.ErrorUserInput)
+
+type tokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	// ExpiresIn is a lifetime of the access token in seconds.
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// issueTokens issues a new token pair for the user and renders it.
+func (h Handler) issueTokens(w http.ResponseWriter, uid domain.UserID) {
+	if h.tokens == nil {
+		renderError(w, errTokensDisabled, 400)
+		return
+	}
+	p, err := h.tokens.Issue(uid)
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	renderTokens(w, p)
+}
+
+// renderTokens renders a token pair as tokenResponse.
+func renderTokens(w http.ResponseWriter, p tokens.Pair) {
+	json.NewEncoder(w).Encode(tokenResponse{
+		AccessToken:  p.AccessToken,
+		RefreshToken: p.RefreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(p.AccessTTL.Seconds()),
+	})
+}
+
+// RefreshTokens is a POST form with refresh_token form param.
+// The token pair it belongs to is revoked and a new one is returned
+// as tokenResponse.
+func (h Handler) RefreshTokens(w http.ResponseWriter, r *http.Request) {
+	if h.tokens == nil {
+		renderError(w, errTokensDisabled, 400)
+		return
+	}
+	claims, err := h.tokens.Verify(r.FormValue("refresh_token"), tokens.TypeRefresh)
+	if err != nil {
+		renderError(w, err, 403)
+		return
+	}
+	// refresh tokens are single-use; denying the ID is what claims it,
+	// so concurrent refreshes can't both succeed
+	err = h.sess.DenyToken(claims.Id, h.tokens.Expiry(claims))
+	if err == session.ErrTokenDenied {
+		renderError(w, tokens.ErrInvalid, 403)
+		return
+	}
+	if err != nil {
+		renderError(w, err, 500)
+		return
+	}
+	p, err := h.tokens.Refresh(claims)
+	if err != nil {
+		renderError(w, err, 403)
+		return
+	}
+	renderTokens(w, p)
+}
+
+// JWKS is a GET request that returns public keys verifying signed
+// tokens as tokens.JWKSet.
+func (h Handler) JWKS(w http.ResponseWriter, r *http.Request) {
+	if h.tokens == nil {
+		renderError(w, errTokensDisabled, 404)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.tokens.Keys().JWKS())
+}
+
+// revokeToken revokes the token pair the request was authenticated with,
+// if any.
+func (h Handler) revokeToken(r *http.Request) error {
+	if h.tokens == nil {
+		return nil
+	}
+	claims, err := accessClaims(h.tokens, h.sess, r)
+	if err != nil {
+		return nil
+	}
+	err = h.sess.DenyToken(claims.Id, h.tokens.Expiry(claims))
+	if err == session.ErrTokenDenied {
+		return nil
+	}
+	return err
+}
@@ -7,7 +7,10 @@ import (
 	"github.com/utrack/woofer/lib/bizerr"
 )
 
-const ctxUserIDKey = `auth.userID`
+const (
+	ctxUserIDKey    = `auth.userID`
+	ctxSessionIDKey = `auth.sessionID`
+)
 
 // ErrNoLogin returned if user is not logged in.
 var ErrNoLogin = bizerr.New("No login info for the request", bizerr.ErrorUnauthorized)
@@ -22,6 +25,20 @@ func UserID(ctx context.Context) (domain.UserID, error) {
 }
 
 // SetUserID sets user ID for this request.
+// The request is not bound to a session, see SetSession.
 func SetUserID(ctx context.Context, uid domain.UserID) context.Context {
-	return context.WithValue(ctx, ctxUserIDKey, uid)
+	return SetSession(ctx, uid, "")
+}
+
+// SessionID returns ID of a session the request was authenticated with,
+// or an empty string if it wasn't authenticated by a session.
+func SessionID(ctx context.Context) string {
+	v, _ := ctx.Value(ctxSessionIDKey).(string)
+	return v
+}
+
+// SetSession sets user ID for this request authenticated with the session.
+func SetSession(ctx context.Context, uid domain.UserID, sessID string) context.Context {
+	ctx = context.WithValue(ctx, ctxUserIDKey, uid)
+	return context.WithValue(ctx, ctxSessionIDKey, sessID)
 }
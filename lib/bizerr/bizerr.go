@@ -1,4 +1,5 @@
-/*Package bizerr provides business errors that can signal us which response code
+/*
+Package bizerr provides business errors that can signal us which response code
 should be sent to the user.
 */
 package bizerr
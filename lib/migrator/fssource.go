@@ -0,0 +1,126 @@
+package migrator
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+
+	"github.com/mattes/migrate/source"
+	_ "github.com/mattes/migrate/source/file"
+	"github.com/pkg/errors"
+)
+
+// fsSource is a migration source that reads migrations from an fs.FS.
+type fsSource struct {
+	fsys       fs.FS
+	dir        string
+	migrations *source.Migrations
+}
+
+var _ source.Driver = &fsSource{}
+
+// OpenSource opens migrations stored in the directory on disk if path is
+// not empty; it falls back to the dir of fsys otherwise.
+func OpenSource(path string, fsys fs.FS, dir string) (source.Driver, error) {
+	if path != "" {
+		ret, err := source.Open("file://" + path)
+		return ret, errors.Wrap(err, "couldn't open migrations")
+	}
+	return NewFSSource(fsys, dir)
+}
+
+// NewFSSource creates a migration source reading migration files from
+// the dir of fsys.
+func NewFSSource(fsys fs.FS, dir string) (source.Driver, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read migrations")
+	}
+
+	ms := source.NewMigrations()
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		m, err := source.Parse(e.Name())
+		if err != nil {
+			// not a migration file
+			continue
+		}
+		if !ms.Append(m) {
+			return nil, errors.Errorf("duplicate migration file %v", e.Name())
+		}
+	}
+	return &fsSource{fsys: fsys, dir: dir, migrations: ms}, nil
+}
+
+// Open implements source.Driver.
+// fsSource can't be opened by URL, use NewFSSource instead.
+func (s *fsSource) Open(url string) (source.Driver, error) {
+	return nil, errors.New("fs source can't be opened by URL")
+}
+
+// Close implements source.Driver.
+func (s *fsSource) Close() error {
+	return nil
+}
+
+// First implements source.Driver.
+func (s *fsSource) First() (uint, error) {
+	v, ok := s.migrations.First()
+	if !ok {
+		return 0, s.notExist("first")
+	}
+	return v, nil
+}
+
+// Prev implements source.Driver.
+func (s *fsSource) Prev(version uint) (uint, error) {
+	v, ok := s.migrations.Prev(version)
+	if !ok {
+		return 0, s.notExist(fmt.Sprintf("prev for version %v", version))
+	}
+	return v, nil
+}
+
+// Next implements source.Driver.
+func (s *fsSource) Next(version uint) (uint, error) {
+	v, ok := s.migrations.Next(version)
+	if !ok {
+		return 0, s.notExist(fmt.Sprintf("next for version %v", version))
+	}
+	return v, nil
+}
+
+// ReadUp implements source.Driver.
+func (s *fsSource) ReadUp(version uint) (io.ReadCloser, string, error) {
+	m, ok := s.migrations.Up(version)
+	if !ok {
+		return nil, "", s.notExist(fmt.Sprintf("read version %v", version))
+	}
+	return s.read(m)
+}
+
+// ReadDown implements source.Driver.
+func (s *fsSource) ReadDown(version uint) (io.ReadCloser, string, error) {
+	m, ok := s.migrations.Down(version)
+	if !ok {
+		return nil, "", s.notExist(fmt.Sprintf("read version %v", version))
+	}
+	return s.read(m)
+}
+
+func (s *fsSource) read(m *source.Migration) (io.ReadCloser, string, error) {
+	f, err := s.fsys.Open(path.Join(s.dir, m.Raw))
+	if err != nil {
+		return nil, "", err
+	}
+	return f, m.Identifier, nil
+}
+
+// notExist returns an error that migrate treats as the end of migrations.
+func (s *fsSource) notExist(op string) error {
+	return &os.PathError{Op: op, Path: s.dir, Err: os.ErrNotExist}
+}
@@ -0,0 +1,145 @@
+/*Package migrator manages database schema versions.
+ */
+package migrator
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mattes/migrate"
+	"github.com/mattes/migrate/database"
+	"github.com/mattes/migrate/source"
+	"github.com/pkg/errors"
+)
+
+// ErrOutdated is returned by CheckCurrent if the schema should be migrated
+// before use.
+var ErrOutdated = errors.New("database schema is outdated")
+
+// Migrator applies migrations from a source to a database.
+type Migrator struct {
+	m   *migrate.Migrate
+	src source.Driver
+}
+
+// Status describes a state of the database schema.
+type Status struct {
+	// Version is the current schema version, 0 if no migrations were applied.
+	Version uint
+	// Latest is the latest version available from the migration source.
+	Latest uint
+	// Dirty is true if the last migration has failed.
+	Dirty bool
+}
+
+// Behind returns true if there are migrations that weren't applied yet.
+func (s Status) Behind() bool {
+	return s.Version < s.Latest
+}
+
+func (s Status) String() string {
+	return fmt.Sprintf("version: %v, latest: %v, dirty: %v", s.Version, s.Latest, s.Dirty)
+}
+
+// New creates a Migrator.
+// Closing the Migrator closes the database driver as well.
+func New(src source.Driver, dbName string, dri database.Driver) (*Migrator, error) {
+	m, err := migrate.NewWithInstance("source", src, dbName, dri)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't create migration engine")
+	}
+	return &Migrator{m: m, src: src}, nil
+}
+
+// Up applies all pending migrations.
+func (m *Migrator) Up() error {
+	return noChange(m.m.Up())
+}
+
+// Down rolls back n last migrations.
+func (m *Migrator) Down(n int) error {
+	if n <= 0 {
+		return errors.New("number of migrations to roll back should be positive")
+	}
+	return noChange(m.m.Steps(-n))
+}
+
+// Goto migrates the schema up or down to the version given.
+func (m *Migrator) Goto(version uint) error {
+	return noChange(m.m.Migrate(version))
+}
+
+// Force sets the schema version without running any migrations
+// and resets the dirty flag.
+// Version -1 means that no migrations were applied.
+func (m *Migrator) Force(version int) error {
+	return errors.Wrap(m.m.Force(version), "couldn't force schema version")
+}
+
+// Status returns current schema status.
+func (m *Migrator) Status() (Status, error) {
+	var ret Status
+	var err error
+	ret.Version, ret.Dirty, err = m.m.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return ret, errors.Wrap(err, "couldn't read schema version")
+	}
+
+	ret.Latest, err = m.latest()
+	return ret, err
+}
+
+// CheckCurrent returns an error if the schema is dirty or behind the latest
+// migration.
+func (m *Migrator) CheckCurrent() error {
+	st, err := m.Status()
+	if err != nil {
+		return err
+	}
+	if st.Dirty {
+		return errors.Wrapf(ErrOutdated, "last migration to version %v has failed", st.Version)
+	}
+	if st.Behind() {
+		return errors.Wrapf(ErrOutdated, "version %v is behind the latest version %v", st.Version, st.Latest)
+	}
+	return nil
+}
+
+// Close closes the migration source and the database.
+func (m *Migrator) Close() error {
+	srcErr, dbErr := m.m.Close()
+	if srcErr != nil {
+		return errors.Wrap(srcErr, "couldn't close migration source")
+	}
+	return errors.Wrap(dbErr, "couldn't close database")
+}
+
+// latest returns the latest version available from the source.
+func (m *Migrator) latest() (uint, error) {
+	v, err := m.src.First()
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't read migration source")
+	}
+	for {
+		next, err := m.src.Next(v)
+		if os.IsNotExist(err) {
+			return v, nil
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "couldn't read migration source")
+		}
+		v = next
+	}
+}
+
+// noChange drops migrate.ErrNoChange, since there's nothing wrong
+// with an up-to-date schema.
+func noChange(err error) error {
+	if err == migrate.ErrNoChange {
+		return nil
+	}
+	return errors.Wrap(err, "couldn't run migrations")
+}
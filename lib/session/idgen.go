@@ -0,0 +1,22 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+)
+
+// idBytes is a number of random bytes in generated session IDs.
+const idBytes = 32
+
+// NewID generates a new unpredictable session ID.
+// All the Storage implementations should use it to create session IDs.
+func NewID() (string, error) {
+	b := make([]byte, idBytes)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't generate session ID")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
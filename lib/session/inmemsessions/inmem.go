@@ -1,6 +1,8 @@
 package inmemsessions
 
 import (
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/patrickmn/go-cache"
@@ -11,20 +13,49 @@ import (
 
 // Storage implements session.Storage.
 type Storage struct {
-	ttl time.Duration
-	c   *cache.Cache
-}
+	ttl    time.Duration
+	maxAge time.Duration
+	c      *cache.Cache
+	// denied holds revoked token IDs.
+	denied *cache.Cache
 
-// sessionIDLength is a length of generated session ID.
-const sessionIDLength = 64
+	// byUser indexes session IDs by their users.
+	byUser map[domain.UserID]map[string]struct{}
+	mtx    sync.Mutex
+}
 
 var _ session.Storage = &Storage{}
 
+type record struct {
+	info session.Info
+	// deadline is a time the session expires at regardless of its use.
+	deadline time.Time
+}
+
 // New creates new Storage.
-func New(ttl time.Duration) *Storage {
-	return &Storage{
-		ttl: ttl,
-		c:   cache.New(ttl, ttl*2),
+// Sessions expire if they're not used for ttl, and after maxAge since
+// their creation if it's not zero.
+func New(ttl time.Duration, maxAge time.Duration) *Storage {
+	s := &Storage{
+		ttl:    ttl,
+		maxAge: maxAge,
+		c:      cache.New(ttl, ttl*2),
+		denied: cache.New(cache.NoExpiration, ttl*2),
+		byUser: map[domain.UserID]map[string]struct{}{},
+	}
+	s.c.OnEvicted(s.unindex)
+	return s
+}
+
+// unindex removes an evicted session from the user index.
+func (s *Storage) unindex(sessID string, v interface{}) {
+	uid := v.(record).info.UserID
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	delete(s.byUser[uid], sessID)
+	if len(s.byUser[uid]) == 0 {
+		delete(s.byUser, uid)
 	}
 }
 
@@ -34,17 +65,84 @@ func (s *Storage) IDForSession(sessID string) (domain.UserID, error) {
 	if !ok {
 		return 0, session.ErrNotFound
 	}
-	// renew TTL
-	ret := got.(domain.UserID)
-	s.c.Set(string(sessID), ret, s.ttl)
+	rec := got.(record)
+	ttl := s.ttl
+	if !rec.deadline.IsZero() {
+		left := time.Until(rec.deadline)
+		if left <= 0 {
+			s.c.Delete(sessID)
+			return 0, session.ErrNotFound
+		}
+		if left < ttl {
+			ttl = left
+		}
+	}
+	// renew TTL; Replace fails if the session was deleted meanwhile
+	rec.info.LastSeen = time.Now()
+	if s.c.Replace(sessID, rec, ttl) != nil {
+		return 0, session.ErrNotFound
+	}
 
-	return ret, nil
+	return rec.info.UserID, nil
 }
 
 // SaveID implements session.Storage.
-func (s *Storage) SaveID(uid domain.UserID) (string, error) {
-	sessID := randString(sessionIDLength)
-	return sessID, errors.Wrap(s.c.Add(string(sessID), uid, s.ttl), "error returned from go-cache")
+func (s *Storage) SaveID(uid domain.UserID, meta session.Meta) (string, error) {
+	sessID, err := session.NewID()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	rec := record{info: session.Info{
+		ID:        sessID,
+		UserID:    uid,
+		Meta:      meta,
+		CreatedAt: now,
+		LastSeen:  now,
+	}}
+	if s.maxAge != 0 {
+		rec.deadline = now.Add(s.maxAge)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	err = s.c.Add(sessID, rec, s.ttl)
+	if err != nil {
+		return "", errors.Wrap(err, "error returned from go-cache")
+	}
+	if s.byUser[uid] == nil {
+		s.byUser[uid] = map[string]struct{}{}
+	}
+	s.byUser[uid][sessID] = struct{}{}
+	return sessID, nil
+}
+
+// Sessions implements session.Storage.
+func (s *Storage) Sessions(uid domain.UserID) ([]session.Info, error) {
+	s.mtx.Lock()
+	ids := make([]string, 0, len(s.byUser[uid]))
+	for id := range s.byUser[uid] {
+		ids = append(ids, id)
+	}
+	s.mtx.Unlock()
+
+	now := time.Now()
+	ret := make([]session.Info, 0, len(ids))
+	for _, id := range ids {
+		got, ok := s.c.Get(id)
+		if !ok {
+			continue
+		}
+		rec := got.(record)
+		if !rec.deadline.IsZero() && !rec.deadline.After(now) {
+			continue
+		}
+		ret = append(ret, rec.info)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CreatedAt.After(ret[j].CreatedAt)
+	})
+	return ret, nil
 }
 
 // Delete implements session.Storage.
@@ -52,3 +150,22 @@ func (s *Storage) Delete(sessID string) error {
 	s.c.Delete(sessID)
 	return nil
 }
+
+// DenyToken implements session.Storage.
+func (s *Storage) DenyToken(tokenID string, until time.Time) error {
+	ttl := time.Until(until)
+	if ttl <= 0 {
+		return nil
+	}
+	err := s.denied.Add(tokenID, struct{}{}, ttl)
+	if err != nil {
+		return session.ErrTokenDenied
+	}
+	return nil
+}
+
+// TokenDenied implements session.Storage.
+func (s *Storage) TokenDenied(tokenID string) (bool, error) {
+	_, ok := s.denied.Get(tokenID)
+	return ok, nil
+}
@@ -0,0 +1,304 @@
+// Package redissessions provides session.Storage kept in Redis,
+// which allows several service instances to share sessions.
+//
+// Only GET, SET (with PX and NX), DEL, set commands (SADD, SREM, SMEMBERS)
+// and transactions (WATCH, MULTI, EXEC) are used, so any server speaking
+// the Redis protocol fits.
+package redissessions
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/session"
+)
+
+const (
+	// keyPrefix prefixes keys of all the sessions.
+	keyPrefix = "woofer:session:"
+	// userKeyPrefix prefixes keys of sets of session IDs per user.
+	userKeyPrefix = "woofer:user-sessions:"
+	// deniedKeyPrefix prefixes keys of revoked token IDs.
+	deniedKeyPrefix = "woofer:denied-token:"
+)
+
+// Storage implements session.Storage.
+//
+// Sessions expire if they're not used for ttl (sliding expiry) and after
+// maxAge since their creation (absolute expiry). Expiry is left to Redis,
+// so no purging is needed.
+type Storage struct {
+	c      *redis.Client
+	ttl    time.Duration
+	maxAge time.Duration
+}
+
+var _ session.Storage = &Storage{}
+
+// New creates new Storage connected to the server at url,
+// like redis://:password@localhost:6379/0.
+// maxAge of zero disables absolute expiry.
+func New(url string, ttl time.Duration, maxAge time.Duration) (*Storage, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "bad redis URL")
+	}
+	c := redis.NewClient(opts)
+	err = c.Ping().Err()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't connect to redis")
+	}
+	return &Storage{c: c, ttl: ttl, maxAge: maxAge}, nil
+}
+
+// record is a session as it's stored in Redis.
+type record struct {
+	UserID    domain.UserID `json:"uid"`
+	CreatedAt int64         `json:"created_at"`
+	LastSeen  int64         `json:"last_seen"`
+	IP        string        `json:"ip,omitempty"`
+	UserAgent string        `json:"ua,omitempty"`
+	// Deadline is a time the session expires at regardless of its use,
+	// zero if there's none. It's rounded up to whole seconds, so that
+	// sessions never end before maxAge passes.
+	Deadline int64 `json:"deadline,omitempty"`
+}
+
+func (r record) info(sessID string) session.Info {
+	return session.Info{
+		ID:        sessID,
+		UserID:    r.UserID,
+		Meta:      session.Meta{IP: r.IP, UserAgent: r.UserAgent},
+		CreatedAt: time.Unix(r.CreatedAt, 0),
+		LastSeen:  time.Unix(r.LastSeen, 0),
+	}
+}
+
+func (r record) String() string {
+	ret, _ := json.Marshal(r)
+	return string(ret)
+}
+
+func parseRecord(v string) (record, error) {
+	var ret record
+	err := json.Unmarshal([]byte(v), &ret)
+	return ret, errors.Wrapf(err, "malformed session record %q", v)
+}
+
+// userKey returns a key of the set of user's session IDs.
+func userKey(uid domain.UserID) string {
+	return userKeyPrefix + strconv.FormatUint(uint64(uid), 10)
+}
+
+// expiry returns the TTL to set for the record, which is not positive
+// if the record has expired already.
+func (s *Storage) expiry(r record) time.Duration {
+	ttl := s.ttl
+	if r.Deadline != 0 {
+		left := time.Until(time.Unix(r.Deadline, 0))
+		if left < ttl {
+			ttl = left
+		}
+	}
+	// SET PX accepts whole milliseconds only
+	return ttl.Truncate(time.Millisecond)
+}
+
+// maxRenewAttempts is a max number of attempts to renew a session
+// which is being changed concurrently.
+const maxRenewAttempts = 5
+
+// IDForSession implements session.Storage.
+// It slides the session's expiry, but never past its absolute deadline.
+func (s *Storage) IDForSession(sessID string) (domain.UserID, error) {
+	key := keyPrefix + sessID
+	var ret domain.UserID
+	// the record is read and renewed in a transaction watching the key,
+	// so a session deleted or renewed concurrently is not overwritten
+	renew := func(tx *redis.Tx) error {
+		v, err := tx.Get(key).Result()
+		if err == redis.Nil {
+			return session.ErrNotFound
+		}
+		if err != nil {
+			return errors.Wrap(err, "couldn't get session")
+		}
+		rec, err := parseRecord(v)
+		if err != nil {
+			return err
+		}
+
+		ttl := s.expiry(rec)
+		if ttl <= 0 {
+			return session.ErrNotFound
+		}
+		rec.LastSeen = time.Now().Unix()
+		_, err = tx.TxPipelined(func(p redis.Pipeliner) error {
+			p.Set(key, rec.String(), ttl)
+			return nil
+		})
+		if err == redis.TxFailedErr {
+			return err
+		}
+		if err != nil {
+			return errors.Wrap(err, "couldn't renew session")
+		}
+		ret = rec.UserID
+		return nil
+	}
+
+	for i := 0; i < maxRenewAttempts; i++ {
+		err := s.c.Watch(renew, key)
+		if err != redis.TxFailedErr {
+			return ret, err
+		}
+	}
+	return 0, errors.New("couldn't renew session: it's changed concurrently")
+}
+
+// SaveID implements session.Storage.
+func (s *Storage) SaveID(uid domain.UserID, meta session.Meta) (string, error) {
+	now := time.Now()
+	rec := record{
+		UserID:    uid,
+		CreatedAt: now.Unix(),
+		LastSeen:  now.Unix(),
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+	}
+	if s.maxAge != 0 {
+		rec.Deadline = now.Add(s.maxAge + time.Second - 1).Unix()
+	}
+	// zero TTL would make the session never expire
+	ttl := s.expiry(rec)
+	if ttl <= 0 {
+		return "", errors.Errorf("session TTL %v is too short", s.ttl)
+	}
+
+	sessID, err := session.NewID()
+	if err != nil {
+		return "", err
+	}
+	ok, err := s.c.SetNX(keyPrefix+sessID, rec.String(), ttl).Result()
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't save session")
+	}
+	if !ok {
+		return "", errors.New("session ID collision")
+	}
+	err = s.c.SAdd(userKey(uid), sessID).Err()
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't index session")
+	}
+	return sessID, nil
+}
+
+// Sessions implements session.Storage.
+// IDs of expired sessions are removed from the user's index.
+func (s *Storage) Sessions(uid domain.UserID) ([]session.Info, error) {
+	ids, err := s.c.SMembers(userKey(uid)).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get sessions")
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	p := s.c.Pipeline()
+	cmds := make([]*redis.StringCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = p.Get(keyPrefix + id)
+	}
+	_, err = p.Exec()
+	if err != nil && err != redis.Nil {
+		return nil, errors.Wrap(err, "couldn't get sessions")
+	}
+
+	ret := make([]session.Info, 0, len(ids))
+	var stale []interface{}
+	for i, cmd := range cmds {
+		v, err := cmd.Result()
+		if err == redis.Nil {
+			stale = append(stale, ids[i])
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't get sessions")
+		}
+		rec, err := parseRecord(v)
+		if err != nil {
+			return nil, err
+		}
+		if s.expiry(rec) <= 0 {
+			continue
+		}
+		ret = append(ret, rec.info(ids[i]))
+	}
+	if len(stale) > 0 {
+		err = s.c.SRem(userKey(uid), stale...).Err()
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't unindex expired sessions")
+		}
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].CreatedAt.After(ret[j].CreatedAt)
+	})
+	return ret, nil
+}
+
+// Delete implements session.Storage.
+func (s *Storage) Delete(sessID string) error {
+	key := keyPrefix + sessID
+	v, err := s.c.Get(key).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "couldn't get session")
+	}
+	err = s.c.Del(key).Err()
+	if err != nil {
+		return errors.Wrap(err, "couldn't delete session")
+	}
+
+	rec, err := parseRecord(v)
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(s.c.SRem(userKey(rec.UserID), sessID).Err(), "couldn't unindex session")
+}
+
+// DenyToken implements session.Storage.
+func (s *Storage) DenyToken(tokenID string, until time.Time) error {
+	// round up to whole seconds, the ID must be denied until tokens expire
+	ttl := (time.Until(until) + time.Second - 1).Truncate(time.Second)
+	if ttl <= 0 {
+		return nil
+	}
+	ok, err := s.c.SetNX(deniedKeyPrefix+tokenID, 1, ttl).Result()
+	if err != nil {
+		return errors.Wrap(err, "couldn't deny token")
+	}
+	if !ok {
+		return session.ErrTokenDenied
+	}
+	return nil
+}
+
+// TokenDenied implements session.Storage.
+func (s *Storage) TokenDenied(tokenID string) (bool, error) {
+	err := s.c.Get(deniedKeyPrefix + tokenID).Err()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "couldn't check denied tokens")
+	}
+	return true, nil
+}
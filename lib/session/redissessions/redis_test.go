@@ -0,0 +1,195 @@
+package redissessions
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/utrack/woofer/lib/session"
+)
+
+func newTestStorage(t *testing.T, mr *miniredis.Miniredis) *Storage {
+	s, err := New("redis://"+mr.Addr(), 10*time.Second, time.Minute)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+func TestSessionExpiry(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := newTestStorage(t, mr)
+
+	id, err := s.SaveID(3, session.Meta{IP: "127.0.0.1", UserAgent: "test"})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	uid, err := s.IDForSession(id)
+	if err != nil || uid != 3 {
+		t.Fatalf("IDForSession = %v, %v; want 3", uid, err)
+	}
+
+	// every use slides the expiry
+	for i := 0; i < 3; i++ {
+		mr.FastForward(8 * time.Second)
+		_, err = s.IDForSession(id)
+		if err != nil {
+			t.Fatalf("IDForSession after %v uses: %v", i+1, err)
+		}
+	}
+	if ttl := mr.TTL(keyPrefix + id); ttl != 10*time.Second {
+		t.Errorf("session TTL is %v after its use, want 10s", ttl)
+	}
+
+	id, err = s.SaveID(3, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	mr.FastForward(11 * time.Second)
+	_, err = s.IDForSession(id)
+	if err != session.ErrNotFound {
+		t.Errorf("IDForSession after TTL: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSessionMaxAge(t *testing.T) {
+	mr := miniredis.RunT(t)
+	// the deadline is checked against the local clock, which miniredis
+	// can't fast forward
+	s, err := New("redis://"+mr.Addr(), 10*time.Second, 2*time.Second)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	id, err := s.SaveID(3, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	time.Sleep(1500 * time.Millisecond)
+	uid, err := s.IDForSession(id)
+	if err != nil || uid != 3 {
+		t.Fatalf("IDForSession before max age = %v, %v; want 3", uid, err)
+	}
+	// the deadline is rounded up to whole seconds
+	time.Sleep(1600 * time.Millisecond)
+	_, err = s.IDForSession(id)
+	if err != session.ErrNotFound {
+		t.Errorf("IDForSession after max age: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSaveIDRejectsZeroTTL(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s, err := New("redis://"+mr.Addr(), time.Microsecond, 0)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = s.SaveID(3, session.Meta{})
+	if err == nil {
+		t.Errorf("SaveID saved a session which would never expire")
+	}
+}
+
+func TestDeletedSessionIsNotRenewed(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := newTestStorage(t, mr)
+
+	id, err := s.SaveID(3, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, err := s.IDForSession(id)
+				if err != nil && err != session.ErrNotFound {
+					errs <- err
+				}
+			}
+		}()
+	}
+	err = s.Delete(id)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("IDForSession: %v", err)
+	}
+
+	if mr.Exists(keyPrefix + id) {
+		t.Errorf("deleted session was brought back by a concurrent renewal")
+	}
+	_, err = s.IDForSession(id)
+	if err != session.ErrNotFound {
+		t.Errorf("IDForSession of the deleted session: got %v, want ErrNotFound", err)
+	}
+}
+
+func TestSessions(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := newTestStorage(t, mr)
+
+	first, err := s.SaveID(9, session.Meta{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	// sessions are ordered by their creation time in seconds
+	time.Sleep(1100 * time.Millisecond)
+	second, err := s.SaveID(9, session.Meta{IP: "10.0.0.2"})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+
+	got, err := s.Sessions(9)
+	if err != nil {
+		t.Fatalf("Sessions: %v", err)
+	}
+	if len(got) != 2 || got[0].ID != second || got[1].ID != first || got[1].IP != "10.0.0.1" {
+		t.Errorf("Sessions = %+v, want %v and %v, newest first", got, second, first)
+	}
+
+	// expired sessions are removed from the index
+	mr.FastForward(time.Hour)
+	got, err = s.Sessions(9)
+	if err != nil || len(got) != 0 {
+		t.Errorf("Sessions after expiry = %+v, %v; want none", got, err)
+	}
+	if ids, _ := mr.SMembers(userKey(9)); len(ids) != 0 {
+		t.Errorf("expired sessions %v are still indexed", ids)
+	}
+}
+
+func TestDeniedTokens(t *testing.T) {
+	mr := miniredis.RunT(t)
+	s := newTestStorage(t, mr)
+
+	err := s.DenyToken("token", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("DenyToken: %v", err)
+	}
+	denied, err := s.TokenDenied("token")
+	if err != nil || !denied {
+		t.Errorf("TokenDenied = %v, %v; want true", denied, err)
+	}
+	err = s.DenyToken("token", time.Now().Add(time.Minute))
+	if err != session.ErrTokenDenied {
+		t.Errorf("DenyToken of a denied token = %v, want ErrTokenDenied", err)
+	}
+	mr.FastForward(2 * time.Minute)
+	denied, err = s.TokenDenied("token")
+	if err != nil || denied {
+		t.Errorf("TokenDenied after expiry = %v, %v; want false", denied, err)
+	}
+	err = s.DenyToken("token", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Errorf("DenyToken after expiry: %v", err)
+	}
+}
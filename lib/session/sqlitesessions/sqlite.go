@@ -0,0 +1,207 @@
+// Package sqlitesessions provides session.Storage persisted in a SQLite database.
+//
+// Sessions are kept in the `sessions` table of the service database,
+// which is created by its migrations.
+package sqlitesessions
+
+import (
+	"database/sql"
+	"math"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3" // sqlite3 driver
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/session"
+)
+
+// Storage implements session.Storage.
+//
+// Sessions expire if they're not used for ttl (sliding expiry) and after
+// maxAge since their creation (absolute expiry). Expired sessions are
+// never returned; Purge removes them from the database.
+type Storage struct {
+	db     *sqlx.DB
+	ttl    time.Duration
+	maxAge time.Duration
+}
+
+var _ session.Storage = &Storage{}
+
+// New creates new Storage using the SQLite database at connstring.
+// maxAge of zero disables absolute expiry.
+func New(connstring string, ttl time.Duration, maxAge time.Duration) (*Storage, error) {
+	db, err := sqlx.Connect("sqlite3", connstring)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't init sqlite3 connection")
+	}
+	// sqlite allows single concurrent write op only; the database is
+	// shared with the service, so wait for its locks instead of failing
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`PRAGMA busy_timeout = 5000`)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't set busy timeout")
+	}
+
+	_, err = db.Exec(`SELECT 1 FROM sessions LIMIT 1`)
+	if err != nil {
+		return nil, errors.Wrap(err, "sessions table is unavailable, is the schema up to date?")
+	}
+
+	return &Storage{db: db, ttl: ttl, maxAge: maxAge}, nil
+}
+
+// IDForSession implements session.Storage.
+// It slides the session's expiry, but never past its absolute deadline.
+func (s *Storage) IDForSession(sessID string) (domain.UserID, error) {
+	now := time.Now()
+	res, err := s.db.Exec(`UPDATE sessions
+SET last_seen = ?1, expires_at = MIN(?2, deadline)
+WHERE id = ?3 AND expires_at > ?1`,
+		now.Unix(), now.Add(s.ttl).Unix(), sessID)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't renew session")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't renew session")
+	}
+	if n == 0 {
+		return 0, session.ErrNotFound
+	}
+
+	var ret domain.UserID
+	err = s.db.Get(&ret, `SELECT uid FROM sessions WHERE id = ?`, sessID)
+	if err == sql.ErrNoRows {
+		return 0, session.ErrNotFound
+	}
+	return ret, errors.Wrap(err, "couldn't get session")
+}
+
+// SaveID implements session.Storage.
+func (s *Storage) SaveID(uid domain.UserID, meta session.Meta) (string, error) {
+	now := time.Now()
+	deadline := int64(math.MaxInt64)
+	if s.maxAge != 0 {
+		deadline = now.Add(s.maxAge).Unix()
+	}
+	expires := now.Add(s.ttl).Unix()
+	if expires > deadline {
+		expires = deadline
+	}
+
+	sessID, err := session.NewID()
+	if err != nil {
+		return "", err
+	}
+	_, err = s.db.Exec(`INSERT INTO sessions (id, uid, created_at, last_seen, expires_at, deadline, ip, user_agent)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		sessID, uid, now.Unix(), now.Unix(), expires, deadline, meta.IP, meta.UserAgent)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't save session")
+	}
+	return sessID, nil
+}
+
+// Sessions implements session.Storage.
+func (s *Storage) Sessions(uid domain.UserID) ([]session.Info, error) {
+	var rows []struct {
+		ID        string        `db:"id"`
+		UserID    domain.UserID `db:"uid"`
+		CreatedAt int64         `db:"created_at"`
+		LastSeen  int64         `db:"last_seen"`
+		IP        string        `db:"ip"`
+		UserAgent string        `db:"user_agent"`
+	}
+	err := s.db.Select(&rows, `SELECT id, uid, created_at, last_seen, ip, user_agent
+FROM sessions WHERE uid = ? AND expires_at > ?
+ORDER BY created_at DESC`, uid, time.Now().Unix())
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get sessions")
+	}
+
+	ret := make([]session.Info, len(rows))
+	for i, r := range rows {
+		ret[i] = session.Info{
+			ID:        r.ID,
+			UserID:    r.UserID,
+			Meta:      session.Meta{IP: r.IP, UserAgent: r.UserAgent},
+			CreatedAt: time.Unix(r.CreatedAt, 0),
+			LastSeen:  time.Unix(r.LastSeen, 0),
+		}
+	}
+	return ret, nil
+}
+
+// Delete implements session.Storage.
+func (s *Storage) Delete(sessID string) error {
+	_, err := s.db.Exec(`DELETE FROM sessions WHERE id = ?`, sessID)
+	return errors.Wrap(err, "couldn't delete session")
+}
+
+// DenyToken implements session.Storage.
+func (s *Storage) DenyToken(tokenID string, until time.Time) error {
+	// expired rows may be left until Purge, they're replaced
+	res, err := s.db.Exec(`INSERT INTO denied_tokens (id, expires_at) VALUES (?1, ?2)
+ON CONFLICT (id) DO UPDATE SET expires_at = ?2 WHERE expires_at <= ?3`,
+		tokenID, until.Unix(), time.Now().Unix())
+	if err != nil {
+		return errors.Wrap(err, "couldn't deny token")
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return errors.Wrap(err, "couldn't deny token")
+	}
+	if n == 0 {
+		return session.ErrTokenDenied
+	}
+	return nil
+}
+
+// TokenDenied implements session.Storage.
+func (s *Storage) TokenDenied(tokenID string) (bool, error) {
+	var n int
+	err := s.db.Get(&n, `SELECT COUNT(*) FROM denied_tokens WHERE id = ? AND expires_at > ?`,
+		tokenID, time.Now().Unix())
+	return n > 0, errors.Wrap(err, "couldn't check denied tokens")
+}
+
+// Purge removes expired sessions and denied tokens, returning the number
+// of sessions removed.
+func (s *Storage) Purge() (int64, error) {
+	now := time.Now().Unix()
+	_, err := s.db.Exec(`DELETE FROM denied_tokens WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't purge denied tokens")
+	}
+	res, err := s.db.Exec(`DELETE FROM sessions WHERE expires_at <= ?`, now)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't purge sessions")
+	}
+	n, err := res.RowsAffected()
+	return n, errors.Wrap(err, "couldn't purge sessions")
+}
+
+// PurgeEvery runs Purge every interval until stop is closed.
+// Errors are logged and don't stop the job.
+func (s *Storage) PurgeEvery(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+		}
+		n, err := s.Purge()
+		if err != nil {
+			logrus.Error(err)
+			continue
+		}
+		if n > 0 {
+			logrus.Infof("Purged %v expired sessions", n)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package sqlitesessions
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mattes/migrate/database/sqlite3"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/migrator"
+	"github.com/utrack/woofer/lib/session"
+	"github.com/utrack/woofer/migrations"
+)
+
+func newTestStorage(t *testing.T, ttl time.Duration, maxAge time.Duration) *Storage {
+	path := filepath.Join(t.TempDir(), "db.sqlite")
+
+	_, err := New(path, ttl, maxAge)
+	if err == nil {
+		t.Fatal("storage should refuse a database without schema")
+	}
+
+	db, err := sqlx.Connect("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dri, err := sqlite3.WithInstance(db.DB, &sqlite3.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := migrator.NewFSSource(migrations.FS, "sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := migrator.New(src, "sqlite", dri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := New(path, ttl, maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// age moves all stored times d back, as if d has passed.
+func age(t *testing.T, s *Storage, d time.Duration) {
+	t.Helper()
+	sec := int64(d / time.Second)
+	_, err := s.db.Exec(`UPDATE sessions SET created_at = created_at - ?1,
+last_seen = last_seen - ?1, expires_at = expires_at - ?1, deadline = deadline - ?1`, sec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.db.Exec(`UPDATE denied_tokens SET expires_at = expires_at - ?`, sec)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectSession(t *testing.T, s *Storage, sessID string, want domain.UserID) {
+	t.Helper()
+	uid, err := s.IDForSession(sessID)
+	if want == 0 {
+		if err != session.ErrNotFound {
+			t.Errorf("IDForSession = %v, %v; want ErrNotFound", uid, err)
+		}
+		return
+	}
+	if err != nil || uid != want {
+		t.Errorf("IDForSession = %v, %v; want %v", uid, err, want)
+	}
+}
+
+func TestSlidingExpiry(t *testing.T) {
+	s := newTestStorage(t, time.Hour, 0)
+	id, err := s.SaveID(1, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+
+	age(t, s, 50*time.Minute)
+	expectSession(t, s, id, 1)
+	// the session was renewed for another hour
+	age(t, s, 50*time.Minute)
+	expectSession(t, s, id, 1)
+	age(t, s, 61*time.Minute)
+	expectSession(t, s, id, 0)
+}
+
+func TestSessionMaxAge(t *testing.T) {
+	s := newTestStorage(t, time.Hour, 90*time.Minute)
+	id, err := s.SaveID(1, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+
+	age(t, s, 59*time.Minute)
+	expectSession(t, s, id, 1)
+	var row struct {
+		ExpiresAt int64 `db:"expires_at"`
+		Deadline  int64 `db:"deadline"`
+	}
+	err = s.db.Get(&row, `SELECT expires_at, deadline FROM sessions WHERE id = ?`, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if row.ExpiresAt != row.Deadline {
+		t.Errorf("session renewed until %v, past its deadline %v", row.ExpiresAt, row.Deadline)
+	}
+
+	age(t, s, 30*time.Minute)
+	expectSession(t, s, id, 1)
+	age(t, s, 2*time.Minute)
+	expectSession(t, s, id, 0)
+}
+
+func TestSessionsOrder(t *testing.T) {
+	s := newTestStorage(t, time.Hour, 0)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		id, err := s.SaveID(1, session.Meta{IP: "127.0.0.1", UserAgent: "test"})
+		if err != nil {
+			t.Fatalf("SaveID: %v", err)
+		}
+		ids = append(ids, id)
+		age(t, s, time.Minute)
+	}
+	_, err := s.SaveID(2, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	expired, err := s.SaveID(1, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	_, err = s.db.Exec(`UPDATE sessions SET expires_at = ? WHERE id = ?`, time.Now().Unix(), expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.Sessions(1)
+	if err != nil {
+		t.Fatalf("Sessions: %v", err)
+	}
+	if len(got) != 3 || got[0].ID != ids[2] || got[1].ID != ids[1] || got[2].ID != ids[0] {
+		t.Fatalf("Sessions = %+v, want sessions %v newest first", got, ids)
+	}
+	if got[0].UserID != 1 || got[0].IP != "127.0.0.1" || got[0].UserAgent != "test" {
+		t.Errorf("Sessions returned %+v", got[0])
+	}
+}
+
+func TestPurge(t *testing.T) {
+	s := newTestStorage(t, time.Hour, 0)
+	old, err := s.SaveID(1, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	err = s.DenyToken("old", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("DenyToken: %v", err)
+	}
+	age(t, s, 2*time.Hour)
+	id, err := s.SaveID(1, session.Meta{})
+	if err != nil {
+		t.Fatalf("SaveID: %v", err)
+	}
+	err = s.DenyToken("new", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("DenyToken: %v", err)
+	}
+
+	n, err := s.Purge()
+	if err != nil || n != 1 {
+		t.Errorf("Purge = %v, %v; want 1 session purged", n, err)
+	}
+	var ids []string
+	err = s.db.Select(&ids, `SELECT id FROM sessions`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != id {
+		t.Errorf("sessions %v were left, want %v; %v is expired", ids, id, old)
+	}
+	err = s.db.Select(&ids, `SELECT id FROM denied_tokens`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 1 || ids[0] != "new" {
+		t.Errorf("denied tokens %v were left, want new", ids)
+	}
+}
+
+func TestDeniedTokens(t *testing.T) {
+	s := newTestStorage(t, time.Hour, 0)
+
+	err := s.DenyToken("token", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatalf("DenyToken: %v", err)
+	}
+	denied, err := s.TokenDenied("token")
+	if err != nil || !denied {
+		t.Errorf("TokenDenied = %v, %v; want true", denied, err)
+	}
+	err = s.DenyToken("token", time.Now().Add(time.Minute))
+	if err != session.ErrTokenDenied {
+		t.Errorf("DenyToken of a denied token = %v, want ErrTokenDenied", err)
+	}
+
+	age(t, s, 2*time.Minute)
+	denied, err = s.TokenDenied("token")
+	if err != nil || denied {
+		t.Errorf("TokenDenied after expiry = %v, %v; want false", denied, err)
+	}
+	// the expired row isn't purged yet, but the token can be denied again
+	err = s.DenyToken("token", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Errorf("DenyToken after expiry: %v", err)
+	}
+	denied, err = s.TokenDenied("token")
+	if err != nil || !denied {
+		t.Errorf("TokenDenied = %v, %v; want true", denied, err)
+	}
+}
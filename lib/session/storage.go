@@ -1,7 +1,10 @@
 package session
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"time"
 
 	"github.com/utrack/woofer/domain"
 )
@@ -9,13 +12,50 @@ import (
 // Storage stores info about current user logins (pairing session and user ids together).
 type Storage interface {
 	// IDForSession retrieves user ID for given session ID.
-	// It renews any TTLs for the record if it was found.
+	// It renews any TTLs for the record if it was found, updating its
+	// last-seen time.
 	IDForSession(sessID string) (domain.UserID, error)
 	// SaveID creates a new session and assigns userID to it.
 	// It generates a unique session ID internally.
-	SaveID(uid domain.UserID) (string, error)
+	SaveID(uid domain.UserID, meta Meta) (string, error)
+	// Sessions returns active sessions of the user, newest first.
+	Sessions(uid domain.UserID) ([]Info, error)
 	Delete(sessID string) error
+
+	// DenyToken revokes a signed token ID until its tokens expire
+	// on their own. It returns ErrTokenDenied if the ID is revoked
+	// already, so that a single-use token can be used only once.
+	DenyToken(tokenID string, until time.Time) error
+	// TokenDenied checks if a token ID was revoked.
+	TokenDenied(tokenID string) (bool, error)
+}
+
+// Meta describes a client that has created a session.
+type Meta struct {
+	IP        string
+	UserAgent string
+}
+
+// Info describes an active session.
+type Info struct {
+	// ID is a secret session ID; see PublicID for the one that can be shown.
+	ID     string
+	UserID domain.UserID
+	Meta
+	CreatedAt time.Time
+	LastSeen  time.Time
+}
+
+// PublicID returns an identifier of a session that can be shown to its
+// user without disclosing the session ID itself.
+func PublicID(sessID string) string {
+	h := sha256.Sum256([]byte(sessID))
+	return hex.EncodeToString(h[:16])
 }
 
 // ErrNotFound is returned by Storage if session was not found by sessID requested.
 var ErrNotFound = errors.New("session not found by key")
+
+// ErrTokenDenied is returned by Storage.DenyToken if the token ID was
+// revoked already.
+var ErrTokenDenied = errors.New("token is denied already")
@@ -0,0 +1,204 @@
+package tokens
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Keyset holds ES256 signing keys loaded from a keyset file.
+// The first key of the file signs new tokens; all of them are used to
+// verify tokens, so that tokens signed before a rotation stay valid.
+type Keyset struct {
+	path string
+
+	keys []key
+	mtx  sync.RWMutex
+}
+
+type key struct {
+	id   string
+	priv *ecdsa.PrivateKey
+}
+
+// keysetFile is a format of the keyset file.
+type keysetFile struct {
+	Keys []keyFile `json:"keys"`
+}
+
+type keyFile struct {
+	ID string `json:"kid"`
+	// PEM is a PEM-encoded EC private key.
+	PEM string `json:"pem"`
+}
+
+// LoadKeyset loads the keyset file at path.
+func LoadKeyset(path string) (*Keyset, error) {
+	ret := &Keyset{path: path}
+	return ret, ret.Reload()
+}
+
+// Reload reloads keys from the keyset file.
+// Current keys stay in use if the file is broken.
+func (k *Keyset) Reload() error {
+	f, err := readKeyset(k.path)
+	if err != nil {
+		return err
+	}
+	if len(f.Keys) == 0 {
+		return errors.Errorf("keyset %v is empty, use 'woofer rotate-keys' to create a key", k.path)
+	}
+
+	keys := make([]key, len(f.Keys))
+	for i, kf := range f.Keys {
+		block, _ := pem.Decode([]byte(kf.PEM))
+		if block == nil {
+			return errors.Errorf("key %q is not PEM-encoded", kf.ID)
+		}
+		priv, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return errors.Wrapf(err, "couldn't parse key %q", kf.ID)
+		}
+		if priv.Curve != elliptic.P256() {
+			return errors.Errorf("key %q is not a P-256 key", kf.ID)
+		}
+		keys[i] = key{id: kf.ID, priv: priv}
+	}
+
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	k.keys = keys
+	return nil
+}
+
+// signing returns a key to sign new tokens with.
+func (k *Keyset) signing() key {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	return k.keys[0]
+}
+
+// public returns a public key by its ID.
+func (k *Keyset) public(id string) (*ecdsa.PublicKey, bool) {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+	for _, key := range k.keys {
+		if key.id == id {
+			return &key.priv.PublicKey, true
+		}
+	}
+	return nil, false
+}
+
+// JWK is a public key in JSON Web Key format.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+	ID        string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+}
+
+// JWKSet is a set of public keys in JSON Web Key Set format.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns public keys of the keyset.
+func (k *Keyset) JWKS() JWKSet {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	ret := JWKSet{Keys: make([]JWK, len(k.keys))}
+	for i, key := range k.keys {
+		ret.Keys[i] = JWK{
+			KeyType:   "EC",
+			Curve:     "P-256",
+			X:         encodeCoord(key.priv.X),
+			Y:         encodeCoord(key.priv.Y),
+			ID:        key.id,
+			Use:       "sig",
+			Algorithm: "ES256",
+		}
+	}
+	return ret
+}
+
+// encodeCoord encodes a P-256 curve point coordinate as JWK requires.
+func encodeCoord(c *big.Int) string {
+	b := make([]byte, 32)
+	c.FillBytes(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// RotateKeyset adds a new signing key to the keyset file at path,
+// creating the file if it doesn't exist. Only keep most recent keys
+// (including the new one) are kept.
+// Running servers pick up the new key on Reload.
+func RotateKeyset(path string, keep int) error {
+	if keep < 1 {
+		return errors.New("at least one key should be kept")
+	}
+	f, err := readKeyset(path)
+	if err != nil && !os.IsNotExist(errors.Cause(err)) {
+		return err
+	}
+
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return errors.Wrap(err, "couldn't generate key")
+	}
+	der, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return errors.Wrap(err, "couldn't encode key")
+	}
+	id := make([]byte, 8)
+	_, err = rand.Read(id)
+	if err != nil {
+		return errors.Wrap(err, "couldn't generate key ID")
+	}
+
+	f.Keys = append([]keyFile{{
+		ID:  hex.EncodeToString(id),
+		PEM: string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+	}}, f.Keys...)
+	if len(f.Keys) > keep {
+		f.Keys = f.Keys[:keep]
+	}
+
+	buf, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "couldn't encode keyset")
+	}
+	// write the new file next to the old one and swap them, so that
+	// servers never read a partially written keyset
+	tmp := path + ".new"
+	err = ioutil.WriteFile(tmp, buf, 0600)
+	if err != nil {
+		return errors.Wrap(err, "couldn't write keyset")
+	}
+	return errors.Wrap(os.Rename(tmp, path), "couldn't replace keyset")
+}
+
+func readKeyset(path string) (keysetFile, error) {
+	var ret keysetFile
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ret, errors.Wrap(err, "couldn't read keyset")
+	}
+	err = json.Unmarshal(buf, &ret)
+	return ret, errors.Wrapf(err, "couldn't parse keyset %v", path)
+}
@@ -0,0 +1,176 @@
+// Package tokens issues and verifies signed JWT access and refresh tokens.
+//
+// Tokens of a pair share their ID, so that revoking the ID revokes both.
+// Revocation itself is up to the caller; see session.Storage.DenyToken.
+package tokens
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strconv"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+)
+
+// Token types.
+const (
+	TypeAccess  = "access"
+	TypeRefresh = "refresh"
+)
+
+// ErrInvalid is returned if a token is malformed, expired, signed with an
+// unknown key or has unexpected type.
+var ErrInvalid = bizerr.New("invalid token", bizerr.ErrorUnauthorized)
+
+// Claims are claims of woofer's tokens.
+type Claims struct {
+	jwt.StandardClaims
+	Type string `json:"typ"`
+	// AuthTime is a time of the login the token pair descends from,
+	// kept through refreshes, in Unix seconds.
+	AuthTime int64 `json:"auth_time"`
+}
+
+// UserID returns an ID of the user the token was issued to.
+func (c Claims) UserID() (domain.UserID, error) {
+	ret, err := strconv.ParseUint(c.Subject, 10, 64)
+	return domain.UserID(ret), errors.Wrap(err, "malformed token subject")
+}
+
+// Pair is a pair of tokens issued on login.
+type Pair struct {
+	// ID is a token ID shared by both tokens.
+	ID           string
+	AccessToken  string
+	RefreshToken string
+	// AccessTTL is a lifetime of the access token.
+	AccessTTL time.Duration
+	// Expires is an expiration time of the refresh token, after which
+	// the pair can't be used at all.
+	Expires time.Time
+}
+
+// Issuer issues and verifies tokens.
+type Issuer struct {
+	keys       *Keyset
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	maxAge     time.Duration
+}
+
+// NewIssuer creates new Issuer signing tokens with the keyset.
+// Access tokens expire after accessTTL, refresh tokens after refreshTTL,
+// but no later than maxAge since login, so that refreshing can't prolong
+// a login forever. maxAge of zero disables the limit.
+func NewIssuer(keys *Keyset, accessTTL time.Duration, refreshTTL time.Duration, maxAge time.Duration) *Issuer {
+	return &Issuer{keys: keys, accessTTL: accessTTL, refreshTTL: refreshTTL, maxAge: maxAge}
+}
+
+// Keys returns the keyset of the issuer.
+func (i *Issuer) Keys() *Keyset {
+	return i.keys
+}
+
+// Issue issues a new token pair for the user on login.
+func (i *Issuer) Issue(uid domain.UserID) (Pair, error) {
+	now := time.Now()
+	return i.issue(uid, now, now.Unix())
+}
+
+// Refresh issues a new token pair replacing the one of the refresh token
+// claims. It returns ErrInvalid if the login the pair descends from
+// is older than maxAge.
+func (i *Issuer) Refresh(c Claims) (Pair, error) {
+	uid, err := c.UserID()
+	if err != nil {
+		return Pair{}, bizerr.Wrap(err, bizerr.ErrorUnauthorized)
+	}
+	now := time.Now()
+	if i.maxAge != 0 && !now.Before(time.Unix(c.AuthTime, 0).Add(i.maxAge)) {
+		return Pair{}, ErrInvalid
+	}
+	return i.issue(uid, now, c.AuthTime)
+}
+
+func (i *Issuer) issue(uid domain.UserID, now time.Time, authTime int64) (Pair, error) {
+	id := make([]byte, 16)
+	_, err := rand.Read(id)
+	if err != nil {
+		return Pair{}, errors.Wrap(err, "couldn't generate token ID")
+	}
+	ret := Pair{
+		ID:        base64.RawURLEncoding.EncodeToString(id),
+		AccessTTL: i.accessTTL,
+	}
+	claims := Claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:       ret.ID,
+			Subject:  strconv.FormatUint(uint64(uid), 10),
+			IssuedAt: now.Unix(),
+		},
+		AuthTime: authTime,
+	}
+	ret.Expires = i.Expiry(claims)
+	if until := ret.Expires.Sub(now); until < ret.AccessTTL {
+		ret.AccessTTL = until
+	}
+
+	ret.AccessToken, err = i.sign(claims, TypeAccess, now.Add(ret.AccessTTL))
+	if err != nil {
+		return Pair{}, err
+	}
+	ret.RefreshToken, err = i.sign(claims, TypeRefresh, ret.Expires)
+	if err != nil {
+		return Pair{}, err
+	}
+	return ret, nil
+}
+
+func (i *Issuer) sign(c Claims, typ string, exp time.Time) (string, error) {
+	k := i.keys.signing()
+	c.ExpiresAt = exp.Unix()
+	c.Type = typ
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, c)
+	t.Header["kid"] = k.id
+	ret, err := t.SignedString(k.priv)
+	return ret, errors.Wrap(err, "couldn't sign token")
+}
+
+// Expiry returns a time when the whole pair the token belongs to expires.
+func (i *Issuer) Expiry(c Claims) time.Time {
+	ret := time.Unix(c.IssuedAt, 0).Add(i.refreshTTL)
+	if i.maxAge == 0 {
+		return ret
+	}
+	deadline := time.Unix(c.AuthTime, 0).Add(i.maxAge)
+	if deadline.Before(ret) {
+		return deadline
+	}
+	return ret
+}
+
+// Verify checks token's signature and expiry and returns its claims.
+// It returns ErrInvalid if the token is not valid or its type is not typ.
+func (i *Issuer) Verify(token string, typ string) (Claims, error) {
+	var ret Claims
+	p := jwt.Parser{ValidMethods: []string{jwt.SigningMethodES256.Alg()}}
+	_, err := p.ParseWithClaims(token, &ret, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		pub, ok := i.keys.public(kid)
+		if !ok {
+			return nil, errors.Errorf("unknown key %q", kid)
+		}
+		return pub, nil
+	})
+	if err != nil {
+		return ret, bizerr.Wrap(errors.Wrap(err, ErrInvalid.Error()), bizerr.ErrorUnauthorized)
+	}
+	if ret.Type != typ || ret.Id == "" {
+		return ret, ErrInvalid
+	}
+	return ret, nil
+}
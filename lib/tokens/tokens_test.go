@@ -0,0 +1,57 @@
+package tokens
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRefreshKeepsLoginTime(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keyset.json")
+	err := RotateKeyset(path, 1)
+	if err != nil {
+		t.Fatalf("RotateKeyset: %v", err)
+	}
+	keys, err := LoadKeyset(path)
+	if err != nil {
+		t.Fatalf("LoadKeyset: %v", err)
+	}
+	iss := NewIssuer(keys, time.Minute, time.Hour, 2*time.Hour)
+
+	pair, err := iss.Issue(1)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	claims, err := iss.Verify(pair.RefreshToken, TypeRefresh)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	refreshed, err := iss.Refresh(claims)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	got, err := iss.Verify(refreshed.RefreshToken, TypeRefresh)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.AuthTime != claims.AuthTime {
+		t.Errorf("refreshed token has login time %v, want %v", got.AuthTime, claims.AuthTime)
+	}
+
+	// the pair can't outlive the login's max age
+	claims.AuthTime = time.Now().Add(-90 * time.Minute).Unix()
+	refreshed, err = iss.Refresh(claims)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	want := time.Unix(claims.AuthTime, 0).Add(2 * time.Hour)
+	if !refreshed.Expires.Equal(want) {
+		t.Errorf("refreshed pair expires at %v, want %v", refreshed.Expires, want)
+	}
+
+	claims.AuthTime = time.Now().Add(-2 * time.Hour).Unix()
+	_, err = iss.Refresh(claims)
+	if err != ErrInvalid {
+		t.Errorf("Refresh after max age = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,11 @@
+/*Package migrations embeds database schema migrations into the binary.
+Every storage backend has its migrations in its own directory.
+*/
+package migrations
+
+import "embed"
+
+// FS contains migrations for all storage backends.
+//
+//go:embed sqlite/*.sql postgres/*.sql
+var FS embed.FS
@@ -0,0 +1,9 @@
+CREATE TABLE users ( id BIGSERIAL PRIMARY KEY, name TEXT NOT NULL, nickname TEXT NOT NULL UNIQUE, password BYTEA NOT NULL );
+
+CREATE TABLE subs ( sfrom BIGINT NOT NULL, sto BIGINT NOT NULL, PRIMARY KEY(sfrom,sto) );
+
+CREATE TABLE tweets ( id BIGSERIAL PRIMARY KEY, uid BIGINT NOT NULL, created_at TIMESTAMPTZ NOT NULL, text TEXT NOT NULL );
+
+CREATE INDEX idx_tweets_uid ON tweets ( uid );
+
+CREATE INDEX idx_subs_sto ON subs ( sto );
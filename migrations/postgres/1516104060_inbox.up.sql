@@ -0,0 +1 @@
+CREATE TABLE inbox ( uid BIGINT NOT NULL, tid BIGINT NOT NULL, PRIMARY KEY(uid,tid) );
@@ -0,0 +1,3 @@
+DROP INDEX idx_tweets_reply_to;
+
+ALTER TABLE tweets DROP COLUMN reply_to;
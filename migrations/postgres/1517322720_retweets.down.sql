@@ -0,0 +1,3 @@
+DROP INDEX idx_tweets_retweet;
+
+ALTER TABLE tweets DROP COLUMN retweet_of;
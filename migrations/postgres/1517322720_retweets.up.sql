@@ -0,0 +1,3 @@
+ALTER TABLE tweets ADD COLUMN retweet_of BIGINT NOT NULL DEFAULT 0;
+
+CREATE UNIQUE INDEX idx_tweets_retweet ON tweets ( uid, retweet_of ) WHERE retweet_of != 0 AND text = '';
@@ -0,0 +1,3 @@
+CREATE TABLE likes ( uid BIGINT NOT NULL, tid BIGINT NOT NULL, created_at TIMESTAMPTZ NOT NULL, PRIMARY KEY(uid,tid) );
+
+CREATE INDEX idx_likes_tid ON likes ( tid );
@@ -0,0 +1,7 @@
+DROP TABLE tweet_revisions;
+
+DROP INDEX idx_tweets_retweet;
+CREATE UNIQUE INDEX idx_tweets_retweet ON tweets ( uid, retweet_of ) WHERE retweet_of != 0 AND text = '';
+
+ALTER TABLE tweets DROP COLUMN edited_at;
+ALTER TABLE tweets DROP COLUMN deleted;
@@ -0,0 +1,9 @@
+ALTER TABLE tweets ADD COLUMN deleted SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE tweets ADD COLUMN edited_at TIMESTAMPTZ;
+
+DROP INDEX idx_tweets_retweet;
+CREATE UNIQUE INDEX idx_tweets_retweet ON tweets ( uid, retweet_of ) WHERE retweet_of != 0 AND text = '' AND deleted = 0;
+
+CREATE TABLE tweet_revisions ( id BIGSERIAL PRIMARY KEY, tid BIGINT NOT NULL, created_at TIMESTAMPTZ NOT NULL, text TEXT NOT NULL );
+
+CREATE INDEX idx_tweet_revisions_tid ON tweet_revisions ( tid );
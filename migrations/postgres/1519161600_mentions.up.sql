@@ -0,0 +1,3 @@
+CREATE TABLE mentions ( tid BIGINT NOT NULL, uid BIGINT NOT NULL, start_pos INT NOT NULL, end_pos INT NOT NULL, PRIMARY KEY(tid,start_pos) );
+
+CREATE INDEX idx_mentions_uid ON mentions ( uid, tid );
@@ -0,0 +1,3 @@
+CREATE TABLE hashtags ( tag TEXT NOT NULL, tid BIGINT NOT NULL, PRIMARY KEY(tag,tid) );
+
+CREATE INDEX idx_hashtags_tid ON hashtags ( tid );
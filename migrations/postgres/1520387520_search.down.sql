@@ -0,0 +1 @@
+DROP INDEX idx_tweets_fts;
@@ -0,0 +1 @@
+CREATE INDEX idx_tweets_fts ON tweets USING GIN ( to_tsvector('simple', text) );
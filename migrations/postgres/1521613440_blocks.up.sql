@@ -0,0 +1,5 @@
+CREATE TABLE blocks ( uid BIGINT NOT NULL, blocked BIGINT NOT NULL, PRIMARY KEY(uid,blocked) );
+
+CREATE INDEX idx_blocks_blocked ON blocks ( blocked );
+
+CREATE TABLE mutes ( uid BIGINT NOT NULL, muted BIGINT NOT NULL, PRIMARY KEY(uid,muted) );
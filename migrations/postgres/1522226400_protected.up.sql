@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN protected BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE follow_requests ( sfrom BIGINT NOT NULL, sto BIGINT NOT NULL, created_at TIMESTAMPTZ NOT NULL, PRIMARY KEY(sto,sfrom) );
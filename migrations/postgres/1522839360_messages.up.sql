@@ -0,0 +1,9 @@
+CREATE TABLE conversations ( id BIGSERIAL PRIMARY KEY, created_at TIMESTAMPTZ NOT NULL, last_message BIGINT NOT NULL DEFAULT 0 );
+
+CREATE TABLE conversation_members ( cid BIGINT NOT NULL, uid BIGINT NOT NULL, last_read BIGINT NOT NULL DEFAULT 0, PRIMARY KEY(cid,uid) );
+
+CREATE INDEX idx_conversation_members_uid ON conversation_members ( uid, cid );
+
+CREATE TABLE messages ( id BIGSERIAL PRIMARY KEY, cid BIGINT NOT NULL, uid BIGINT NOT NULL, created_at TIMESTAMPTZ NOT NULL, text TEXT NOT NULL );
+
+CREATE INDEX idx_messages_cid ON messages ( cid, id );
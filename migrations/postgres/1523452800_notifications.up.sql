@@ -0,0 +1,5 @@
+CREATE TABLE notifications ( id BIGSERIAL PRIMARY KEY, uid BIGINT NOT NULL, kind TEXT NOT NULL, from_uid BIGINT NOT NULL, tid BIGINT NOT NULL DEFAULT 0, created_at TIMESTAMPTZ NOT NULL, read BOOLEAN NOT NULL DEFAULT FALSE );
+
+CREATE UNIQUE INDEX idx_notifications_unique ON notifications ( uid, kind, from_uid, tid );
+
+CREATE INDEX idx_notifications_uid ON notifications ( uid, id );
@@ -0,0 +1 @@
+ALTER TABLE tweets ADD COLUMN pulled BOOLEAN NOT NULL DEFAULT FALSE;
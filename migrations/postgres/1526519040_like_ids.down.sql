@@ -0,0 +1,3 @@
+DROP INDEX idx_likes_uid;
+
+ALTER TABLE likes DROP COLUMN id;
@@ -0,0 +1,13 @@
+CREATE SEQUENCE likes_id_seq;
+
+ALTER TABLE likes ADD COLUMN id BIGINT;
+
+UPDATE likes SET id = o.n FROM ( SELECT uid, tid, row_number() OVER (ORDER BY created_at, tid) AS n FROM likes ) o WHERE likes.uid = o.uid AND likes.tid = o.tid;
+
+SELECT setval('likes_id_seq', COALESCE(MAX(id), 0) + 1, false) FROM likes;
+
+ALTER TABLE likes ALTER COLUMN id SET DEFAULT nextval('likes_id_seq'), ALTER COLUMN id SET NOT NULL;
+
+ALTER SEQUENCE likes_id_seq OWNED BY likes.id;
+
+CREATE UNIQUE INDEX idx_likes_uid ON likes ( uid, id );
@@ -0,0 +1,5 @@
+DROP TABLE `tweets`;
+
+DROP TABLE `subs`;
+
+DROP TABLE "users";
@@ -0,0 +1,3 @@
+DROP INDEX `idx_subs_sto`;
+
+DROP TABLE `inbox`;
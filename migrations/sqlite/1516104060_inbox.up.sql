@@ -0,0 +1,3 @@
+CREATE TABLE `inbox` ( `uid` INTEGER NOT NULL, `tid` INTEGER NOT NULL, PRIMARY KEY(`uid`,`tid`) ) WITHOUT ROWID;
+
+CREATE INDEX `idx_subs_sto` ON `subs` ( `sto` );
@@ -0,0 +1,3 @@
+ALTER TABLE `tweets` ADD COLUMN `reply_to` INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX `idx_tweets_reply_to` ON `tweets` ( `reply_to` );
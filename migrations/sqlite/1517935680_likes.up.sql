@@ -0,0 +1,3 @@
+CREATE TABLE `likes` ( `uid` INTEGER NOT NULL, `tid` INTEGER NOT NULL, `created_at` timestamp NOT NULL, PRIMARY KEY(`uid`,`tid`) ) WITHOUT ROWID;
+
+CREATE INDEX `idx_likes_tid` ON `likes` ( `tid` );
@@ -0,0 +1,3 @@
+CREATE TABLE `mentions` ( `tid` INTEGER NOT NULL, `uid` INTEGER NOT NULL, `start_pos` INTEGER NOT NULL, `end_pos` INTEGER NOT NULL, PRIMARY KEY(`tid`,`start_pos`) ) WITHOUT ROWID;
+
+CREATE INDEX `idx_mentions_uid` ON `mentions` ( `uid`, `tid` );
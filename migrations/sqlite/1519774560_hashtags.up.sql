@@ -0,0 +1,3 @@
+CREATE TABLE `hashtags` ( `tag` TEXT NOT NULL, `tid` INTEGER NOT NULL, PRIMARY KEY(`tag`,`tid`) ) WITHOUT ROWID;
+
+CREATE INDEX `idx_hashtags_tid` ON `hashtags` ( `tid` );
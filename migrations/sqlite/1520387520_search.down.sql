@@ -0,0 +1,5 @@
+DROP TRIGGER `tweets_fts_update`;
+DROP TRIGGER `tweets_fts_delete`;
+DROP TRIGGER `tweets_fts_insert`;
+
+DROP TABLE `tweets_fts`;
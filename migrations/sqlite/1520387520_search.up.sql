@@ -0,0 +1,16 @@
+CREATE VIRTUAL TABLE `tweets_fts` USING fts5( `text`, content='tweets', content_rowid='id' );
+
+INSERT INTO `tweets_fts` ( `rowid`, `text` ) SELECT `id`, `text` FROM `tweets`;
+
+CREATE TRIGGER `tweets_fts_insert` AFTER INSERT ON `tweets` BEGIN
+  INSERT INTO `tweets_fts` ( `rowid`, `text` ) VALUES ( new.`id`, new.`text` );
+END;
+
+CREATE TRIGGER `tweets_fts_delete` AFTER DELETE ON `tweets` BEGIN
+  INSERT INTO `tweets_fts` ( `tweets_fts`, `rowid`, `text` ) VALUES ( 'delete', old.`id`, old.`text` );
+END;
+
+CREATE TRIGGER `tweets_fts_update` AFTER UPDATE OF `text` ON `tweets` BEGIN
+  INSERT INTO `tweets_fts` ( `tweets_fts`, `rowid`, `text` ) VALUES ( 'delete', old.`id`, old.`text` );
+  INSERT INTO `tweets_fts` ( `rowid`, `text` ) VALUES ( new.`id`, new.`text` );
+END;
@@ -0,0 +1,5 @@
+CREATE TABLE `blocks` ( `uid` INTEGER NOT NULL, `blocked` INTEGER NOT NULL, PRIMARY KEY(`uid`,`blocked`) ) WITHOUT ROWID;
+
+CREATE INDEX `idx_blocks_blocked` ON `blocks` ( `blocked` );
+
+CREATE TABLE `mutes` ( `uid` INTEGER NOT NULL, `muted` INTEGER NOT NULL, PRIMARY KEY(`uid`,`muted`) ) WITHOUT ROWID;
@@ -0,0 +1,3 @@
+DROP TABLE `follow_requests`;
+
+ALTER TABLE `users` DROP COLUMN `protected`;
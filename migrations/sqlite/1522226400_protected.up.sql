@@ -0,0 +1,3 @@
+ALTER TABLE `users` ADD COLUMN `protected` INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE `follow_requests` ( `sfrom` INTEGER NOT NULL, `sto` INTEGER NOT NULL, `created_at` timestamp NOT NULL, PRIMARY KEY(`sto`,`sfrom`) ) WITHOUT ROWID;
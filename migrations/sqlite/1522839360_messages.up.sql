@@ -0,0 +1,9 @@
+CREATE TABLE `conversations` ( `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, `created_at` timestamp NOT NULL, `last_message` INTEGER NOT NULL DEFAULT 0 );
+
+CREATE TABLE `conversation_members` ( `cid` INTEGER NOT NULL, `uid` INTEGER NOT NULL, `last_read` INTEGER NOT NULL DEFAULT 0, PRIMARY KEY(`cid`,`uid`) ) WITHOUT ROWID;
+
+CREATE INDEX `idx_conversation_members_uid` ON `conversation_members` ( `uid`, `cid` );
+
+CREATE TABLE `messages` ( `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, `cid` INTEGER NOT NULL, `uid` INTEGER NOT NULL, `created_at` timestamp NOT NULL, `text` TEXT NOT NULL );
+
+CREATE INDEX `idx_messages_cid` ON `messages` ( `cid`, `id` );
@@ -0,0 +1,5 @@
+CREATE TABLE `notifications` ( `id` INTEGER NOT NULL PRIMARY KEY AUTOINCREMENT, `uid` INTEGER NOT NULL, `kind` TEXT NOT NULL, `from_uid` INTEGER NOT NULL, `tid` INTEGER NOT NULL DEFAULT 0, `created_at` timestamp NOT NULL, `read` INTEGER NOT NULL DEFAULT 0 );
+
+CREATE UNIQUE INDEX `idx_notifications_unique` ON `notifications` ( `uid`, `kind`, `from_uid`, `tid` );
+
+CREATE INDEX `idx_notifications_uid` ON `notifications` ( `uid`, `id` );
@@ -0,0 +1,3 @@
+CREATE TABLE `sessions` ( `id` TEXT NOT NULL PRIMARY KEY, `uid` INTEGER NOT NULL, `created_at` INTEGER NOT NULL, `last_seen` INTEGER NOT NULL, `expires_at` INTEGER NOT NULL, `deadline` INTEGER NOT NULL );
+
+CREATE INDEX `idx_sessions_expires_at` ON `sessions` ( `expires_at` );
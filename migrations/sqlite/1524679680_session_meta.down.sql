@@ -0,0 +1,4 @@
+DROP INDEX `idx_sessions_uid`;
+
+ALTER TABLE `sessions` DROP COLUMN `ip`;
+ALTER TABLE `sessions` DROP COLUMN `user_agent`;
@@ -0,0 +1,4 @@
+ALTER TABLE `sessions` ADD COLUMN `ip` TEXT NOT NULL DEFAULT '';
+ALTER TABLE `sessions` ADD COLUMN `user_agent` TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX `idx_sessions_uid` ON `sessions` ( `uid`, `created_at` );
@@ -0,0 +1 @@
+DROP TABLE `denied_tokens`;
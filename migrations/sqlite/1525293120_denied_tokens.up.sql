@@ -0,0 +1 @@
+CREATE TABLE `denied_tokens` ( `id` TEXT NOT NULL PRIMARY KEY, `expires_at` INTEGER NOT NULL ) WITHOUT ROWID;
@@ -0,0 +1 @@
+ALTER TABLE `tweets` DROP COLUMN `pulled`;
@@ -0,0 +1 @@
+ALTER TABLE `tweets` ADD COLUMN `pulled` INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,9 @@
+CREATE TABLE `likes_old` ( `uid` INTEGER NOT NULL, `tid` INTEGER NOT NULL, `created_at` timestamp NOT NULL, PRIMARY KEY(`uid`,`tid`) ) WITHOUT ROWID;
+
+INSERT INTO `likes_old` (`uid`,`tid`,`created_at`) SELECT `uid`,`tid`,`created_at` FROM `likes`;
+
+DROP TABLE `likes`;
+
+ALTER TABLE `likes_old` RENAME TO `likes`;
+
+CREATE INDEX `idx_likes_tid` ON `likes` ( `tid` );
@@ -0,0 +1,11 @@
+CREATE TABLE `likes_new` ( `id` INTEGER PRIMARY KEY AUTOINCREMENT, `uid` INTEGER NOT NULL, `tid` INTEGER NOT NULL, `created_at` timestamp NOT NULL, UNIQUE(`uid`,`tid`) );
+
+INSERT INTO `likes_new` (`uid`,`tid`,`created_at`) SELECT `uid`,`tid`,`created_at` FROM `likes` ORDER BY `created_at`,`tid`;
+
+DROP TABLE `likes`;
+
+ALTER TABLE `likes_new` RENAME TO `likes`;
+
+CREATE INDEX `idx_likes_tid` ON `likes` ( `tid` );
+
+CREATE INDEX `idx_likes_uid` ON `likes` ( `uid`, `id` );
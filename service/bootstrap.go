@@ -2,27 +2,95 @@ package service
 
 import (
 	"github.com/pkg/errors"
+	"github.com/utrack/woofer/lib/migrator"
+	"github.com/utrack/woofer/service/internal/events"
+	"github.com/utrack/woofer/service/internal/hub"
+	"github.com/utrack/woofer/service/internal/storage"
+	"github.com/utrack/woofer/service/internal/storage/inmem"
+	"github.com/utrack/woofer/service/internal/storage/postgres"
 	"github.com/utrack/woofer/service/internal/storage/sqlite"
 )
 
+// Storage backends supported by Bootstrap.
+const (
+	StorageSQLite   = "sqlite"
+	StoragePostgres = "postgres"
+	StorageInmem    = "inmem"
+)
+
 type Config struct {
+	// Storage is a name of the storage backend to use.
+	// Defaults to StorageSQLite.
+	Storage string
+
 	SQLiteConnString string
 	SQLiteMigrations string
+
+	PostgresConnString string
+	PostgresMigrations string
+
+	// FanoutLimit enables fan-out-on-write home timelines if non-zero.
+	// Tweets of users having more subscribers than the limit are not
+	// delivered on write; they're read on each request instead.
+	FanoutLimit uint
 }
 
 // Bootstrap returns a Woofer service.
 func Bootstrap(cfg Config) (*Woofer, error) {
 
-	storage, err := sqlite.New(cfg.SQLiteConnString, cfg.SQLiteMigrations)
+	storage, err := newStorage(cfg)
 	if err != nil {
 		return nil, errors.Wrap(err, "storage init failed")
 	}
 	// Normally we'd provide some configuration for the service there
 	// but this is a code challenge so
-	return &Woofer{
+	w := &Woofer{
 		tweetStorage: storage,
 		userStorage:  storage,
 		subStorage:   storage,
+		follows:      storage,
+		blocks:       storage,
+		mutes:        storage,
 		passCheck:    storage,
-	}, nil
+		inbox:        storage,
+		likes:        storage,
+		mentions:     storage,
+		hashtags:     storage,
+		search:       storage,
+		messages:     storage,
+		notes:        storage,
+		events:       events.New(),
+		hub:          hub.New(),
+		fanoutLimit:  cfg.FanoutLimit,
+	}
+	w.events.Subscribe(w.notify)
+	w.events.Subscribe(w.push)
+	return w, nil
+}
+
+// newStorage creates a storage backend chosen by the config.
+func newStorage(cfg Config) (storage.Storage, error) {
+	switch cfg.Storage {
+	case StorageSQLite, "":
+		return sqlite.New(cfg.SQLiteConnString, cfg.SQLiteMigrations)
+	case StoragePostgres:
+		return postgres.New(cfg.PostgresConnString, cfg.PostgresMigrations)
+	case StorageInmem:
+		return inmem.New(), nil
+	}
+	return nil, errors.Errorf("unknown storage backend %q", cfg.Storage)
+}
+
+// NewMigrator returns a schema migrator for the storage backend chosen
+// by the config.
+func NewMigrator(cfg Config) (*migrator.Migrator, error) {
+	switch cfg.Storage {
+	case StorageSQLite, "":
+		return sqlite.NewMigrator(cfg.SQLiteConnString, cfg.SQLiteMigrations)
+	case StoragePostgres:
+		return postgres.NewMigrator(cfg.PostgresConnString, cfg.PostgresMigrations)
+	case StorageInmem:
+		return nil, errors.New("inmem storage has no schema to migrate")
+	}
+	return nil, errors.Errorf("unknown storage backend %q", cfg.Storage)
 }
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/events"
+)
+
+// mention resolves users mentioned in the tweet's text and stores them,
+// replacing previous mentions of the tweet, then notifies mentioned users
+// who can see the tweet.
+// Mentions of unknown nicknames are ignored.
+func (w Woofer) mention(ctx context.Context, author domain.UserID, tweetID uint64, text string) error {
+	mentions := parseMentions(text)
+	if len(mentions) > 0 {
+		nicknames := make([]string, 0, len(mentions))
+		seen := make(map[string]struct{}, len(mentions))
+		for _, m := range mentions {
+			if _, ok := seen[m.Nickname]; !ok {
+				seen[m.Nickname] = struct{}{}
+				nicknames = append(nicknames, m.Nickname)
+			}
+		}
+		users, err := w.userStorage.GetByNicknames(ctx, nicknames)
+		if err != nil {
+			return errors.Wrap(err, "couldn't retrieve mentioned users")
+		}
+		ids := make(map[string]domain.UserID, len(users))
+		for _, u := range users {
+			ids[u.Nickname] = u.ID
+		}
+
+		found := mentions[:0]
+		for _, m := range mentions {
+			if id, ok := ids[m.Nickname]; ok {
+				m.UserID = id
+				found = append(found, m)
+			}
+		}
+		mentions = found
+	}
+
+	err := w.mentions.SetMentions(ctx, tweetID, mentions)
+	if err != nil {
+		return errors.Wrap(err, "couldn't save mentions")
+	}
+	if len(mentions) == 0 {
+		return nil
+	}
+
+	from, err := w.userStorage.GetByIds(ctx, []domain.UserID{author})
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve tweet's author")
+	}
+	if len(from) == 0 {
+		return errors.Errorf("author %v of tweet %v not found", author, tweetID)
+	}
+	notified := make(map[domain.UserID]struct{}, len(mentions))
+	for _, m := range mentions {
+		if _, ok := notified[m.UserID]; ok {
+			continue
+		}
+		notified[m.UserID] = struct{}{}
+		err = w.canView(ctx, m.UserID, from[0])
+		if err == errProtected {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		w.publish(ctx, events.Mentioned, author, m.UserID, tweetID)
+	}
+	return nil
+}
+
+// parseMentions returns all @nickname entities of the text, without UserIDs.
+// '@' should not follow a word character, so emails are not mentions.
+func parseMentions(text string) []domain.Mention {
+	var ret []domain.Mention
+	for _, e := range parseEntities(text, '@') {
+		ret = append(ret, domain.Mention{Nickname: e.name, Start: e.start, End: e.end})
+	}
+	return ret
+}
+
+// tag indexes the tweet by hashtags found in its text,
+// replacing its previous hashtags.
+func (w Woofer) tag(ctx context.Context, tweetID uint64, text string) error {
+	err := w.hashtags.SetHashtags(ctx, tweetID, parseHashtags(text))
+	return errors.Wrap(err, "couldn't save hashtags")
+}
+
+// parseHashtags returns unique normalized #hashtags of the text.
+// Hashtags consisting of digits only are ignored, so "#1" is not a tag.
+func parseHashtags(text string) []string {
+	var ret []string
+	seen := map[string]struct{}{}
+	for _, e := range parseEntities(text, '#') {
+		if strings.TrimFunc(e.name, unicode.IsDigit) == "" {
+			continue
+		}
+		tag := normalizeTag(e.name)
+		if _, ok := seen[tag]; !ok {
+			seen[tag] = struct{}{}
+			ret = append(ret, tag)
+		}
+	}
+	return ret
+}
+
+// normalizeTag returns the form of a hashtag it's indexed by,
+// so #Go and #go are the same tag.
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// entity is a word prefixed by a marker in a tweet's text.
+type entity struct {
+	// name is the word without the marker.
+	name string
+	// start and end are character offsets of the entity including
+	// the marker; end is exclusive.
+	start uint
+	end   uint
+}
+
+// parseEntities finds all words prefixed by the marker in the text.
+// The marker should not follow a word character.
+func parseEntities(text string, marker rune) []entity {
+	var ret []entity
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != marker || (i > 0 && isWordRune(runes[i-1])) {
+			continue
+		}
+		end := i + 1
+		for end < len(runes) && isWordRune(runes[end]) {
+			end++
+		}
+		if end == i+1 {
+			continue
+		}
+		ret = append(ret, entity{
+			name:  string(runes[i+1 : end]),
+			start: uint(i),
+			end:   uint(end),
+		})
+		i = end - 1
+	}
+	return ret
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
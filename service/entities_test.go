@@ -0,0 +1,54 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEntities(t *testing.T) {
+	tests := []struct {
+		text string
+		want []entity
+	}{
+		{"hi @alice!", []entity{{"alice", 3, 9}}},
+		{"@alice", []entity{{"alice", 0, 6}}},
+		// offsets are in characters, not bytes
+		{"привет @алиса", []entity{{"алиса", 7, 13}}},
+		{"😀 @bob", []entity{{"bob", 2, 6}}},
+		// emails are not mentions
+		{"mail bob@example.com", nil},
+		{"@bob@example.com", []entity{{"bob", 0, 4}}},
+		{"@alice @bob", []entity{{"alice", 0, 6}, {"bob", 7, 11}}},
+		{"(@alice,@bob)", []entity{{"alice", 1, 7}, {"bob", 8, 12}}},
+		{"@ alone @", nil},
+		{"@@bob", []entity{{"bob", 1, 5}}},
+		{"@bob_2.", []entity{{"bob_2", 0, 6}}},
+	}
+	for _, tc := range tests {
+		got := parseEntities(tc.text, '@')
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %+v, want %+v", tc.text, got, tc.want)
+		}
+	}
+}
+
+func TestParseHashtags(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"#Go and #go", []string{"go"}},
+		{"#go#rust", []string{"go"}},
+		{"#go #rust", []string{"go", "rust"}},
+		// digit-only tags are not tags, but tags may contain digits
+		{"#1 #2018 #go2", []string{"go2"}},
+		{"issue#1", nil},
+		{"#Привет", []string{"привет"}},
+	}
+	for _, tc := range tests {
+		got := parseHashtags(tc.text)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %v, want %v", tc.text, got, tc.want)
+		}
+	}
+}
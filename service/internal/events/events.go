@@ -0,0 +1,86 @@
+/*
+Package events provides an in-process event bus.
+It decouples the service's write paths from reactions to them,
+like notifications.
+*/
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+)
+
+// Kind is a kind of an event.
+type Kind string
+
+const (
+	// Subscribed means From has subscribed to To.
+	Subscribed Kind = "subscribe"
+	// FollowRequested means From has requested to subscribe
+	// to protected To.
+	FollowRequested Kind = "follow_request"
+	// Mentioned means From has mentioned To in the tweet.
+	Mentioned Kind = "mention"
+	// Replied means From has replied to To's tweet with the tweet.
+	Replied Kind = "reply"
+	// Liked means From has liked To's tweet.
+	Liked Kind = "like"
+	// Tweeted means From has posted the tweet. It's addressed
+	// to all From's subscribers, so To is 0.
+	Tweeted Kind = "tweet"
+)
+
+// Event is something a user did.
+type Event struct {
+	Kind Kind
+	// From is a user who caused the event.
+	From domain.UserID
+	// To is a user the event is addressed to, if any.
+	To domain.UserID
+	// TweetID is a tweet the event is about, 0 if there's none.
+	TweetID uint64
+	At      time.Time
+}
+
+// Handler reacts to an event.
+type Handler func(context.Context, Event) error
+
+// Bus delivers published events to all the handlers subscribed to it.
+type Bus struct {
+	mtx      sync.RWMutex
+	handlers []Handler
+}
+
+// New creates a new Bus without handlers.
+func New() *Bus {
+	return &Bus{}
+}
+
+// Subscribe adds a handler receiving all events published afterwards.
+func (b *Bus) Subscribe(h Handler) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.handlers = append(b.handlers, h)
+}
+
+// Publish passes the event to the handlers synchronously, in order
+// of their subscription.
+// The event has already happened, so handler errors can't undo it:
+// they're logged and don't stop the delivery.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mtx.RLock()
+	handlers := b.handlers
+	b.mtx.RUnlock()
+
+	for _, h := range handlers {
+		err := h(ctx, e)
+		if err != nil {
+			logrus.Error(errors.Wrapf(err, "couldn't handle %v event", e.Kind))
+		}
+	}
+}
@@ -0,0 +1,102 @@
+/*
+Package hub provides an in-process pub/sub hub fanning out updates
+to users' connected clients.
+*/
+package hub
+
+import (
+	"sync"
+
+	"github.com/utrack/woofer/domain"
+)
+
+// subBuffer is a number of updates a subscription can lag behind
+// before it's dropped.
+const subBuffer = 64
+
+// Hub delivers updates to subscriptions of their recipients.
+type Hub struct {
+	mtx  sync.Mutex
+	subs map[domain.UserID]map[*Subscription]struct{}
+}
+
+// New creates a new Hub without subscriptions.
+func New() *Hub {
+	return &Hub{subs: map[domain.UserID]map[*Subscription]struct{}{}}
+}
+
+// Subscription receives updates of a single user.
+type Subscription struct {
+	hub  *Hub
+	user domain.UserID
+	c    chan domain.StreamEvent
+}
+
+// Subscribe creates a new subscription to the user's updates.
+// It should be closed when it's not needed anymore.
+func (h *Hub) Subscribe(user domain.UserID) *Subscription {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	s := &Subscription{
+		hub:  h,
+		user: user,
+		c:    make(chan domain.StreamEvent, subBuffer),
+	}
+	if h.subs[user] == nil {
+		h.subs[user] = map[*Subscription]struct{}{}
+	}
+	h.subs[user][s] = struct{}{}
+	return s
+}
+
+// Connected returns true if the user has any subscriptions,
+// so updates can be skipped for disconnected users.
+func (h *Hub) Connected(user domain.UserID) bool {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return len(h.subs[user]) > 0
+}
+
+// Publish sends the update to all subscriptions of the user without
+// blocking. Subscriptions lagging behind are dropped, their clients
+// should resume from the last update they got.
+func (h *Hub) Publish(user domain.UserID, e domain.StreamEvent) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	for s := range h.subs[user] {
+		select {
+		case s.c <- e:
+		default:
+			h.drop(s)
+		}
+	}
+}
+
+// drop removes the subscription, closing its channel.
+// Caller should hold the lock.
+func (h *Hub) drop(s *Subscription) {
+	if _, ok := h.subs[s.user][s]; !ok {
+		return
+	}
+	delete(h.subs[s.user], s)
+	if len(h.subs[s.user]) == 0 {
+		delete(h.subs, s.user)
+	}
+	close(s.c)
+}
+
+// C returns a channel of the user's updates. It's closed when
+// the subscription is closed or dropped.
+func (s *Subscription) C() <-chan domain.StreamEvent {
+	return s.c
+}
+
+// Close removes the subscription from the hub.
+// Closing it twice is not an error.
+func (s *Subscription) Close() {
+	s.hub.mtx.Lock()
+	defer s.hub.mtx.Unlock()
+	s.hub.drop(s)
+}
@@ -0,0 +1,75 @@
+package inmem
+
+import (
+	"context"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type blockStorage struct {
+	d *db
+}
+
+var _ storage.BlockStorage = &blockStorage{}
+var _ storage.MuteStorage = &blockStorage{}
+
+func (bs *blockStorage) Block(ctx context.Context, user domain.UserID, blocked domain.UserID) error {
+	bs.d.mtx.Lock()
+	defer bs.d.mtx.Unlock()
+
+	link(bs.d.blocks, user, blocked)
+	for _, pair := range [][2]domain.UserID{{user, blocked}, {blocked, user}} {
+		delete(bs.d.subs[pair[0]], pair[1])
+		delete(bs.d.subbed[pair[1]], pair[0])
+		bs.d.dropFollowRequest(pair[0], pair[1])
+	}
+	return nil
+}
+
+func (bs *blockStorage) Unblock(ctx context.Context, user domain.UserID, blocked domain.UserID) error {
+	bs.d.mtx.Lock()
+	defer bs.d.mtx.Unlock()
+
+	delete(bs.d.blocks[user], blocked)
+	return nil
+}
+
+func (bs *blockStorage) IsBlocked(ctx context.Context, a domain.UserID, b domain.UserID) (bool, error) {
+	bs.d.mtx.RLock()
+	defer bs.d.mtx.RUnlock()
+
+	_, ab := bs.d.blocks[a][b]
+	_, ba := bs.d.blocks[b][a]
+	return ab || ba, nil
+}
+
+func (bs *blockStorage) Blocked(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	bs.d.mtx.RLock()
+	defer bs.d.mtx.RUnlock()
+
+	return keys(bs.d.blocks[user]), nil
+}
+
+func (bs *blockStorage) Mute(ctx context.Context, user domain.UserID, muted domain.UserID) error {
+	bs.d.mtx.Lock()
+	defer bs.d.mtx.Unlock()
+
+	link(bs.d.mutes, user, muted)
+	return nil
+}
+
+func (bs *blockStorage) Unmute(ctx context.Context, user domain.UserID, muted domain.UserID) error {
+	bs.d.mtx.Lock()
+	defer bs.d.mtx.Unlock()
+
+	delete(bs.d.mutes[user], muted)
+	return nil
+}
+
+func (bs *blockStorage) Muted(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	bs.d.mtx.RLock()
+	defer bs.d.mtx.RUnlock()
+
+	return keys(bs.d.mutes[user]), nil
+}
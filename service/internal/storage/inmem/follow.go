@@ -0,0 +1,88 @@
+package inmem
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type followStorage struct {
+	d *db
+}
+
+var _ storage.FollowRequestStorage = &followStorage{}
+
+// errNoFollowRequest is returned if a follow request to approve or reject
+// doesn't exist.
+var errNoFollowRequest = bizerr.New("follow request was not found", bizerr.ErrorNotFound)
+
+func (fs *followStorage) RequestFollow(ctx context.Context, from, to domain.UserID, at time.Time) error {
+	fs.d.mtx.Lock()
+	defer fs.d.mtx.Unlock()
+
+	reqs, ok := fs.d.followReqs[to]
+	if !ok {
+		reqs = map[domain.UserID]time.Time{}
+		fs.d.followReqs[to] = reqs
+	}
+	if _, ok := reqs[from]; !ok {
+		reqs[from] = at
+	}
+	return nil
+}
+
+func (fs *followStorage) FollowRequests(ctx context.Context, to domain.UserID) ([]domain.UserID, error) {
+	fs.d.mtx.RLock()
+	defer fs.d.mtx.RUnlock()
+
+	reqs := fs.d.followReqs[to]
+	ret := make([]domain.UserID, 0, len(reqs))
+	for from := range reqs {
+		ret = append(ret, from)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := reqs[ret[i]], reqs[ret[j]]
+		if !a.Equal(b) {
+			return a.Before(b)
+		}
+		return ret[i] < ret[j]
+	})
+	return ret, nil
+}
+
+func (fs *followStorage) ApproveFollow(ctx context.Context, from, to domain.UserID) error {
+	fs.d.mtx.Lock()
+	defer fs.d.mtx.Unlock()
+
+	if !fs.d.dropFollowRequest(from, to) {
+		return errNoFollowRequest
+	}
+	link(fs.d.subs, from, to)
+	link(fs.d.subbed, to, from)
+	return nil
+}
+
+func (fs *followStorage) RejectFollow(ctx context.Context, from, to domain.UserID) error {
+	fs.d.mtx.Lock()
+	defer fs.d.mtx.Unlock()
+
+	if !fs.d.dropFollowRequest(from, to) {
+		return errNoFollowRequest
+	}
+	return nil
+}
+
+// dropFollowRequest removes a follow request, returning false
+// if there was none.
+// Caller should hold the write lock.
+func (d *db) dropFollowRequest(from, to domain.UserID) bool {
+	if _, ok := d.followReqs[to][from]; !ok {
+		return false
+	}
+	delete(d.followReqs[to], from)
+	return true
+}
@@ -0,0 +1,47 @@
+package inmem
+
+import (
+	"context"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type hashtagStorage struct {
+	d *db
+}
+
+var _ storage.HashtagStorage = &hashtagStorage{}
+
+func (hs *hashtagStorage) SetHashtags(ctx context.Context, tweetID uint64, tags []string) error {
+	hs.d.mtx.Lock()
+	defer hs.d.mtx.Unlock()
+
+	for _, tag := range hs.d.tweetTags[tweetID] {
+		delete(hs.d.hashtags[tag], tweetID)
+	}
+	delete(hs.d.tweetTags, tweetID)
+	for _, tag := range tags {
+		tagged, ok := hs.d.hashtags[tag]
+		if !ok {
+			tagged = map[uint64]struct{}{}
+			hs.d.hashtags[tag] = tagged
+		}
+		tagged[tweetID] = struct{}{}
+	}
+	if len(tags) > 0 {
+		hs.d.tweetTags[tweetID] = append([]string(nil), tags...)
+	}
+	return nil
+}
+
+func (hs *hashtagStorage) GetTagPage(ctx context.Context, viewer domain.UserID, tag string, page storage.Page) ([]domain.TweetWithUsername, error) {
+	hs.d.mtx.RLock()
+	defer hs.d.mtx.RUnlock()
+
+	tagged := hs.d.hashtags[tag]
+	return hs.d.page(page, func(t domain.Tweet) bool {
+		_, ok := tagged[t.ID]
+		return ok && hs.d.visible(viewer, t)
+	}), nil
+}
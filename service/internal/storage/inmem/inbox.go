@@ -0,0 +1,79 @@
+package inmem
+
+import (
+	"context"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type inboxStorage struct {
+	d *db
+}
+
+var _ storage.InboxStorage = &inboxStorage{}
+
+func (is *inboxStorage) Deliver(ctx context.Context, tweetID uint64, to []domain.UserID) error {
+	is.d.mtx.Lock()
+	defer is.d.mtx.Unlock()
+
+	for _, uid := range to {
+		is.d.deliver(uid, tweetID)
+	}
+	return nil
+}
+
+func (is *inboxStorage) Pull(ctx context.Context, tweetID uint64) error {
+	is.d.mtx.Lock()
+	defer is.d.mtx.Unlock()
+
+	is.d.pulled[tweetID] = struct{}{}
+	return nil
+}
+
+func (is *inboxStorage) Backfill(ctx context.Context, user domain.UserID, from domain.UserID, limit uint) error {
+	is.d.mtx.Lock()
+	defer is.d.mtx.Unlock()
+
+	var found uint
+	for i := len(is.d.tweets) - 1; i >= 0 && found < limit; i-- {
+		if is.d.tweets[i].From != from || is.d.isDeleted(is.d.tweets[i].ID) {
+			continue
+		}
+		is.d.deliver(user, is.d.tweets[i].ID)
+		found++
+	}
+	return nil
+}
+
+func (is *inboxStorage) GetInboxPage(ctx context.Context, user domain.UserID, page storage.Page, pullThreshold uint) ([]domain.TweetWithUsername, error) {
+	is.d.mtx.RLock()
+	defer is.d.mtx.RUnlock()
+
+	subs, mutes := is.d.subs[user], is.d.mutes[user]
+	inbox := is.d.inbox[user]
+	return is.d.page(page, func(t domain.Tweet) bool {
+		if _, ok := subs[t.From]; !ok {
+			return false
+		}
+		if _, ok := mutes[t.From]; ok {
+			return false
+		}
+		if _, ok := inbox[t.ID]; ok {
+			return true
+		}
+		if _, ok := is.d.pulled[t.ID]; ok {
+			return true
+		}
+		return uint(len(is.d.subbed[t.From])) > pullThreshold
+	}), nil
+}
+
+// deliver puts a tweet to the user's inbox.
+// Caller should hold the write lock.
+func (d *db) deliver(user domain.UserID, tweetID uint64) {
+	if d.inbox[user] == nil {
+		d.inbox[user] = map[uint64]struct{}{}
+	}
+	d.inbox[user][tweetID] = struct{}{}
+}
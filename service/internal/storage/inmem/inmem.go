@@ -0,0 +1,157 @@
+/*
+Package inmem provides in-memory storage.
+It keeps no data between restarts, so it's useful for tests and demos only.
+*/
+package inmem
+
+import (
+	"sync"
+	"time"
+
+	"github.com/utrack/woofer/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// passwordHashCost is kept minimal since this storage is not meant
+// for production use and should be fast in tests.
+const passwordHashCost = bcrypt.MinCost
+
+// Storage implements complete storage.Storage using in-process maps as a backend.
+type Storage struct {
+	userStorage
+	tweetStorage
+	subsStorage
+	followStorage
+	blockStorage
+	inboxStorage
+	likeStorage
+	mentionStorage
+	hashtagStorage
+	messageStorage
+	notificationStorage
+}
+
+// New creates a new empty in-memory storage.
+func New() *Storage {
+	d := &db{
+		users:      map[domain.UserID]userRecord{},
+		nicknames:  map[string]domain.UserID{},
+		subs:       map[domain.UserID]map[domain.UserID]struct{}{},
+		subbed:     map[domain.UserID]map[domain.UserID]struct{}{},
+		followReqs: map[domain.UserID]map[domain.UserID]time.Time{},
+		blocks:     map[domain.UserID]map[domain.UserID]struct{}{},
+		mutes:      map[domain.UserID]map[domain.UserID]struct{}{},
+		deleted:    map[uint64]struct{}{},
+		pulled:     map[uint64]struct{}{},
+		revisions:  map[uint64][]domain.TweetRevision{},
+		inbox:      map[domain.UserID]map[uint64]struct{}{},
+		likes:      map[uint64]map[domain.UserID]struct{}{},
+		liked:      map[domain.UserID]map[uint64]uint64{},
+		mentions:   map[uint64][]domain.Mention{},
+		hashtags:   map[string]map[uint64]struct{}{},
+		tweetTags:  map[uint64][]string{},
+		notified:   map[notificationKey]struct{}{},
+	}
+	return &Storage{
+		userStorage{
+			d: d,
+		},
+		tweetStorage{
+			d: d,
+		},
+		subsStorage{
+			d: d,
+		},
+		followStorage{
+			d: d,
+		},
+		blockStorage{
+			d: d,
+		},
+		inboxStorage{
+			d: d,
+		},
+		likeStorage{
+			d: d,
+		},
+		mentionStorage{
+			d: d,
+		},
+		hashtagStorage{
+			d: d,
+		},
+		messageStorage{
+			d: d,
+		},
+		notificationStorage{
+			d: d,
+		},
+	}
+}
+
+// db is the data shared between all the storages.
+type db struct {
+	mtx sync.RWMutex
+
+	lastUserID domain.UserID
+	users      map[domain.UserID]userRecord
+	nicknames  map[string]domain.UserID
+
+	// tweets are ordered by their ID; tweet's ID is its index+1.
+	tweets []domain.Tweet
+	// deleted is a set of deleted tweets' IDs.
+	deleted map[uint64]struct{}
+	// revisions maps tweet to its previous versions, oldest first.
+	revisions map[uint64][]domain.TweetRevision
+
+	// subs maps subscriber to its subscriptions.
+	subs map[domain.UserID]map[domain.UserID]struct{}
+	// subbed maps user to its subscribers.
+	subbed map[domain.UserID]map[domain.UserID]struct{}
+	// followReqs maps protected user to users requesting to subscribe
+	// to it and times of their requests.
+	followReqs map[domain.UserID]map[domain.UserID]time.Time
+
+	// blocks maps user to users it blocks.
+	blocks map[domain.UserID]map[domain.UserID]struct{}
+	// mutes maps user to users it muted.
+	mutes map[domain.UserID]map[domain.UserID]struct{}
+
+	// inbox maps user to tweet IDs delivered to its home timeline.
+	inbox map[domain.UserID]map[uint64]struct{}
+	// pulled is a set of tweets not delivered to inboxes.
+	pulled map[uint64]struct{}
+
+	// likes maps tweet to users who liked it.
+	likes map[uint64]map[domain.UserID]struct{}
+	// liked maps user to tweets it likes and IDs of the likes.
+	liked map[domain.UserID]map[uint64]uint64
+	// likeRecords are ordered by their ID; like's ID is its index+1.
+	// Records of removed likes are kept to keep the IDs.
+	likeRecords []likeRecord
+
+	// mentions maps tweet to users mentioned in it, ordered by offsets.
+	mentions map[uint64][]domain.Mention
+
+	// hashtags maps hashtag to tweets having it.
+	hashtags map[string]map[uint64]struct{}
+	// tweetTags maps tweet to its hashtags.
+	tweetTags map[uint64][]string
+
+	// conversations are ordered by their ID; conversation's ID
+	// is its index+1.
+	conversations []conversationRecord
+	// messages are ordered by their ID; message's ID is its index+1.
+	messages []domain.Message
+
+	// notifications are ordered by their ID; notification's ID
+	// is its index+1.
+	notifications []notificationRecord
+	// notified is a set of saved notifications.
+	notified map[notificationKey]struct{}
+}
+
+type userRecord struct {
+	domain.User
+	password []byte
+}
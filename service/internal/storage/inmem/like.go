@@ -0,0 +1,97 @@
+package inmem
+
+import (
+	"context"
+	"time"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type likeStorage struct {
+	d *db
+}
+
+var _ storage.LikeStorage = &likeStorage{}
+
+type likeRecord struct {
+	user    domain.UserID
+	tweetID uint64
+}
+
+func (ls *likeStorage) Like(ctx context.Context, user domain.UserID, tweetID uint64, at time.Time) error {
+	ls.d.mtx.Lock()
+	defer ls.d.mtx.Unlock()
+
+	if ls.d.likes[tweetID] == nil {
+		ls.d.likes[tweetID] = map[domain.UserID]struct{}{}
+	}
+	ls.d.likes[tweetID][user] = struct{}{}
+	if ls.d.liked[user] == nil {
+		ls.d.liked[user] = map[uint64]uint64{}
+	}
+	if _, ok := ls.d.liked[user][tweetID]; ok {
+		return nil
+	}
+	ls.d.likeRecords = append(ls.d.likeRecords, likeRecord{user: user, tweetID: tweetID})
+	ls.d.liked[user][tweetID] = uint64(len(ls.d.likeRecords))
+	return nil
+}
+
+func (ls *likeStorage) Unlike(ctx context.Context, user domain.UserID, tweetID uint64) error {
+	ls.d.mtx.Lock()
+	defer ls.d.mtx.Unlock()
+
+	delete(ls.d.likes[tweetID], user)
+	delete(ls.d.liked[user], tweetID)
+	return nil
+}
+
+func (ls *likeStorage) LikeStats(ctx context.Context, viewer domain.UserID, tweetIDs []uint64) (map[uint64]storage.LikeStats, error) {
+	ls.d.mtx.RLock()
+	defer ls.d.mtx.RUnlock()
+
+	ret := make(map[uint64]storage.LikeStats, len(tweetIDs))
+	for _, id := range tweetIDs {
+		users := ls.d.likes[id]
+		if len(users) == 0 {
+			continue
+		}
+		_, liked := users[viewer]
+		ret[id] = storage.LikeStats{Count: uint64(len(users)), Liked: liked}
+	}
+	return ret, nil
+}
+
+func (ls *likeStorage) GetLikedPage(ctx context.Context, viewer domain.UserID, user domain.UserID, page storage.Page) ([]storage.LikedTweet, error) {
+	ls.d.mtx.RLock()
+	defer ls.d.mtx.RUnlock()
+
+	after, before := page.Bounds()
+	if last := uint64(len(ls.d.likeRecords)); before > last+1 {
+		before = last + 1
+	}
+
+	ret := make([]storage.LikedTweet, 0, page.Len)
+	add := func(id uint64) {
+		l := ls.d.likeRecords[id-1]
+		if l.user != user || ls.d.liked[user][l.tweetID] != id {
+			return
+		}
+		t := ls.d.tweets[l.tweetID-1]
+		if ls.d.isDeleted(t.ID) || !ls.d.visible(viewer, t) {
+			return
+		}
+		ret = append(ret, storage.LikedTweet{LikeID: id, TweetWithUsername: ls.d.withUsername(t)})
+	}
+	if page.Ascending() {
+		for id := after + 1; id < before && uint(len(ret)) < page.Len; id++ {
+			add(id)
+		}
+	} else {
+		for id := before - 1; id > after && uint(len(ret)) < page.Len; id-- {
+			add(id)
+		}
+	}
+	return ret, nil
+}
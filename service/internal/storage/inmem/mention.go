@@ -0,0 +1,57 @@
+package inmem
+
+import (
+	"context"
+	"sort"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type mentionStorage struct {
+	d *db
+}
+
+var _ storage.MentionStorage = &mentionStorage{}
+
+func (ms *mentionStorage) SetMentions(ctx context.Context, tweetID uint64, mentions []domain.Mention) error {
+	ms.d.mtx.Lock()
+	defer ms.d.mtx.Unlock()
+
+	if len(mentions) == 0 {
+		delete(ms.d.mentions, tweetID)
+		return nil
+	}
+	sorted := append([]domain.Mention(nil), mentions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	ms.d.mentions[tweetID] = sorted
+	return nil
+}
+
+func (ms *mentionStorage) Mentions(ctx context.Context, tweetIDs []uint64) (map[uint64][]domain.Mention, error) {
+	ms.d.mtx.RLock()
+	defer ms.d.mtx.RUnlock()
+
+	ret := map[uint64][]domain.Mention{}
+	for _, id := range tweetIDs {
+		for _, m := range ms.d.mentions[id] {
+			m.Nickname = ms.d.users[m.UserID].Nickname
+			ret[id] = append(ret[id], m)
+		}
+	}
+	return ret, nil
+}
+
+func (ms *mentionStorage) GetMentionsPage(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	ms.d.mtx.RLock()
+	defer ms.d.mtx.RUnlock()
+
+	return ms.d.page(page, func(t domain.Tweet) bool {
+		for _, m := range ms.d.mentions[t.ID] {
+			if m.UserID == user {
+				return ms.d.visible(user, t)
+			}
+		}
+		return false
+	}), nil
+}
@@ -0,0 +1,182 @@
+package inmem
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type messageStorage struct {
+	d *db
+}
+
+var _ storage.MessageStorage = &messageStorage{}
+
+// errNoConversation is returned if a conversation doesn't exist
+// or it's not visible to the user.
+var errNoConversation = bizerr.New("conversation was not found", bizerr.ErrorNotFound)
+
+type conversationRecord struct {
+	// members are ordered by their IDs.
+	members []domain.UserID
+	// lastRead maps member to the latest message it read.
+	lastRead map[domain.UserID]uint64
+	// messages are IDs of conversation's messages, oldest first.
+	messages []uint64
+}
+
+func (ms *messageStorage) NewConversation(ctx context.Context, members []domain.UserID, at time.Time) (uint64, error) {
+	ms.d.mtx.Lock()
+	defer ms.d.mtx.Unlock()
+
+	rec := conversationRecord{lastRead: map[domain.UserID]uint64{}}
+	for _, uid := range members {
+		if _, ok := rec.lastRead[uid]; !ok {
+			rec.lastRead[uid] = 0
+			rec.members = append(rec.members, uid)
+		}
+	}
+	sort.Slice(rec.members, func(i, j int) bool { return rec.members[i] < rec.members[j] })
+	ms.d.conversations = append(ms.d.conversations, rec)
+	return uint64(len(ms.d.conversations)), nil
+}
+
+func (ms *messageStorage) DirectConversation(ctx context.Context, a, b domain.UserID) (uint64, error) {
+	ms.d.mtx.RLock()
+	defer ms.d.mtx.RUnlock()
+
+	for i, c := range ms.d.conversations {
+		if len(c.members) != 2 {
+			continue
+		}
+		_, hasA := c.lastRead[a]
+		_, hasB := c.lastRead[b]
+		if hasA && hasB {
+			return uint64(i + 1), nil
+		}
+	}
+	return 0, errNoConversation
+}
+
+func (ms *messageStorage) Conversation(ctx context.Context, id uint64, user domain.UserID) (domain.Conversation, error) {
+	ms.d.mtx.RLock()
+	defer ms.d.mtx.RUnlock()
+
+	if id == 0 || id > uint64(len(ms.d.conversations)) {
+		return domain.Conversation{}, errNoConversation
+	}
+	if _, ok := ms.d.conversations[id-1].lastRead[user]; !ok {
+		return domain.Conversation{}, errNoConversation
+	}
+	return ms.d.conversation(id, user), nil
+}
+
+func (ms *messageStorage) Conversations(ctx context.Context, user domain.UserID) ([]domain.Conversation, error) {
+	ms.d.mtx.RLock()
+	defer ms.d.mtx.RUnlock()
+
+	ret := []domain.Conversation{}
+	for i, c := range ms.d.conversations {
+		if _, ok := c.lastRead[user]; ok {
+			ret = append(ret, ms.d.conversation(uint64(i+1), user))
+		}
+	}
+	lastID := func(c domain.Conversation) uint64 {
+		if c.LastMessage == nil {
+			return 0
+		}
+		return c.LastMessage.ID
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := lastID(ret[i]), lastID(ret[j])
+		if a != b {
+			return a > b
+		}
+		return ret[i].ID > ret[j].ID
+	})
+	return ret, nil
+}
+
+// conversation returns a conversation as seen by the user.
+// Caller should hold the read lock.
+func (d *db) conversation(id uint64, user domain.UserID) domain.Conversation {
+	c := d.conversations[id-1]
+	ret := domain.Conversation{
+		ID:       id,
+		Members:  append([]domain.UserID(nil), c.members...),
+		LastRead: c.lastRead[user],
+	}
+	for i := len(c.messages) - 1; i >= 0 && c.messages[i] > ret.LastRead; i-- {
+		if d.messages[c.messages[i]-1].From != user {
+			ret.Unread++
+		}
+	}
+	if n := len(c.messages); n > 0 {
+		m := d.messages[c.messages[n-1]-1]
+		ret.LastMessage = &m
+	}
+	return ret
+}
+
+func (ms *messageStorage) SendMessage(ctx context.Context, m domain.Message) (uint64, error) {
+	ms.d.mtx.Lock()
+	defer ms.d.mtx.Unlock()
+
+	if m.ConversationID == 0 || m.ConversationID > uint64(len(ms.d.conversations)) {
+		return 0, errNoConversation
+	}
+	m.ID = uint64(len(ms.d.messages) + 1)
+	ms.d.messages = append(ms.d.messages, m)
+
+	c := &ms.d.conversations[m.ConversationID-1]
+	c.messages = append(c.messages, m.ID)
+	if _, ok := c.lastRead[m.From]; ok {
+		c.lastRead[m.From] = m.ID
+	}
+	return m.ID, nil
+}
+
+func (ms *messageStorage) GetMessagePage(ctx context.Context, conversationID uint64, page storage.Page) ([]domain.Message, error) {
+	ms.d.mtx.RLock()
+	defer ms.d.mtx.RUnlock()
+
+	ret := make([]domain.Message, 0, page.Len)
+	if conversationID == 0 || conversationID > uint64(len(ms.d.conversations)) {
+		return ret, nil
+	}
+	ids := ms.d.conversations[conversationID-1].messages
+	after, before := page.Bounds()
+	add := func(id uint64) {
+		if id > after && id < before {
+			ret = append(ret, ms.d.messages[id-1])
+		}
+	}
+	if page.Ascending() {
+		for i := 0; i < len(ids) && uint(len(ret)) < page.Len; i++ {
+			add(ids[i])
+		}
+	} else {
+		for i := len(ids) - 1; i >= 0 && uint(len(ret)) < page.Len; i-- {
+			add(ids[i])
+		}
+	}
+	return ret, nil
+}
+
+func (ms *messageStorage) MarkRead(ctx context.Context, conversationID uint64, user domain.UserID) error {
+	ms.d.mtx.Lock()
+	defer ms.d.mtx.Unlock()
+
+	if conversationID == 0 || conversationID > uint64(len(ms.d.conversations)) {
+		return nil
+	}
+	c := ms.d.conversations[conversationID-1]
+	if _, ok := c.lastRead[user]; ok && len(c.messages) > 0 {
+		c.lastRead[user] = c.messages[len(c.messages)-1]
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package inmem
+
+import (
+	"context"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type notificationStorage struct {
+	d *db
+}
+
+var _ storage.NotificationStorage = &notificationStorage{}
+
+type notificationRecord struct {
+	storage.Notification
+	user domain.UserID
+	read bool
+}
+
+// notificationKey identifies duplicate notifications.
+type notificationKey struct {
+	user    domain.UserID
+	kind    domain.NotificationKind
+	from    domain.UserID
+	tweetID uint64
+}
+
+func (ns *notificationStorage) Notify(ctx context.Context, user domain.UserID, n storage.Notification) (uint64, error) {
+	ns.d.mtx.Lock()
+	defer ns.d.mtx.Unlock()
+
+	key := notificationKey{user: user, kind: n.Kind, from: n.From, tweetID: n.TweetID}
+	if _, ok := ns.d.notified[key]; ok {
+		return 0, nil
+	}
+	ns.d.notified[key] = struct{}{}
+	ns.d.notifications = append(ns.d.notifications, notificationRecord{Notification: n, user: user})
+	return uint64(len(ns.d.notifications)), nil
+}
+
+func (ns *notificationStorage) GetNotificationsPage(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.Notification, error) {
+	ns.d.mtx.RLock()
+	defer ns.d.mtx.RUnlock()
+
+	after, before := page.Bounds()
+	if last := uint64(len(ns.d.notifications)); before > last+1 {
+		before = last + 1
+	}
+
+	ret := make([]domain.Notification, 0, page.Len)
+	add := func(id uint64) {
+		n := ns.d.notifications[id-1]
+		if n.user != user {
+			return
+		}
+		ret = append(ret, domain.Notification{
+			ID:      id,
+			Kind:    n.Kind,
+			From:    ns.d.users[n.From].Nickname,
+			TweetID: n.TweetID,
+			At:      n.At,
+			Read:    n.read,
+		})
+	}
+	if page.Ascending() {
+		for id := after + 1; id < before && uint(len(ret)) < page.Len; id++ {
+			add(id)
+		}
+	} else {
+		for id := before - 1; id > after && uint(len(ret)) < page.Len; id-- {
+			add(id)
+		}
+	}
+	return ret, nil
+}
+
+func (ns *notificationStorage) UnreadNotifications(ctx context.Context, user domain.UserID) (uint64, error) {
+	ns.d.mtx.RLock()
+	defer ns.d.mtx.RUnlock()
+
+	var ret uint64
+	for _, n := range ns.d.notifications {
+		if n.user == user && !n.read {
+			ret++
+		}
+	}
+	return ret, nil
+}
+
+func (ns *notificationStorage) ReadNotifications(ctx context.Context, user domain.UserID, upTo uint64) error {
+	ns.d.mtx.Lock()
+	defer ns.d.mtx.Unlock()
+
+	for i := range ns.d.notifications {
+		if upTo != 0 && uint64(i+1) > upTo {
+			break
+		}
+		if ns.d.notifications[i].user == user {
+			ns.d.notifications[i].read = true
+		}
+	}
+	return nil
+}
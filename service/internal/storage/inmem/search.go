@@ -0,0 +1,69 @@
+package inmem
+
+import (
+	"context"
+	"strings"
+	"unicode"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+var _ storage.TweetSearcher = &tweetStorage{}
+
+// SearchTweets scans all the tweets, so it's slow on large data sets.
+// Matching tweets are not ranked, newest ones come first.
+func (ts *tweetStorage) SearchTweets(ctx context.Context, q storage.SearchQuery, offset uint, limit uint) ([]domain.TweetWithUsername, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	want := words(strings.Join(q.Words, " "))
+	var phrases [][]string
+	for _, p := range q.Phrases {
+		phrases = append(phrases, words(p))
+	}
+	match := func(t domain.Tweet) bool {
+		if q.From != "" && ts.d.users[t.From].Nickname != q.From {
+			return false
+		}
+		if !ts.d.visible(q.Viewer, t) {
+			return false
+		}
+		if (!q.Since.IsZero() && t.At.Before(q.Since)) || (!q.Until.IsZero() && !t.At.Before(q.Until)) {
+			return false
+		}
+		text := " " + strings.Join(words(t.Text), " ") + " "
+		for _, w := range want {
+			if !strings.Contains(text, " "+w+" ") {
+				return false
+			}
+		}
+		for _, p := range phrases {
+			if !strings.Contains(text, " "+strings.Join(p, " ")+" ") {
+				return false
+			}
+		}
+		return true
+	}
+
+	ret := make([]domain.TweetWithUsername, 0, limit)
+	for i := len(ts.d.tweets) - 1; i >= 0 && uint(len(ret)) < limit; i-- {
+		t := ts.d.tweets[i]
+		if ts.d.isDeleted(t.ID) || !match(t) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		ret = append(ret, ts.d.withUsername(t))
+	}
+	return ret, nil
+}
+
+// words splits the text into lowercase words.
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+}
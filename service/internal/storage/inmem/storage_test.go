@@ -0,0 +1,14 @@
+package inmem
+
+import (
+	"testing"
+
+	"github.com/utrack/woofer/service/internal/storage"
+	"github.com/utrack/woofer/service/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New()
+	})
+}
@@ -0,0 +1,70 @@
+package inmem
+
+import (
+	"context"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type subsStorage struct {
+	d *db
+}
+
+var _ storage.SubsStorage = &subsStorage{}
+
+func (s *subsStorage) Subscribe(ctx context.Context, from, to domain.UserID) error {
+	s.d.mtx.Lock()
+	defer s.d.mtx.Unlock()
+
+	link(s.d.subs, from, to)
+	link(s.d.subbed, to, from)
+	return nil
+}
+
+func (s *subsStorage) Unsubscribe(ctx context.Context, from, to domain.UserID) error {
+	s.d.mtx.Lock()
+	defer s.d.mtx.Unlock()
+
+	delete(s.d.subs[from], to)
+	delete(s.d.subbed[to], from)
+	return nil
+}
+
+func (s *subsStorage) IsSubscribed(ctx context.Context, from, to domain.UserID) (bool, error) {
+	s.d.mtx.RLock()
+	defer s.d.mtx.RUnlock()
+
+	_, ok := s.d.subs[from][to]
+	return ok, nil
+}
+
+func (s *subsStorage) Subs(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	s.d.mtx.RLock()
+	defer s.d.mtx.RUnlock()
+
+	return keys(s.d.subs[user]), nil
+}
+
+func (s *subsStorage) Subbed(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	s.d.mtx.RLock()
+	defer s.d.mtx.RUnlock()
+
+	return keys(s.d.subbed[user]), nil
+}
+
+// link adds an a->b relation to the set.
+func link(set map[domain.UserID]map[domain.UserID]struct{}, a, b domain.UserID) {
+	if set[a] == nil {
+		set[a] = map[domain.UserID]struct{}{}
+	}
+	set[a][b] = struct{}{}
+}
+
+func keys(set map[domain.UserID]struct{}) []domain.UserID {
+	ret := make([]domain.UserID, 0, len(set))
+	for id := range set {
+		ret = append(ret, id)
+	}
+	return ret
+}
@@ -0,0 +1,236 @@
+package inmem
+
+import (
+	"context"
+	"time"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type tweetStorage struct {
+	d *db
+}
+
+var _ storage.TweetStorage = &tweetStorage{}
+
+func (ts *tweetStorage) Tweet(ctx context.Context, t domain.Tweet) (uint64, error) {
+	ts.d.mtx.Lock()
+	defer ts.d.mtx.Unlock()
+
+	if t.IsPlainRetweet() {
+		for _, old := range ts.d.tweets {
+			if old.From == t.From && old.RetweetOf == t.RetweetOf && old.IsPlainRetweet() && !ts.d.isDeleted(old.ID) {
+				return 0, bizerr.New("this tweet was already retweeted", bizerr.ErrorConflict)
+			}
+		}
+	}
+	t.ID = uint64(len(ts.d.tweets) + 1)
+	ts.d.tweets = append(ts.d.tweets, t)
+	return t.ID, nil
+}
+
+func (ts *tweetStorage) Edit(ctx context.Context, tweetID uint64, text string, at time.Time) error {
+	ts.d.mtx.Lock()
+	defer ts.d.mtx.Unlock()
+
+	t, ok := ts.d.tweet(tweetID)
+	if !ok {
+		return bizerr.New("tweet was not found", bizerr.ErrorNotFound)
+	}
+	rev := domain.TweetRevision{Text: t.Text, At: t.At}
+	if t.EditedAt != nil {
+		rev.At = *t.EditedAt
+	}
+	ts.d.revisions[tweetID] = append(ts.d.revisions[tweetID], rev)
+
+	t.Text = text
+	t.EditedAt = &at
+	ts.d.tweets[tweetID-1] = t
+	return nil
+}
+
+func (ts *tweetStorage) Delete(ctx context.Context, tweetID uint64) error {
+	ts.d.mtx.Lock()
+	defer ts.d.mtx.Unlock()
+
+	if _, ok := ts.d.tweet(tweetID); !ok {
+		return nil
+	}
+	ts.d.deleted[tweetID] = struct{}{}
+	for _, t := range ts.d.tweets {
+		if t.RetweetOf == tweetID && t.IsPlainRetweet() {
+			ts.d.deleted[t.ID] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (ts *tweetStorage) Revisions(ctx context.Context, tweetID uint64) ([]domain.TweetRevision, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	ret := []domain.TweetRevision{}
+	if _, ok := ts.d.tweet(tweetID); !ok {
+		return ret, nil
+	}
+	return append(ret, ts.d.revisions[tweetID]...), nil
+}
+
+func (ts *tweetStorage) ByID(ctx context.Context, id uint64) (domain.Tweet, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	t, ok := ts.d.tweet(id)
+	if !ok {
+		return domain.Tweet{}, bizerr.New("tweet was not found", bizerr.ErrorNotFound)
+	}
+	return t, nil
+}
+
+func (ts *tweetStorage) ByIDs(ctx context.Context, ids []uint64) ([]domain.TweetWithUsername, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	ret := make([]domain.TweetWithUsername, 0, len(ids))
+	for _, id := range ids {
+		if t, ok := ts.d.tweet(id); ok {
+			ret = append(ret, ts.d.withUsername(t))
+		}
+	}
+	return ret, nil
+}
+
+func (ts *tweetStorage) GetPageForUser(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	subs, mutes := ts.d.subs[user], ts.d.mutes[user]
+	return ts.d.page(page, func(t domain.Tweet) bool {
+		_, ok := subs[t.From]
+		_, muted := mutes[t.From]
+		return ok && !muted
+	}), nil
+}
+
+func (ts *tweetStorage) GetPageForProfile(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	return ts.d.page(page, func(t domain.Tweet) bool {
+		return t.From == user
+	}), nil
+}
+
+func (ts *tweetStorage) GetPage(ctx context.Context, page storage.Page) ([]domain.TweetWithUsername, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	return ts.d.page(page, func(domain.Tweet) bool {
+		return true
+	}), nil
+}
+
+// page returns tweets of the page that match the filter.
+// Caller should hold the read lock.
+func (d *db) page(p storage.Page, match func(domain.Tweet) bool) []domain.TweetWithUsername {
+	after, before := p.Bounds()
+	if last := uint64(len(d.tweets)); before > last+1 {
+		before = last + 1
+	}
+
+	ret := make([]domain.TweetWithUsername, 0, p.Len)
+	add := func(id uint64) {
+		t := d.tweets[id-1]
+		if match(t) && !d.isDeleted(id) {
+			ret = append(ret, d.withUsername(t))
+		}
+	}
+	if p.Ascending() {
+		for id := after + 1; id < before && uint(len(ret)) < p.Len; id++ {
+			add(id)
+		}
+	} else {
+		for id := before - 1; id > after && uint(len(ret)) < p.Len; id-- {
+			add(id)
+		}
+	}
+	return ret
+}
+
+func (ts *tweetStorage) Ancestors(ctx context.Context, viewer domain.UserID, tweetID uint64, limit uint) ([]domain.TweetWithUsername, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	var ret []domain.TweetWithUsername
+	if tweetID == 0 || tweetID > uint64(len(ts.d.tweets)) {
+		return ret, nil
+	}
+	parent := ts.d.tweets[tweetID-1].InReplyTo
+	for depth := uint(0); parent != 0 && depth < limit; depth++ {
+		t := ts.d.tweets[parent-1]
+		if !ts.d.isDeleted(t.ID) && ts.d.visible(viewer, t) {
+			ret = append([]domain.TweetWithUsername{ts.d.withUsername(t)}, ret...)
+		}
+		parent = t.InReplyTo
+	}
+	return ret, nil
+}
+
+func (ts *tweetStorage) Descendants(ctx context.Context, viewer domain.UserID, tweetID uint64, page storage.Page) ([]domain.TweetWithUsername, error) {
+	ts.d.mtx.RLock()
+	defer ts.d.mtx.RUnlock()
+
+	if tweetID == 0 {
+		return []domain.TweetWithUsername{}, nil
+	}
+	// replies are always newer than their parents
+	thread := map[uint64]struct{}{tweetID: {}}
+	for i := tweetID; i < uint64(len(ts.d.tweets)); i++ {
+		t := ts.d.tweets[i]
+		if _, ok := thread[t.InReplyTo]; ok {
+			thread[t.ID] = struct{}{}
+		}
+	}
+	delete(thread, tweetID)
+
+	return ts.d.page(page, func(t domain.Tweet) bool {
+		_, ok := thread[t.ID]
+		return ok && ts.d.visible(viewer, t)
+	}), nil
+}
+
+// tweet returns a tweet if it exists and wasn't deleted.
+// Caller should hold the read lock.
+func (d *db) tweet(id uint64) (domain.Tweet, bool) {
+	if id == 0 || id > uint64(len(d.tweets)) || d.isDeleted(id) {
+		return domain.Tweet{}, false
+	}
+	return d.tweets[id-1], true
+}
+
+// isDeleted returns true if the tweet was deleted.
+// Caller should hold the read lock.
+func (d *db) isDeleted(id uint64) bool {
+	_, ok := d.deleted[id]
+	return ok
+}
+
+// visible returns true if the viewer can see the tweet: tweets of
+// protected users are visible to their authors and the authors'
+// subscribers only.
+// Caller should hold the read lock.
+func (d *db) visible(viewer domain.UserID, t domain.Tweet) bool {
+	if !d.users[t.From].Protected || viewer == t.From {
+		return true
+	}
+	_, ok := d.subs[viewer][t.From]
+	return ok
+}
+
+// withUsername attaches author's nickname to the tweet.
+// Caller should hold the read lock.
+func (d *db) withUsername(t domain.Tweet) domain.TweetWithUsername {
+	return domain.TweetWithUsername{Tweet: t, From: d.users[t.From].Nickname}
+}
@@ -0,0 +1,187 @@
+package inmem
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userStorage implements UserStorage and PasswordManager.
+type userStorage struct {
+	d *db
+}
+
+var _ storage.UserStorage = &userStorage{}
+var _ storage.PasswordManager = &userStorage{}
+
+func (us *userStorage) New(ctx context.Context, u domain.UserWithPassword) (domain.UserID, error) {
+	pass, err := bcrypt.GenerateFromPassword([]byte(u.Password), passwordHashCost)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't generate a hash")
+	}
+
+	us.d.mtx.Lock()
+	defer us.d.mtx.Unlock()
+
+	if _, ok := us.d.nicknames[u.Nickname]; ok {
+		return 0, bizerr.New("this nickname is already taken", bizerr.ErrorConflict)
+	}
+	us.d.lastUserID++
+	u.ID = us.d.lastUserID
+	us.d.users[u.ID] = userRecord{User: u.User, password: pass}
+	us.d.nicknames[u.Nickname] = u.ID
+	return u.ID, nil
+}
+
+func (us *userStorage) Save(ctx context.Context, u domain.User) error {
+	us.d.mtx.Lock()
+	defer us.d.mtx.Unlock()
+
+	rec, ok := us.d.users[u.ID]
+	if !ok {
+		return nil
+	}
+	rec.RealName = u.RealName
+	rec.Protected = u.Protected
+	us.d.users[u.ID] = rec
+	return nil
+}
+
+func (us *userStorage) PasswordCheck(ctx context.Context, nickname string, pass string) (bool, error) {
+	us.d.mtx.RLock()
+	id, ok := us.d.nicknames[nickname]
+	pwdHash := us.d.users[id].password
+	us.d.mtx.RUnlock()
+	if !ok {
+		return false, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword(pwdHash, []byte(pass))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "error comparing hashes")
+	}
+	return true, nil
+}
+
+func (us *userStorage) GetByNickname(ctx context.Context, n string) (domain.User, error) {
+	us.d.mtx.RLock()
+	defer us.d.mtx.RUnlock()
+
+	id, ok := us.d.nicknames[n]
+	if !ok {
+		return domain.User{}, bizerr.New("user was not found", bizerr.ErrorNotFound)
+	}
+	return us.d.users[id].User, nil
+}
+
+func (us *userStorage) GetByNicknames(ctx context.Context, nicknames []string) ([]domain.User, error) {
+	us.d.mtx.RLock()
+	defer us.d.mtx.RUnlock()
+
+	ret := make([]domain.User, 0, len(nicknames))
+	for _, n := range nicknames {
+		if id, ok := us.d.nicknames[n]; ok {
+			ret = append(ret, us.d.users[id].User)
+		}
+	}
+	return ret, nil
+}
+
+func (us *userStorage) SearchUsers(ctx context.Context, viewer domain.UserID, query string, limit uint) ([]domain.User, error) {
+	us.d.mtx.RLock()
+	defer us.d.mtx.RUnlock()
+
+	type found struct {
+		domain.User
+		rank     storage.UserSearchRank
+		relation int
+	}
+	var all []found
+	for _, rec := range us.d.users {
+		rank, ok := searchRank(rec.User, query)
+		if !ok {
+			continue
+		}
+		f := found{User: rec.User, rank: rank, relation: 2}
+		if _, ok := us.d.subs[viewer][rec.ID]; ok {
+			f.relation = 0
+		} else if _, ok := us.d.subs[rec.ID][viewer]; ok {
+			f.relation = 1
+		}
+		all = append(all, f)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		a, b := all[i], all[j]
+		if a.rank != b.rank {
+			return a.rank < b.rank
+		}
+		if a.relation != b.relation {
+			return a.relation < b.relation
+		}
+		if la, lb := utf8.RuneCountInString(a.Nickname), utf8.RuneCountInString(b.Nickname); la != lb {
+			return la < lb
+		}
+		return a.Nickname < b.Nickname
+	})
+
+	ret := make([]domain.User, 0, limit)
+	for i := 0; i < len(all) && uint(i) < limit; i++ {
+		ret = append(ret, all[i].User)
+	}
+	return ret, nil
+}
+
+// searchRank returns how well the user matches the query.
+func searchRank(u domain.User, query string) (storage.UserSearchRank, bool) {
+	q := strings.ToLower(query)
+	nick, name := strings.ToLower(u.Nickname), strings.ToLower(u.RealName)
+	switch {
+	case nick == q:
+		return storage.RankExactNickname, true
+	case strings.HasPrefix(nick, q):
+		return storage.RankNicknamePrefix, true
+	case strings.HasPrefix(name, q) || strings.Contains(name, " "+q):
+		return storage.RankNamePrefix, true
+	case strings.Contains(nick, q) || strings.Contains(name, q):
+		return storage.RankSubstring, true
+	case isSubsequence(nick, q) || isSubsequence(name, q):
+		return storage.RankFuzzy, true
+	}
+	return 0, false
+}
+
+// isSubsequence returns true if s contains all the characters of sub
+// in the same order.
+func isSubsequence(s, sub string) bool {
+	for _, r := range sub {
+		i := strings.IndexRune(s, r)
+		if i == -1 {
+			return false
+		}
+		s = s[i+utf8.RuneLen(r):]
+	}
+	return true
+}
+
+func (us *userStorage) GetByIds(ctx context.Context, ids []domain.UserID) ([]domain.User, error) {
+	us.d.mtx.RLock()
+	defer us.d.mtx.RUnlock()
+
+	ret := make([]domain.User, 0, len(ids))
+	for _, id := range ids {
+		if rec, ok := us.d.users[id]; ok {
+			ret = append(ret, rec.User)
+		}
+	}
+	return ret, nil
+}
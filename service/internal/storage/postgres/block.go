@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type blockStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.BlockStorage = &blockStorage{}
+var _ storage.MuteStorage = &blockStorage{}
+
+func (bs *blockStorage) Block(ctx context.Context, user domain.UserID, blocked domain.UserID) error {
+	_, err := bs.db.ExecContext(ctx, `
+WITH b AS (
+ INSERT INTO blocks (uid,blocked) VALUES ($1,$2)
+ ON CONFLICT DO NOTHING
+), r AS (
+ DELETE FROM follow_requests WHERE (sfrom,sto) = ($1,$2) OR (sfrom,sto) = ($2,$1)
+)
+DELETE FROM subs WHERE (sfrom,sto) = ($1,$2) OR (sfrom,sto) = ($2,$1)`, user, blocked)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (bs *blockStorage) Unblock(ctx context.Context, user domain.UserID, blocked domain.UserID) error {
+	_, err := bs.db.ExecContext(ctx,
+		`DELETE FROM blocks WHERE (uid,blocked) = ($1,$2)`, user, blocked)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (bs *blockStorage) IsBlocked(ctx context.Context, a domain.UserID, b domain.UserID) (bool, error) {
+	var ret bool
+	err := bs.db.GetContext(ctx, &ret, `
+SELECT EXISTS (SELECT 1 FROM blocks WHERE (uid,blocked) = ($1,$2) OR (uid,blocked) = ($2,$1))`, a, b)
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (bs *blockStorage) Blocked(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	ret := []domain.UserID{}
+	err := bs.db.SelectContext(ctx, &ret, `SELECT blocked FROM blocks WHERE uid = $1`, user)
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (bs *blockStorage) Mute(ctx context.Context, user domain.UserID, muted domain.UserID) error {
+	_, err := bs.db.ExecContext(ctx,
+		`INSERT INTO mutes (uid,muted) VALUES ($1,$2) ON CONFLICT DO NOTHING`, user, muted)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (bs *blockStorage) Unmute(ctx context.Context, user domain.UserID, muted domain.UserID) error {
+	_, err := bs.db.ExecContext(ctx,
+		`DELETE FROM mutes WHERE (uid,muted) = ($1,$2)`, user, muted)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (bs *blockStorage) Muted(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	ret := []domain.UserID{}
+	err := bs.db.SelectContext(ctx, &ret, `SELECT muted FROM mutes WHERE uid = $1`, user)
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
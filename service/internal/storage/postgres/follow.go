@@ -0,0 +1,68 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type followStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.FollowRequestStorage = &followStorage{}
+
+// errNoFollowRequest is returned if a follow request to approve or reject
+// doesn't exist.
+var errNoFollowRequest = bizerr.New("follow request was not found", bizerr.ErrorNotFound)
+
+func (fs *followStorage) RequestFollow(ctx context.Context, from, to domain.UserID, at time.Time) error {
+	_, err := fs.db.ExecContext(ctx,
+		`INSERT INTO follow_requests (sfrom,sto,created_at) VALUES ($1,$2,$3) ON CONFLICT DO NOTHING`, from, to, at)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (fs *followStorage) FollowRequests(ctx context.Context, to domain.UserID) ([]domain.UserID, error) {
+	ret := []domain.UserID{}
+	err := fs.db.SelectContext(ctx, &ret,
+		`SELECT sfrom FROM follow_requests WHERE sto = $1 ORDER BY created_at,sfrom`, to)
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (fs *followStorage) ApproveFollow(ctx context.Context, from, to domain.UserID) error {
+	var n int
+	err := fs.db.GetContext(ctx, &n, `
+WITH r AS (
+ DELETE FROM follow_requests WHERE (sfrom,sto) = ($1,$2)
+ RETURNING sfrom,sto
+), s AS (
+ INSERT INTO subs (sfrom,sto)
+ SELECT sfrom,sto FROM r
+ ON CONFLICT DO NOTHING
+)
+SELECT COUNT(*) FROM r`, from, to)
+	if err != nil {
+		return errors.Wrap(err, "error returned from postgres")
+	}
+	if n == 0 {
+		return errNoFollowRequest
+	}
+	return nil
+}
+
+func (fs *followStorage) RejectFollow(ctx context.Context, from, to domain.UserID) error {
+	res, err := fs.db.ExecContext(ctx,
+		`DELETE FROM follow_requests WHERE (sfrom,sto) = ($1,$2)`, from, to)
+	if err != nil {
+		return errors.Wrap(err, "error returned from postgres")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNoFollowRequest
+	}
+	return nil
+}
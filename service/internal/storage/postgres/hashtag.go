@@ -0,0 +1,60 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type hashtagStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.HashtagStorage = &hashtagStorage{}
+
+func (hs *hashtagStorage) SetHashtags(ctx context.Context, tweetID uint64, tags []string) error {
+	tx, err := hs.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "couldn't begin a transaction")
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM hashtags WHERE tid = $1`, tweetID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `
+INSERT INTO hashtags (tag,tid)
+SELECT unnest($1::text[]),$2
+ON CONFLICT DO NOTHING`, pq.Array(tags), tweetID)
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "error returned from postgres")
+	}
+	return errors.Wrap(tx.Commit(), "couldn't commit a transaction")
+}
+
+func (hs *hashtagStorage) GetTagPage(ctx context.Context, viewer domain.UserID, tag string, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := hs.db.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM hashtags h
+JOIN tweets t
+ ON t.id = h.tid
+JOIN users u
+ ON t.uid = u.id
+WHERE
+h.tag = $1
+AND h.tid > $2
+AND h.tid < $3
+AND t.deleted = 0
+AND `+visibleTo("$5")+`
+ORDER BY h.tid `+order(page)+`
+LIMIT $4`, tag, after, before, page.Len, viewer)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, page)
+}
@@ -0,0 +1,78 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type inboxStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.InboxStorage = &inboxStorage{}
+
+func (is *inboxStorage) Deliver(ctx context.Context, tweetID uint64, to []domain.UserID) error {
+	_, err := is.db.ExecContext(ctx, `
+INSERT INTO inbox (uid,tid)
+SELECT unnest($1::bigint[]),$2
+ON CONFLICT DO NOTHING`, userIDArray(to), tweetID)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (is *inboxStorage) Pull(ctx context.Context, tweetID uint64) error {
+	_, err := is.db.ExecContext(ctx, `UPDATE tweets SET pulled = TRUE WHERE id = $1`, tweetID)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (is *inboxStorage) Backfill(ctx context.Context, user domain.UserID, from domain.UserID, len uint) error {
+	_, err := is.db.ExecContext(ctx, `
+INSERT INTO inbox (uid,tid)
+SELECT $1,id FROM tweets
+WHERE uid = $2 AND deleted = 0
+ORDER BY id DESC
+LIMIT $3
+ON CONFLICT DO NOTHING`, user, from, len)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (is *inboxStorage) GetInboxPage(ctx context.Context, user domain.UserID, page storage.Page, pullThreshold uint) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := is.db.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM inbox i
+JOIN tweets t
+ ON t.id = i.tid
+JOIN users u
+ ON t.uid = u.id
+WHERE
+i.uid = $1
+AND i.tid > $2
+AND i.tid < $3
+AND t.deleted = 0
+AND t.uid NOT IN (SELECT muted FROM mutes WHERE uid = $1)
+AND EXISTS (SELECT 1 FROM subs WHERE sfrom = $1 AND sto = t.uid)
+UNION
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE
+t.uid IN
+ (SELECT sto FROM subs WHERE sfrom = $1)
+AND (t.pulled OR (SELECT COUNT(*) FROM subs WHERE sto = t.uid) > $5)
+AND t.id > $2
+AND t.id < $3
+AND t.deleted = 0
+AND t.uid NOT IN (SELECT muted FROM mutes WHERE uid = $1)
+ORDER BY 1 `+order(page)+`
+LIMIT $4`, user, after, before, page.Len, pullThreshold)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, page)
+}
@@ -0,0 +1,92 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type mentionStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.MentionStorage = &mentionStorage{}
+
+func (ms *mentionStorage) SetMentions(ctx context.Context, tweetID uint64, mentions []domain.Mention) error {
+	uids := make([]domain.UserID, len(mentions))
+	starts := make([]int64, len(mentions))
+	ends := make([]int64, len(mentions))
+	for i, m := range mentions {
+		uids[i], starts[i], ends[i] = m.UserID, int64(m.Start), int64(m.End)
+	}
+
+	tx, err := ms.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "couldn't begin a transaction")
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM mentions WHERE tid = $1`, tweetID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `
+INSERT INTO mentions (tid,uid,start_pos,end_pos)
+SELECT $1,unnest($2::bigint[]),unnest($3::int[]),unnest($4::int[])`,
+			tweetID, userIDArray(uids), pq.Array(starts), pq.Array(ends))
+	}
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "error returned from postgres")
+	}
+	return errors.Wrap(tx.Commit(), "couldn't commit a transaction")
+}
+
+func (ms *mentionStorage) Mentions(ctx context.Context, tweetIDs []uint64) (map[uint64][]domain.Mention, error) {
+	rows, err := ms.db.QueryContext(ctx, `
+SELECT m.tid,m.uid,u.nickname,m.start_pos,m.end_pos
+FROM mentions m
+JOIN users u
+ ON m.uid = u.id
+WHERE m.tid = ANY($1)
+ORDER BY m.tid,m.start_pos`, tweetIDArray(tweetIDs))
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	defer rows.Close()
+
+	ret := map[uint64][]domain.Mention{}
+	for rows.Next() {
+		var id uint64
+		var m domain.Mention
+		err := rows.Scan(&id, &m.UserID, &m.Nickname, &m.Start, &m.End)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret[id] = append(ret[id], m)
+	}
+	return ret, errors.Wrap(rows.Err(), "error when reading rows from SQL")
+}
+
+func (ms *mentionStorage) GetMentionsPage(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := ms.db.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE
+t.id IN
+ (SELECT tid FROM mentions WHERE uid = $1)
+AND t.id > $2
+AND t.id < $3
+AND t.deleted = 0
+AND `+visibleTo("$1")+`
+ORDER BY t.id `+order(page)+`
+LIMIT $4`, user, after, before, page.Len)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, page)
+}
@@ -0,0 +1,225 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type messageStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.MessageStorage = &messageStorage{}
+
+// errNoConversation is returned if a conversation doesn't exist
+// or it's not visible to the user.
+var errNoConversation = bizerr.New("conversation was not found", bizerr.ErrorNotFound)
+
+func (ms *messageStorage) NewConversation(ctx context.Context, members []domain.UserID, at time.Time) (uint64, error) {
+	ids := make([]int64, len(members))
+	for i, uid := range members {
+		ids[i] = int64(uid)
+	}
+	var ret uint64
+	err := ms.db.GetContext(ctx, &ret, `
+WITH c AS (
+ INSERT INTO conversations (created_at) VALUES ($1)
+ RETURNING id
+), m AS (
+ INSERT INTO conversation_members (cid,uid)
+ SELECT c.id,u FROM c, unnest($2::BIGINT[]) u
+ ON CONFLICT DO NOTHING
+)
+SELECT id FROM c`, at, pq.Array(ids))
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (ms *messageStorage) DirectConversation(ctx context.Context, a, b domain.UserID) (uint64, error) {
+	var ret uint64
+	err := ms.db.GetContext(ctx, &ret, `
+SELECT cid FROM conversation_members
+WHERE
+cid IN
+ (SELECT cid FROM conversation_members WHERE uid = $1)
+AND cid IN
+ (SELECT cid FROM conversation_members WHERE uid = $2)
+GROUP BY cid
+HAVING COUNT(*) = 2
+ORDER BY cid
+LIMIT 1`, a, b)
+	if err == sql.ErrNoRows {
+		return 0, errNoConversation
+	}
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (ms *messageStorage) Conversation(ctx context.Context, id uint64, user domain.UserID) (domain.Conversation, error) {
+	ret, err := ms.conversations(ctx, user, id)
+	if err != nil {
+		return domain.Conversation{}, err
+	}
+	if len(ret) == 0 {
+		return domain.Conversation{}, errNoConversation
+	}
+	return ret[0], nil
+}
+
+func (ms *messageStorage) Conversations(ctx context.Context, user domain.UserID) ([]domain.Conversation, error) {
+	return ms.conversations(ctx, user, 0)
+}
+
+// conversations returns user's conversations, or a single one
+// if id is not zero.
+func (ms *messageStorage) conversations(ctx context.Context, user domain.UserID, id uint64) ([]domain.Conversation, error) {
+	rows, err := ms.db.QueryContext(ctx, `
+SELECT c.id,cm.last_read,
+ (SELECT COUNT(*) FROM messages um WHERE um.cid = c.id AND um.id > cm.last_read AND um.uid != cm.uid),
+ m.id,m.uid,m.created_at,m.text
+FROM conversation_members cm
+JOIN conversations c
+ ON c.id = cm.cid
+LEFT JOIN messages m
+ ON m.id = c.last_message
+WHERE cm.uid = $1
+AND ($2 = 0 OR c.id = $2)
+ORDER BY c.last_message DESC, c.id DESC`, user, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	ret, err := scanConversations(rows)
+	if err != nil || len(ret) == 0 {
+		return ret, err
+	}
+
+	rows, err = ms.db.QueryContext(ctx, `
+SELECT cid,uid FROM conversation_members
+WHERE cid IN
+ (SELECT cid FROM conversation_members WHERE uid = $1 AND ($2 = 0 OR cid = $2))
+ORDER BY cid,uid`, user, id)
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return ret, scanMembers(rows, ret)
+}
+
+func (ms *messageStorage) SendMessage(ctx context.Context, m domain.Message) (uint64, error) {
+	var ret uint64
+	err := ms.db.GetContext(ctx, &ret, `
+WITH m AS (
+ INSERT INTO messages (cid,uid,created_at,text) VALUES ($1,$2,$3,$4)
+ RETURNING id
+), c AS (
+ UPDATE conversations SET last_message = m.id FROM m
+ WHERE conversations.id = $1
+), r AS (
+ UPDATE conversation_members SET last_read = m.id FROM m
+ WHERE (cid,uid) = ($1,$2)
+)
+SELECT id FROM m`, m.ConversationID, m.From, m.At, m.Text)
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (ms *messageStorage) GetMessagePage(ctx context.Context, conversationID uint64, page storage.Page) ([]domain.Message, error) {
+	after, before := page.Bounds()
+	rows, err := ms.db.QueryContext(ctx, `
+SELECT id,cid,uid,created_at,text
+FROM messages
+WHERE cid = $1
+AND id > $2
+AND id < $3
+ORDER BY id `+order(page)+`
+LIMIT $4`, conversationID, after, before, page.Len)
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	defer rows.Close()
+
+	ret := make([]domain.Message, 0, page.Len)
+	for rows.Next() {
+		var m domain.Message
+		err := rows.Scan(&m.ID, &m.ConversationID, &m.From, &m.At, &m.Text)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret = append(ret, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error when reading rows from SQL")
+	}
+	return ret, nil
+}
+
+func (ms *messageStorage) MarkRead(ctx context.Context, conversationID uint64, user domain.UserID) error {
+	_, err := ms.db.ExecContext(ctx, `
+UPDATE conversation_members
+SET last_read = (SELECT last_message FROM conversations WHERE id = $1)
+WHERE (cid,uid) = ($1,$2)`, conversationID, user)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+// scanConversations reads conversations selected as
+// (id,last_read,unread,message's id,uid,created_at,text)
+// and closes the rows.
+func scanConversations(rows *sql.Rows) ([]domain.Conversation, error) {
+	defer rows.Close()
+
+	ret := []domain.Conversation{}
+	for rows.Next() {
+		var c domain.Conversation
+		var m struct {
+			ID   *uint64
+			From *domain.UserID
+			At   *time.Time
+			Text *string
+		}
+		err := rows.Scan(&c.ID, &c.LastRead, &c.Unread, &m.ID, &m.From, &m.At, &m.Text)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		if m.ID != nil {
+			c.LastMessage = &domain.Message{
+				ID:             *m.ID,
+				ConversationID: c.ID,
+				From:           *m.From,
+				At:             *m.At,
+				Text:           *m.Text,
+			}
+		}
+		ret = append(ret, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error when reading rows from SQL")
+	}
+	return ret, nil
+}
+
+// scanMembers reads conversation members selected as (cid,uid)
+// into their conversations and closes the rows.
+func scanMembers(rows *sql.Rows, convs []domain.Conversation) error {
+	defer rows.Close()
+
+	byID := make(map[uint64]*domain.Conversation, len(convs))
+	for i := range convs {
+		byID[convs[i].ID] = &convs[i]
+	}
+	for rows.Next() {
+		var cid uint64
+		var uid domain.UserID
+		err := rows.Scan(&cid, &uid)
+		if err != nil {
+			return errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		if c, ok := byID[cid]; ok {
+			c.Members = append(c.Members, uid)
+		}
+	}
+	return errors.Wrap(rows.Err(), "error when reading rows from SQL")
+}
@@ -0,0 +1,73 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type notificationStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.NotificationStorage = &notificationStorage{}
+
+func (ns *notificationStorage) Notify(ctx context.Context, user domain.UserID, n storage.Notification) (uint64, error) {
+	var ret uint64
+	err := ns.db.GetContext(ctx, &ret,
+		`INSERT INTO notifications (uid,kind,from_uid,tid,created_at) VALUES ($1,$2,$3,$4,$5) ON CONFLICT DO NOTHING RETURNING id`,
+		user, n.Kind, n.From, n.TweetID, n.At)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (ns *notificationStorage) GetNotificationsPage(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.Notification, error) {
+	after, before := page.Bounds()
+	rows, err := ns.db.QueryContext(ctx, `
+SELECT n.id,n.kind,u.nickname,n.tid,n.created_at,n.read
+FROM notifications n
+JOIN users u
+ ON n.from_uid = u.id
+WHERE n.uid = $1
+AND n.id > $2
+AND n.id < $3
+ORDER BY n.id `+order(page)+`
+LIMIT $4`, user, after, before, page.Len)
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	defer rows.Close()
+
+	ret := make([]domain.Notification, 0, page.Len)
+	for rows.Next() {
+		var n domain.Notification
+		err := rows.Scan(&n.ID, &n.Kind, &n.From, &n.TweetID, &n.At, &n.Read)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error when reading rows from SQL")
+	}
+	return ret, nil
+}
+
+func (ns *notificationStorage) UnreadNotifications(ctx context.Context, user domain.UserID) (uint64, error) {
+	var ret uint64
+	err := ns.db.GetContext(ctx, &ret, `SELECT COUNT(*) FROM notifications WHERE uid = $1 AND NOT read`, user)
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (ns *notificationStorage) ReadNotifications(ctx context.Context, user domain.UserID, upTo uint64) error {
+	_, err := ns.db.ExecContext(ctx, `
+UPDATE notifications SET read = TRUE
+WHERE uid = $1 AND NOT read AND ($2 = 0 OR id <= $2)`, user, upTo)
+	return errors.Wrap(err, "error returned from postgres")
+}
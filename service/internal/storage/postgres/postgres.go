@@ -0,0 +1,107 @@
+/*Package postgres provides PostgreSQL-backed storage.
+ */
+package postgres
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/mattes/migrate/database/postgres"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/lib/migrator"
+	embedded "github.com/utrack/woofer/migrations"
+)
+
+const passwordHashCost = 12
+
+// pgUniqueViolation is a PostgreSQL error code for unique_violation.
+const pgUniqueViolation = "23505"
+
+// Storage implements complete storage.Storage using PostgreSQL as a backend.
+type Storage struct {
+	userStorage
+	tweetStorage
+	subsStorage
+	followStorage
+	blockStorage
+	inboxStorage
+	likeStorage
+	mentionStorage
+	hashtagStorage
+	messageStorage
+	notificationStorage
+}
+
+// New creates a new PostgreSQL-backed storage.
+// It refuses to use a database with outdated schema, see NewMigrator.
+func New(connstring string, migrations string) (*Storage, error) {
+	db, err := sqlx.Connect("postgres", connstring)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't init postgres connection")
+	}
+
+	m, err := newMigrator(db, migrations)
+	if err != nil {
+		return nil, err
+	}
+	err = m.CheckCurrent()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Storage{
+		userStorage{
+			db: db,
+		},
+		tweetStorage{
+			db: db,
+		},
+		subsStorage{
+			db: db,
+		},
+		followStorage{
+			db: db,
+		},
+		blockStorage{
+			db: db,
+		},
+		inboxStorage{
+			db: db,
+		},
+		likeStorage{
+			db: db,
+		},
+		mentionStorage{
+			db: db,
+		},
+		hashtagStorage{
+			db: db,
+		},
+		messageStorage{
+			db: db,
+		},
+		notificationStorage{
+			db: db,
+		},
+	}, nil
+}
+
+// NewMigrator creates a schema migrator for the database.
+// Migrations embedded into the binary are used if the migrations path is empty.
+func NewMigrator(connstring string, migrations string) (*migrator.Migrator, error) {
+	db, err := sqlx.Connect("postgres", connstring)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't init postgres connection")
+	}
+	return newMigrator(db, migrations)
+}
+
+func newMigrator(db *sqlx.DB, migrations string) (*migrator.Migrator, error) {
+	dri, err := postgres.WithInstance(db.DB, &postgres.Config{})
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't init migration driver")
+	}
+	src, err := migrator.OpenSource(migrations, embedded.FS, "postgres")
+	if err != nil {
+		return nil, err
+	}
+	return migrator.New(src, "postgres", dri)
+}
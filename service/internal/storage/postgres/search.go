@@ -0,0 +1,62 @@
+package postgres
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+var _ storage.TweetSearcher = &tweetStorage{}
+
+// SearchTweets uses the full-text index of tweets ranked by ts_rank.
+func (ts *tweetStorage) SearchTweets(ctx context.Context, q storage.SearchQuery, offset uint, limit uint) ([]domain.TweetWithUsername, error) {
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	orderBy := `t.id DESC`
+	var where []string
+	if q.HasText() {
+		var query []string
+		if len(q.Words) > 0 {
+			query = append(query, `plainto_tsquery('simple', `+arg(strings.Join(q.Words, " "))+`)`)
+		}
+		for _, p := range q.Phrases {
+			query = append(query, `phraseto_tsquery('simple', `+arg(p)+`)`)
+		}
+		match := strings.Join(query, " && ")
+		where = append(where, `to_tsvector('simple', t.text) @@ (`+match+`)`)
+		orderBy = `ts_rank(to_tsvector('simple', t.text), ` + match + `) DESC, t.id DESC`
+	}
+	where = append(where, `t.deleted = 0`, visibleTo(arg(q.Viewer)))
+	if q.From != "" {
+		where = append(where, `u.nickname = `+arg(q.From))
+	}
+	if !q.Since.IsZero() {
+		where = append(where, `t.created_at >= `+arg(q.Since))
+	}
+	if !q.Until.IsZero() {
+		where = append(where, `t.created_at < `+arg(q.Until))
+	}
+
+	rows, err := ts.db.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE
+`+strings.Join(where, "\nAND ")+`
+ORDER BY `+orderBy+`
+LIMIT `+arg(limit)+` OFFSET `+arg(offset), args...)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, storage.Page{Len: limit})
+}
@@ -0,0 +1,49 @@
+package postgres
+
+import (
+	"os"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/utrack/woofer/service/internal/storage"
+	"github.com/utrack/woofer/service/internal/storage/storagetest"
+)
+
+// envTestDB is an environment variable containing a connection string of
+// a database the tests may wipe out.
+const envTestDB = "WOOFER_TEST_PGDB"
+
+func TestStorage(t *testing.T) {
+	connstring := os.Getenv(envTestDB)
+	if connstring == "" {
+		t.Skip(envTestDB + " is not set")
+	}
+
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		db, err := sqlx.Connect("postgres", connstring)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		_, err = db.Exec(`DROP SCHEMA public CASCADE; CREATE SCHEMA public;`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		m, err := NewMigrator(connstring, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Close()
+		err = m.Up()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := New(connstring, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
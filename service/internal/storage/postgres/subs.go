@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type subsStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.SubsStorage = &subsStorage{}
+
+func (s *subsStorage) Subscribe(ctx context.Context, from, to domain.UserID) error {
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO subs (sfrom,sto) VALUES ($1,$2) ON CONFLICT DO NOTHING`, from, to)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (s *subsStorage) Unsubscribe(ctx context.Context, from, to domain.UserID) error {
+	_, err := s.db.ExecContext(ctx,
+		`DELETE FROM subs WHERE sfrom = $1 AND sto = $2`, from, to)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (s *subsStorage) IsSubscribed(ctx context.Context, from, to domain.UserID) (bool, error) {
+	var ret bool
+	err := s.db.GetContext(ctx, &ret,
+		`SELECT EXISTS (SELECT 1 FROM subs WHERE (sfrom,sto) = ($1,$2))`, from, to)
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (s *subsStorage) Subs(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	ret := make([]domain.UserID, 0, 100)
+	err := s.db.SelectContext(ctx, &ret, `SELECT sto FROM subs WHERE sfrom = $1`, user)
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return ret, nil
+}
+
+func (s *subsStorage) Subbed(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	ret := make([]domain.UserID, 0, 100)
+	err := s.db.SelectContext(ctx, &ret, `SELECT sfrom FROM subs WHERE sto = $1`, user)
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return ret, nil
+}
@@ -0,0 +1,257 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type tweetStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.TweetStorage = &tweetStorage{}
+
+func (ts *tweetStorage) Tweet(ctx context.Context, t domain.Tweet) (uint64, error) {
+	var ret uint64
+	err := ts.db.QueryRowContext(ctx,
+		`INSERT INTO tweets (uid,created_at,text,reply_to,retweet_of) VALUES ($1,$2,$3,$4,$5) RETURNING id`, t.From, t.At, t.Text, t.InReplyTo, t.RetweetOf).Scan(&ret)
+	if err, ok := err.(*pq.Error); ok && err.Code == pgUniqueViolation && err.Constraint == "idx_tweets_retweet" {
+		return 0, bizerr.New("this tweet was already retweeted", bizerr.ErrorConflict)
+	}
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (ts *tweetStorage) Edit(ctx context.Context, tweetID uint64, text string, at time.Time) error {
+	var n int
+	err := ts.db.QueryRowContext(ctx, `
+WITH old AS (
+ SELECT id,COALESCE(edited_at,created_at) AS at,text FROM tweets
+ WHERE id = $1 AND deleted = 0
+ FOR UPDATE
+), rev AS (
+ INSERT INTO tweet_revisions (tid,created_at,text)
+ SELECT id,at,text FROM old
+), upd AS (
+ UPDATE tweets SET text = $2, edited_at = $3
+ WHERE id IN (SELECT id FROM old)
+ RETURNING id
+)
+SELECT COUNT(*) FROM upd`, tweetID, text, at).Scan(&n)
+	if err != nil {
+		return errors.Wrap(err, "error returned from postgres")
+	}
+	if n == 0 {
+		return bizerr.New("tweet was not found", bizerr.ErrorNotFound)
+	}
+	return nil
+}
+
+func (ts *tweetStorage) Delete(ctx context.Context, tweetID uint64) error {
+	_, err := ts.db.ExecContext(ctx, `UPDATE tweets SET deleted = 1
+WHERE id = $1 OR (retweet_of = $1 AND text = '')`, tweetID)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (ts *tweetStorage) Revisions(ctx context.Context, tweetID uint64) ([]domain.TweetRevision, error) {
+	ret := []domain.TweetRevision{}
+	err := ts.db.SelectContext(ctx, &ret, `
+SELECT r.text,r.created_at AS at
+FROM tweet_revisions r
+JOIN tweets t
+ ON t.id = r.tid
+WHERE r.tid = $1 AND t.deleted = 0
+ORDER BY r.id`, tweetID)
+	return ret, errors.Wrap(err, "error returned from postgres")
+}
+
+func (ts *tweetStorage) ByID(ctx context.Context, id uint64) (domain.Tweet, error) {
+	var ret domain.Tweet
+	row := ts.db.QueryRowContext(ctx, `SELECT id,uid,created_at,text,reply_to,retweet_of,edited_at FROM tweets WHERE id = $1 AND deleted = 0`, id)
+	err := row.Scan(&ret.ID, &ret.From, &ret.At, &ret.Text, &ret.InReplyTo, &ret.RetweetOf, &ret.EditedAt)
+	if err == sql.ErrNoRows {
+		return ret, bizerr.New("tweet was not found", bizerr.ErrorNotFound)
+	}
+	return ret, errors.Wrap(err, "error when scanning tweet")
+}
+
+func (ts *tweetStorage) ByIDs(ctx context.Context, ids []uint64) ([]domain.TweetWithUsername, error) {
+	rows, err := ts.db.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE t.id = ANY($1)
+AND t.deleted = 0
+ORDER BY t.id DESC`, tweetIDArray(ids))
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, storage.Page{Len: uint(len(ids))})
+}
+
+func (ts *tweetStorage) GetPageForUser(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := ts.db.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+JOIN subs s
+ ON s.sto = t.uid AND s.sfrom = $1
+WHERE t.id > $2
+AND t.id < $3
+AND t.deleted = 0
+AND t.uid NOT IN (SELECT muted FROM mutes WHERE uid = $1)
+ORDER BY t.id `+order(page)+`
+LIMIT $4`, user, after, before, page.Len)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, page)
+}
+
+func (ts *tweetStorage) GetPageForProfile(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := ts.db.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE
+t.uid = $1
+AND t.id > $2
+AND t.id < $3
+AND t.deleted = 0
+ORDER BY t.id `+order(page)+`
+LIMIT $4`, user, after, before, page.Len)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, page)
+}
+
+func (ts *tweetStorage) GetPage(ctx context.Context, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := ts.db.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE
+t.id > $1
+AND t.id < $2
+AND t.deleted = 0
+ORDER BY t.id `+order(page)+`
+LIMIT $3`, after, before, page.Len)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, page)
+}
+
+func (ts *tweetStorage) Ancestors(ctx context.Context, viewer domain.UserID, tweetID uint64, len uint) ([]domain.TweetWithUsername, error) {
+	rows, err := ts.db.QueryContext(ctx, `
+WITH RECURSIVE anc(id,depth) AS (
+ SELECT reply_to,1 FROM tweets WHERE id = $1 AND reply_to != 0
+ UNION ALL
+ SELECT t.reply_to,anc.depth+1
+ FROM tweets t
+ JOIN anc
+  ON t.id = anc.id
+ WHERE t.reply_to != 0 AND anc.depth < $2
+)
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM anc
+JOIN tweets t
+ ON t.id = anc.id
+JOIN users u
+ ON t.uid = u.id
+WHERE t.deleted = 0
+AND `+visibleTo("$3")+`
+ORDER BY anc.depth DESC`, tweetID, len, viewer)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	// ancestors are already ordered from the oldest one
+	return scanTweets(rows, storage.Page{Len: len})
+}
+
+func (ts *tweetStorage) Descendants(ctx context.Context, viewer domain.UserID, tweetID uint64, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := ts.db.QueryContext(ctx, `
+WITH RECURSIVE thread(id) AS (
+ SELECT id FROM tweets WHERE reply_to = $1
+ UNION ALL
+ SELECT t.id
+ FROM tweets t
+ JOIN thread
+  ON t.reply_to = thread.id
+)
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM thread
+JOIN tweets t
+ ON t.id = thread.id
+JOIN users u
+ ON t.uid = u.id
+WHERE
+t.id > $2
+AND t.id < $3
+AND t.deleted = 0
+AND `+visibleTo("$5")+`
+ORDER BY t.id `+order(page)+`
+LIMIT $4`, tweetID, after, before, page.Len, viewer)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	return scanTweets(rows, page)
+}
+
+// visibleTo returns an SQL condition selecting tweets t of users u
+// the viewer passed as param can see: tweets of protected users are
+// visible to their authors and the authors' subscribers only.
+func visibleTo(param string) string {
+	return `(NOT u.protected OR t.uid = ` + param + ` OR EXISTS (
+ SELECT 1 FROM subs s WHERE s.sfrom = ` + param + ` AND s.sto = t.uid))`
+}
+
+// order returns an SQL sort order to read the page in.
+func order(page storage.Page) string {
+	if page.Ascending() {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// scanTweets reads tweets selected as
+// (id,nickname,created_at,text,reply_to,retweet_of,edited_at)
+// and closes the rows.
+func scanTweets(rows *sql.Rows, page storage.Page) ([]domain.TweetWithUsername, error) {
+	defer rows.Close()
+
+	ret := make([]domain.TweetWithUsername, 0, page.Len)
+	for rows.Next() {
+		var tweet domain.TweetWithUsername
+		err := rows.Scan(&tweet.ID, &tweet.From, &tweet.At, &tweet.Text, &tweet.InReplyTo, &tweet.RetweetOf, &tweet.EditedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret = append(ret, tweet)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error when reading rows from SQL")
+	}
+	return ret, nil
+}
@@ -0,0 +1,170 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// userStorage implements UserStorage and PasswordManager.
+type userStorage struct {
+	db *sqlx.DB
+}
+
+var _ storage.UserStorage = &userStorage{}
+var _ storage.PasswordManager = &userStorage{}
+
+func (us *userStorage) New(ctx context.Context, u domain.UserWithPassword) (domain.UserID, error) {
+	pass, err := bcrypt.GenerateFromPassword([]byte(u.Password), passwordHashCost)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't generate a hash")
+	}
+
+	var ret domain.UserID
+	err = us.db.QueryRowContext(ctx,
+		`INSERT INTO users (name,nickname,password,protected) VALUES ($1,$2,$3,$4) RETURNING id`, u.RealName, u.Nickname, pass, u.Protected).Scan(&ret)
+	if err != nil {
+		if err, ok := err.(*pq.Error); ok && err.Code == pgUniqueViolation {
+			return 0, bizerr.New("this nickname is already taken", bizerr.ErrorConflict)
+		}
+		return 0, errors.Wrap(err, "error returned from postgres")
+	}
+	return ret, nil
+}
+
+func (us *userStorage) Save(ctx context.Context, u domain.User) error {
+	_, err := us.db.ExecContext(ctx,
+		`UPDATE users SET name = $1, protected = $2 WHERE id = $3`, u.RealName, u.Protected, u.ID)
+	return errors.Wrap(err, "error returned from postgres")
+}
+
+func (us *userStorage) PasswordCheck(ctx context.Context, nickname string, pass string) (bool, error) {
+	var pwdHash []byte
+	err := us.db.GetContext(ctx, &pwdHash, `SELECT password FROM users WHERE nickname = $1`, nickname)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "error returned from postgres")
+	}
+	err = bcrypt.CompareHashAndPassword(pwdHash, []byte(pass))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.Wrap(err, "error comparing hashes")
+	}
+	return true, nil
+}
+
+func (us *userStorage) GetByNickname(ctx context.Context, n string) (domain.User, error) {
+	var ret domain.User
+	row := us.db.QueryRowContext(ctx, `SELECT id,name,nickname,protected FROM users WHERE nickname = $1`, n)
+	err := row.Scan(&ret.ID, &ret.RealName, &ret.Nickname, &ret.Protected)
+	if err == sql.ErrNoRows {
+		return ret, bizerr.New("user was not found", bizerr.ErrorNotFound)
+	}
+	return ret, errors.Wrap(err, "error when scanning user")
+}
+
+func (us *userStorage) GetByIds(ctx context.Context, ids []domain.UserID) ([]domain.User, error) {
+	return us.getUsers(ctx, `SELECT id,name,nickname,protected FROM users WHERE id = ANY($1)`, userIDArray(ids))
+}
+
+func (us *userStorage) GetByNicknames(ctx context.Context, nicknames []string) ([]domain.User, error) {
+	return us.getUsers(ctx, `SELECT id,name,nickname,protected FROM users WHERE nickname = ANY($1)`, pq.Array(nicknames))
+}
+
+func (us *userStorage) SearchUsers(ctx context.Context, viewer domain.UserID, query string, len uint) ([]domain.User, error) {
+	p := newSearchPatterns(query)
+	// ranks are storage.UserSearchRank
+	return us.getUsers(ctx, `
+SELECT u.id,u.name,u.nickname,u.protected
+FROM (
+ SELECT id,name,nickname,protected,
+  CASE
+   WHEN nickname ILIKE $1 THEN 0
+   WHEN nickname ILIKE $2 THEN 1
+   WHEN name ILIKE $2 OR name ILIKE $3 THEN 2
+   WHEN nickname ILIKE $4 OR name ILIKE $4 THEN 3
+   ELSE 4
+  END AS rank
+ FROM users
+ WHERE nickname ILIKE $5 OR name ILIKE $5
+) u
+ORDER BY
+ u.rank,
+ CASE
+  WHEN EXISTS (SELECT 1 FROM subs WHERE sfrom = $6 AND sto = u.id) THEN 0
+  WHEN EXISTS (SELECT 1 FROM subs WHERE sfrom = u.id AND sto = $6) THEN 1
+  ELSE 2
+ END,
+ length(u.nickname),
+ u.nickname
+LIMIT $7`, p.exact, p.prefix, p.wordPrefix, p.substring, p.fuzzy, viewer, len)
+}
+
+// searchPatterns are LIKE patterns matching a user search query.
+type searchPatterns struct {
+	exact      string
+	prefix     string
+	wordPrefix string
+	substring  string
+	fuzzy      string
+}
+
+func newSearchPatterns(query string) searchPatterns {
+	q := likeEscaper.Replace(query)
+	fuzzy := "%"
+	for _, r := range query {
+		fuzzy += likeEscaper.Replace(string(r)) + "%"
+	}
+	return searchPatterns{
+		exact:      q,
+		prefix:     q + "%",
+		wordPrefix: "% " + q + "%",
+		substring:  "%" + q + "%",
+		fuzzy:      fuzzy,
+	}
+}
+
+// likeEscaper escapes LIKE wildcards, backslash is the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// getUsers reads users selected as (id,name,nickname,protected).
+func (us *userStorage) getUsers(ctx context.Context, q string, args ...interface{}) ([]domain.User, error) {
+	rows, err := us.db.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from postgres")
+	}
+	defer rows.Close()
+
+	ret := []domain.User{}
+	for rows.Next() {
+		var user domain.User
+		err := rows.Scan(&user.ID, &user.RealName, &user.Nickname, &user.Protected)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret = append(ret, user)
+	}
+
+	return ret, nil
+}
+
+// userIDArray converts IDs to a value that can be passed as a bigint[] parameter.
+func userIDArray(ids []domain.UserID) interface{} {
+	ret := make([]int64, len(ids))
+	for i := range ids {
+		ret[i] = int64(ids[i])
+	}
+	return pq.Array(ret)
+}
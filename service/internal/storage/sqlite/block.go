@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type blockStorage struct {
+	c *conn
+}
+
+var _ storage.BlockStorage = &blockStorage{}
+var _ storage.MuteStorage = &blockStorage{}
+
+func (bs *blockStorage) Block(ctx context.Context, user domain.UserID, blocked domain.UserID) error {
+	return bs.c.Tx(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO blocks (uid,blocked) VALUES (?,?)`, user, blocked)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM subs WHERE (sfrom,sto) = (?1,?2) OR (sfrom,sto) = (?2,?1)`, user, blocked)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`DELETE FROM follow_requests WHERE (sfrom,sto) = (?1,?2) OR (sfrom,sto) = (?2,?1)`, user, blocked)
+		return err
+	})
+}
+
+func (bs *blockStorage) Unblock(ctx context.Context, user domain.UserID, blocked domain.UserID) error {
+	_, err := bs.c.ExecContext(ctx,
+		`DELETE FROM blocks WHERE (uid,blocked) = (?,?)`, user, blocked)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (bs *blockStorage) IsBlocked(ctx context.Context, a domain.UserID, b domain.UserID) (bool, error) {
+	var ret bool
+	err := bs.c.GetContext(ctx, &ret, `
+SELECT EXISTS (SELECT 1 FROM blocks WHERE (uid,blocked) = (?1,?2) OR (uid,blocked) = (?2,?1))`, a, b)
+	return ret, errors.Wrap(err, "error returned from sqlite")
+}
+
+func (bs *blockStorage) Blocked(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	ret := []domain.UserID{}
+	err := bs.c.sq.SelectContext(ctx, &ret, `SELECT blocked FROM blocks WHERE uid = ?`, user)
+	return ret, errors.Wrap(err, "error returned from sqlite")
+}
+
+func (bs *blockStorage) Mute(ctx context.Context, user domain.UserID, muted domain.UserID) error {
+	_, err := bs.c.ExecContext(ctx,
+		`INSERT OR IGNORE INTO mutes (uid,muted) VALUES (?,?)`, user, muted)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (bs *blockStorage) Unmute(ctx context.Context, user domain.UserID, muted domain.UserID) error {
+	_, err := bs.c.ExecContext(ctx,
+		`DELETE FROM mutes WHERE (uid,muted) = (?,?)`, user, muted)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (bs *blockStorage) Muted(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
+	ret := []domain.UserID{}
+	err := bs.c.sq.SelectContext(ctx, &ret, `SELECT muted FROM mutes WHERE uid = ?`, user)
+	return ret, errors.Wrap(err, "error returned from sqlite")
+}
@@ -0,0 +1,63 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type followStorage struct {
+	c *conn
+}
+
+var _ storage.FollowRequestStorage = &followStorage{}
+
+// errNoFollowRequest is returned if a follow request to approve or reject
+// doesn't exist.
+var errNoFollowRequest = bizerr.New("follow request was not found", bizerr.ErrorNotFound)
+
+func (fs *followStorage) RequestFollow(ctx context.Context, from, to domain.UserID, at time.Time) error {
+	_, err := fs.c.ExecContext(ctx,
+		`INSERT OR IGNORE INTO follow_requests (sfrom,sto,created_at) VALUES (?,?,?)`, from, to, at)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (fs *followStorage) FollowRequests(ctx context.Context, to domain.UserID) ([]domain.UserID, error) {
+	ret := []domain.UserID{}
+	err := fs.c.sq.SelectContext(ctx, &ret,
+		`SELECT sfrom FROM follow_requests WHERE sto = ? ORDER BY created_at,sfrom`, to)
+	return ret, errors.Wrap(err, "error returned from sqlite")
+}
+
+func (fs *followStorage) ApproveFollow(ctx context.Context, from, to domain.UserID) error {
+	return fs.c.Tx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx,
+			`DELETE FROM follow_requests WHERE (sfrom,sto) = (?,?)`, from, to)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return errNoFollowRequest
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO subs (sfrom,sto) VALUES (?,?)`, from, to)
+		return err
+	})
+}
+
+func (fs *followStorage) RejectFollow(ctx context.Context, from, to domain.UserID) error {
+	res, err := fs.c.ExecContext(ctx,
+		`DELETE FROM follow_requests WHERE (sfrom,sto) = (?,?)`, from, to)
+	if err != nil {
+		return errors.Wrap(err, "error returned from sqlite")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errNoFollowRequest
+	}
+	return nil
+}
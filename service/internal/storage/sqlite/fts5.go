@@ -0,0 +1,21 @@
+package sqlite
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+)
+
+// checkFTS5 checks that SQLite is compiled with the FTS5 extension tweet
+// search relies on. go-sqlite3 compiles it in with the sqlite_fts5 build
+// tag only, see Makefile.
+func checkFTS5(db *sqlx.DB) error {
+	var ok bool
+	err := db.Get(&ok, `SELECT sqlite_compileoption_used('ENABLE_FTS5')`)
+	if err != nil {
+		return errors.Wrap(err, "couldn't check SQLite compile options")
+	}
+	if !ok {
+		return errors.New("SQLite is built without FTS5 which tweet search needs, build woofer with `-tags sqlite_fts5`")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type hashtagStorage struct {
+	c *conn
+}
+
+var _ storage.HashtagStorage = &hashtagStorage{}
+
+func (hs *hashtagStorage) SetHashtags(ctx context.Context, tweetID uint64, tags []string) error {
+	return hs.c.Tx(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM hashtags WHERE tid = ?`, tweetID)
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO hashtags (tag,tid) VALUES (?,?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, tag := range tags {
+			_, err = stmt.ExecContext(ctx, tag, tweetID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (hs *hashtagStorage) GetTagPage(ctx context.Context, viewer domain.UserID, tag string, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := hs.c.sq.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM hashtags h
+JOIN tweets t
+ ON t.id = h.tid
+JOIN users u
+ ON t.uid = u.id
+WHERE
+h.tag = ?1
+AND h.tid > ?2
+AND h.tid < ?3
+AND t.deleted = 0
+AND `+visibleTo("?5")+`
+ORDER BY h.tid `+order(page)+`
+LIMIT ?4`, tag, after, before, page.Len, viewer)
+
+	if err != nil {
+		return nil, err
+	}
+	return scanTweets(rows, page)
+}
@@ -0,0 +1,86 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type inboxStorage struct {
+	c *conn
+}
+
+var _ storage.InboxStorage = &inboxStorage{}
+
+func (is *inboxStorage) Deliver(ctx context.Context, tweetID uint64, to []domain.UserID) error {
+	return is.c.Tx(ctx, func(tx *sqlx.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, `INSERT OR IGNORE INTO inbox (uid,tid) VALUES (?,?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, uid := range to {
+			_, err = stmt.ExecContext(ctx, uid, tweetID)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (is *inboxStorage) Pull(ctx context.Context, tweetID uint64) error {
+	_, err := is.c.ExecContext(ctx, `UPDATE tweets SET pulled = 1 WHERE id = ?`, tweetID)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (is *inboxStorage) Backfill(ctx context.Context, user domain.UserID, from domain.UserID, len uint) error {
+	_, err := is.c.ExecContext(ctx, `
+INSERT OR IGNORE INTO inbox (uid,tid)
+SELECT ?,id FROM tweets
+WHERE uid = ? AND deleted = 0
+ORDER BY id DESC
+LIMIT ?`, user, from, len)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (is *inboxStorage) GetInboxPage(ctx context.Context, user domain.UserID, page storage.Page, pullThreshold uint) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := is.c.sq.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM inbox i
+JOIN tweets t
+ ON t.id = i.tid
+JOIN users u
+ ON t.uid = u.id
+WHERE
+i.uid = ?1
+AND i.tid > ?2
+AND i.tid < ?3
+AND t.deleted = 0
+AND t.uid NOT IN (SELECT muted FROM mutes WHERE uid = ?1)
+AND EXISTS (SELECT 1 FROM subs WHERE sfrom = ?1 AND sto = t.uid)
+UNION
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE
+t.uid IN
+ (SELECT sto FROM subs WHERE sfrom = ?1)
+AND (t.pulled = 1 OR (SELECT COUNT(*) FROM subs WHERE sto = t.uid) > ?5)
+AND t.id > ?2
+AND t.id < ?3
+AND t.deleted = 0
+AND t.uid NOT IN (SELECT muted FROM mutes WHERE uid = ?1)
+ORDER BY 1 `+order(page)+`
+LIMIT ?4`, user, after, before, page.Len, pullThreshold)
+
+	if err != nil {
+		return nil, err
+	}
+	return scanTweets(rows, page)
+}
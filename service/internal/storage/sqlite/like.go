@@ -0,0 +1,98 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type likeStorage struct {
+	c *conn
+}
+
+var _ storage.LikeStorage = &likeStorage{}
+
+func (ls *likeStorage) Like(ctx context.Context, user domain.UserID, tweetID uint64, at time.Time) error {
+	_, err := ls.c.ExecContext(ctx,
+		`INSERT OR IGNORE INTO likes (uid,tid,created_at) VALUES (?,?,?)`, user, tweetID, at)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (ls *likeStorage) Unlike(ctx context.Context, user domain.UserID, tweetID uint64) error {
+	_, err := ls.c.ExecContext(ctx,
+		`DELETE FROM likes WHERE uid = ? AND tid = ?`, user, tweetID)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (ls *likeStorage) LikeStats(ctx context.Context, viewer domain.UserID, tweetIDs []uint64) (map[uint64]storage.LikeStats, error) {
+	ret := make(map[uint64]storage.LikeStats, len(tweetIDs))
+	if len(tweetIDs) == 0 {
+		return ret, nil
+	}
+	q, args, err := sqlx.In(`
+SELECT tid,COUNT(*),MAX(uid = ?)
+FROM likes
+WHERE tid IN (?)
+GROUP BY tid`, viewer, tweetIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't build a query")
+	}
+	rows, err := ls.c.sq.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uint64
+		var stats storage.LikeStats
+		err := rows.Scan(&id, &stats.Count, &stats.Liked)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret[id] = stats
+	}
+	return ret, nil
+}
+
+func (ls *likeStorage) GetLikedPage(ctx context.Context, viewer domain.UserID, user domain.UserID, page storage.Page) ([]storage.LikedTweet, error) {
+	after, before := page.Bounds()
+	rows, err := ls.c.sq.QueryContext(ctx, `
+SELECT l.id,t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM likes l
+JOIN tweets t
+ ON t.id = l.tid
+JOIN users u
+ ON t.uid = u.id
+WHERE
+l.uid = ?1
+AND l.id > ?2
+AND l.id < ?3
+AND t.deleted = 0
+AND `+visibleTo("?5")+`
+ORDER BY l.id `+order(page)+`
+LIMIT ?4`, user, after, before, page.Len, viewer)
+
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]storage.LikedTweet, 0, page.Len)
+	for rows.Next() {
+		var l storage.LikedTweet
+		err := rows.Scan(&l.LikeID, &l.ID, &l.From, &l.At, &l.Text, &l.InReplyTo, &l.RetweetOf, &l.EditedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret = append(ret, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error when reading rows from SQL")
+	}
+	return ret, nil
+}
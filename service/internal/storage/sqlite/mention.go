@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type mentionStorage struct {
+	c *conn
+}
+
+var _ storage.MentionStorage = &mentionStorage{}
+
+func (ms *mentionStorage) SetMentions(ctx context.Context, tweetID uint64, mentions []domain.Mention) error {
+	return ms.c.Tx(ctx, func(tx *sqlx.Tx) error {
+		_, err := tx.ExecContext(ctx, `DELETE FROM mentions WHERE tid = ?`, tweetID)
+		if err != nil {
+			return err
+		}
+		stmt, err := tx.PrepareContext(ctx, `INSERT INTO mentions (tid,uid,start_pos,end_pos) VALUES (?,?,?,?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		for _, m := range mentions {
+			_, err = stmt.ExecContext(ctx, tweetID, m.UserID, m.Start, m.End)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (ms *mentionStorage) Mentions(ctx context.Context, tweetIDs []uint64) (map[uint64][]domain.Mention, error) {
+	ret := map[uint64][]domain.Mention{}
+	if len(tweetIDs) == 0 {
+		return ret, nil
+	}
+	q, args, err := sqlx.In(`
+SELECT m.tid,m.uid,u.nickname,m.start_pos,m.end_pos
+FROM mentions m
+JOIN users u
+ ON m.uid = u.id
+WHERE m.tid IN (?)
+ORDER BY m.tid,m.start_pos`, tweetIDs)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't build a query")
+	}
+	rows, err := ms.c.sq.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id uint64
+		var m domain.Mention
+		err := rows.Scan(&id, &m.UserID, &m.Nickname, &m.Start, &m.End)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret[id] = append(ret[id], m)
+	}
+	return ret, errors.Wrap(rows.Err(), "error when reading rows from SQL")
+}
+
+func (ms *mentionStorage) GetMentionsPage(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := ms.c.sq.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE
+t.id IN
+ (SELECT tid FROM mentions WHERE uid = ?1)
+AND t.id > ?2
+AND t.id < ?3
+AND t.deleted = 0
+AND `+visibleTo("?1")+`
+ORDER BY t.id `+order(page)+`
+LIMIT ?4`, user, after, before, page.Len)
+
+	if err != nil {
+		return nil, err
+	}
+	return scanTweets(rows, page)
+}
@@ -0,0 +1,74 @@
+package sqlite
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+type notificationStorage struct {
+	c *conn
+}
+
+var _ storage.NotificationStorage = &notificationStorage{}
+
+func (ns *notificationStorage) Notify(ctx context.Context, user domain.UserID, n storage.Notification) (uint64, error) {
+	res, err := ns.c.ExecContext(ctx,
+		`INSERT OR IGNORE INTO notifications (uid,kind,from_uid,tid,created_at) VALUES (?,?,?,?,?)`,
+		user, n.Kind, n.From, n.TweetID, n.At)
+	if err != nil {
+		return 0, errors.Wrap(err, "error returned from sqlite")
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return 0, nil
+	}
+	ret, _ := res.LastInsertId()
+	return uint64(ret), nil
+}
+
+func (ns *notificationStorage) GetNotificationsPage(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.Notification, error) {
+	after, before := page.Bounds()
+	rows, err := ns.c.sq.QueryContext(ctx, `
+SELECT n.id,n.kind,u.nickname,n.tid,n.created_at,n.read
+FROM notifications n
+JOIN users u
+ ON n.from_uid = u.id
+WHERE n.uid = ?
+AND n.id > ?
+AND n.id < ?
+ORDER BY n.id `+order(page)+`
+LIMIT ?`, user, after, before, page.Len)
+	if err != nil {
+		return nil, errors.Wrap(err, "error returned from sqlite")
+	}
+	defer rows.Close()
+
+	ret := make([]domain.Notification, 0, page.Len)
+	for rows.Next() {
+		var n domain.Notification
+		err := rows.Scan(&n.ID, &n.Kind, &n.From, &n.TweetID, &n.At, &n.Read)
+		if err != nil {
+			return nil, errors.Wrap(err, "error when scanning rows from SQL")
+		}
+		ret = append(ret, n)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error when reading rows from SQL")
+	}
+	return ret, nil
+}
+
+func (ns *notificationStorage) UnreadNotifications(ctx context.Context, user domain.UserID) (uint64, error) {
+	var ret uint64
+	err := ns.c.GetContext(ctx, &ret, `SELECT COUNT(*) FROM notifications WHERE uid = ? AND read = 0`, user)
+	return ret, errors.Wrap(err, "error returned from sqlite")
+}
+
+func (ns *notificationStorage) ReadNotifications(ctx context.Context, user domain.UserID, upTo uint64) error {
+	_, err := ns.c.ExecContext(ctx, `
+UPDATE notifications SET read = 1
+WHERE uid = ?1 AND read = 0 AND (?2 = 0 OR id <= ?2)`, user, upTo)
+	return errors.Wrap(err, "error returned from sqlite")
+}
@@ -0,0 +1,78 @@
+package sqlite
+
+import (
+	"context"
+	"strings"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+var _ storage.TweetSearcher = &tweetStorage{}
+
+// SearchTweets uses the FTS5 index of tweets ranked by bm25.
+func (ts *tweetStorage) SearchTweets(ctx context.Context, q storage.SearchQuery, offset uint, len uint) ([]domain.TweetWithUsername, error) {
+	from := `tweets t`
+	orderBy := `t.id DESC`
+	var where []string
+	var args []interface{}
+	if q.HasText() {
+		from = `tweets_fts f
+JOIN tweets t
+ ON t.id = f.rowid`
+		orderBy = `f.rank, t.id DESC`
+		where = append(where, `tweets_fts MATCH ?`)
+		args = append(args, matchExpr(q))
+	}
+	where = append(where, `t.deleted = 0`, visibleTo("?"))
+	// visibleTo uses the parameter twice
+	args = append(args, q.Viewer, q.Viewer)
+	if q.From != "" {
+		where = append(where, `u.nickname = ?`)
+		args = append(args, q.From)
+	}
+	// timestamps may be stored in different time zones
+	if !q.Since.IsZero() {
+		where = append(where, `julianday(t.created_at) >= julianday(?)`)
+		args = append(args, q.Since)
+	}
+	if !q.Until.IsZero() {
+		where = append(where, `julianday(t.created_at) < julianday(?)`)
+		args = append(args, q.Until)
+	}
+	args = append(args, len, offset)
+
+	rows, err := ts.c.sq.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM `+from+`
+JOIN users u
+ ON t.uid = u.id
+WHERE
+`+strings.Join(where, "\nAND ")+`
+ORDER BY `+orderBy+`
+LIMIT ? OFFSET ?`, args...)
+
+	if err != nil {
+		return nil, err
+	}
+	return scanTweets(rows, storage.Page{Len: len})
+}
+
+// matchExpr builds an FTS5 query matching all the words and phrases
+// of the search query.
+func matchExpr(q storage.SearchQuery) string {
+	terms := make([]string, 0, len(q.Words)+len(q.Phrases))
+	for _, t := range q.Words {
+		terms = append(terms, quoteTerm(t))
+	}
+	for _, t := range q.Phrases {
+		terms = append(terms, quoteTerm(t))
+	}
+	return strings.Join(terms, " ")
+}
+
+// quoteTerm makes an FTS5 string out of a word or a phrase,
+// so its special characters are not treated as query syntax.
+func quoteTerm(t string) string {
+	return `"` + strings.Replace(t, `"`, `""`, -1) + `"`
+}
@@ -8,10 +8,10 @@ import (
 	"sync"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/mattes/migrate"
 	"github.com/mattes/migrate/database/sqlite3"
-	_ "github.com/mattes/migrate/source/file"
 	"github.com/pkg/errors"
+	"github.com/utrack/woofer/lib/migrator"
+	embedded "github.com/utrack/woofer/migrations"
 )
 
 const passwordHashCost = 12
@@ -21,28 +21,31 @@ type Storage struct {
 	userStorage
 	tweetStorage
 	subsStorage
+	followStorage
+	blockStorage
+	inboxStorage
+	likeStorage
+	mentionStorage
+	hashtagStorage
+	messageStorage
+	notificationStorage
 }
 
 // New creates a new sqlite-backed storage.
+// It refuses to use a database with outdated schema, see NewMigrator.
 func New(connstring string, migrations string) (*Storage, error) {
 	db, err := sqlx.Connect("sqlite3", connstring)
 	if err != nil {
 		return nil, errors.Wrap(err, "couldn't init sqlite3 connection")
 	}
 
-	dri, err := sqlite3.WithInstance(db.DB, &sqlite3.Config{})
-	if err != nil {
-		return nil, errors.Wrap(err, "couldn't init migration driver")
-	}
-	m, err := migrate.NewWithDatabaseInstance(
-		"file://"+migrations,
-		"sqlite", dri)
+	m, err := newMigrator(db, migrations)
 	if err != nil {
-		return nil, errors.Wrap(err, "couldn't create migration engine")
+		return nil, err
 	}
-	err = m.Steps(1)
+	err = m.CheckCurrent()
 	if err != nil {
-		return nil, errors.Wrap(err, "couldn't run migrations")
+		return nil, err
 	}
 
 	c := &conn{sq: db}
@@ -56,9 +59,61 @@ func New(connstring string, migrations string) (*Storage, error) {
 		subsStorage{
 			c: c,
 		},
+		followStorage{
+			c: c,
+		},
+		blockStorage{
+			c: c,
+		},
+		inboxStorage{
+			c: c,
+		},
+		likeStorage{
+			c: c,
+		},
+		mentionStorage{
+			c: c,
+		},
+		hashtagStorage{
+			c: c,
+		},
+		messageStorage{
+			c: c,
+		},
+		notificationStorage{
+			c: c,
+		},
 	}, nil
 }
 
+// NewMigrator creates a schema migrator for the database.
+// Migrations embedded into the binary are used if the migrations path is empty.
+func NewMigrator(connstring string, migrations string) (*migrator.Migrator, error) {
+	db, err := sqlx.Connect("sqlite3", connstring)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't init sqlite3 connection")
+	}
+	return newMigrator(db, migrations)
+}
+
+func newMigrator(db *sqlx.DB, migrations string) (*migrator.Migrator, error) {
+	// checked before migrating, the search migration would leave
+	// the schema dirty otherwise
+	err := checkFTS5(db)
+	if err != nil {
+		return nil, err
+	}
+	dri, err := sqlite3.WithInstance(db.DB, &sqlite3.Config{})
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't init migration driver")
+	}
+	src, err := migrator.OpenSource(migrations, embedded.FS, "sqlite")
+	if err != nil {
+		return nil, err
+	}
+	return migrator.New(src, "sqlite", dri)
+}
+
 type conn struct {
 	sq *sqlx.DB
 	// need to lock a connection since sqlite allows single concurrent write
@@ -75,3 +130,20 @@ func (c *conn) ExecContext(ctx context.Context, q string, args ...interface{}) (
 func (c *conn) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
 	return c.sq.GetContext(ctx, dest, query, args...)
 }
+
+// Tx runs fn in a transaction, committing it if fn succeeds.
+func (c *conn) Tx(ctx context.Context, fn func(*sqlx.Tx) error) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	tx, err := c.sq.BeginTxx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "couldn't begin a transaction")
+	}
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "error returned from sqlite")
+	}
+	return errors.Wrap(tx.Commit(), "couldn't commit a transaction")
+}
@@ -0,0 +1,36 @@
+package sqlite
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/utrack/woofer/service/internal/storage"
+	"github.com/utrack/woofer/service/internal/storage/storagetest"
+)
+
+func TestStorage(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		path := filepath.Join(t.TempDir(), "db.sqlite")
+
+		_, err := New(path, "")
+		if err == nil {
+			t.Fatal("storage should refuse a database without schema")
+		}
+
+		m, err := NewMigrator(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Close()
+		err = m.Up()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		s, err := New(path, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	})
+}
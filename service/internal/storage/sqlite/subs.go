@@ -16,7 +16,7 @@ var _ storage.SubsStorage = &subsStorage{}
 
 func (s *subsStorage) Subscribe(ctx context.Context, from, to domain.UserID) error {
 	_, err := s.c.ExecContext(ctx,
-		`INSERT INTO subs (sfrom,sto) VALUES (?,?)`, from, to)
+		`INSERT OR IGNORE INTO subs (sfrom,sto) VALUES (?,?)`, from, to)
 	return errors.Wrap(err, "error returned from sqlite")
 }
 
@@ -26,6 +26,13 @@ func (s *subsStorage) Unsubscribe(ctx context.Context, from, to domain.UserID) e
 	return errors.Wrap(err, "error returned from sqlite")
 }
 
+func (s *subsStorage) IsSubscribed(ctx context.Context, from, to domain.UserID) (bool, error) {
+	var ret bool
+	err := s.c.GetContext(ctx, &ret,
+		`SELECT EXISTS (SELECT 1 FROM subs WHERE (sfrom,sto) = (?,?))`, from, to)
+	return ret, errors.Wrap(err, "error returned from sqlite")
+}
+
 func (s *subsStorage) Subs(ctx context.Context, user domain.UserID) ([]domain.UserID, error) {
 	rows, err := s.c.sq.QueryContext(ctx, `SELECT sto FROM subs WHERE sfrom = ?`, user)
 	if err != nil {
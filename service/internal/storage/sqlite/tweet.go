@@ -2,7 +2,11 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"strings"
+	"time"
 
+	"github.com/jmoiron/sqlx"
 	sqlite "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 	"github.com/utrack/woofer/domain"
@@ -18,8 +22,11 @@ var _ storage.TweetStorage = &tweetStorage{}
 
 func (ts *tweetStorage) Tweet(ctx context.Context, t domain.Tweet) (uint64, error) {
 	res, err := ts.c.ExecContext(ctx,
-		`INSERT INTO tweets (uid,created_at,text) VALUES (?,?,?)`, t.From, t.At, t.Text)
+		`INSERT INTO tweets (uid,created_at,text,reply_to,retweet_of) VALUES (?,?,?,?,?)`, t.From, t.At, t.Text, t.InReplyTo, t.RetweetOf)
 	if err != nil {
+		if isRetweetConflict(err) {
+			return 0, bizerr.New("this tweet was already retweeted", bizerr.ErrorConflict)
+		}
 		return 0, errors.Wrap(err, "error returned from sqlite")
 	}
 
@@ -27,76 +34,242 @@ func (ts *tweetStorage) Tweet(ctx context.Context, t domain.Tweet) (uint64, erro
 	return uint64(ret), nil
 }
 
+// isRetweetConflict checks if err is a violation of idx_tweets_retweet,
+// which allows a single plain retweet per user.
+func isRetweetConflict(err error) bool {
+	sqErr, ok := err.(sqlite.Error)
+	if !ok || sqErr.ExtendedCode != sqlite.ErrConstraintUnique {
+		return false
+	}
+	// sqlite reports columns of the violated index only
+	return strings.Contains(sqErr.Error(), "tweets.retweet_of")
+}
+
+func (ts *tweetStorage) Edit(ctx context.Context, tweetID uint64, text string, at time.Time) error {
+	return ts.c.Tx(ctx, func(tx *sqlx.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+INSERT INTO tweet_revisions (tid,created_at,text)
+SELECT id,COALESCE(edited_at,created_at),text FROM tweets
+WHERE id = ? AND deleted = 0`, tweetID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return bizerr.New("tweet was not found", bizerr.ErrorNotFound)
+		}
+		_, err = tx.ExecContext(ctx, `UPDATE tweets SET text = ?, edited_at = ? WHERE id = ?`, text, at, tweetID)
+		return err
+	})
+}
+
+func (ts *tweetStorage) Delete(ctx context.Context, tweetID uint64) error {
+	_, err := ts.c.ExecContext(ctx, `UPDATE tweets SET deleted = 1
+WHERE id = ?1 OR (retweet_of = ?1 AND text = '')`, tweetID)
+	return errors.Wrap(err, "error returned from sqlite")
+}
+
+func (ts *tweetStorage) Revisions(ctx context.Context, tweetID uint64) ([]domain.TweetRevision, error) {
+	ret := []domain.TweetRevision{}
+	err := ts.c.sq.SelectContext(ctx, &ret, `
+SELECT r.text,r.created_at AS at
+FROM tweet_revisions r
+JOIN tweets t
+ ON t.id = r.tid
+WHERE r.tid = ? AND t.deleted = 0
+ORDER BY r.id`, tweetID)
+	return ret, errors.Wrap(err, "error returned from sqlite")
+}
+
 func (ts *tweetStorage) ByID(ctx context.Context, id uint64) (domain.Tweet, error) {
 	var ret domain.Tweet
-	row := ts.c.sq.QueryRowContext(ctx, `SELECT id,uid,created_at,text FROM tweets WHERE id = ?`, id)
-	err := row.Scan(&ret.ID, &ret.From, &ret.At, &ret.Text)
-	if err != nil {
-		if err, ok := err.(sqlite.Error); ok && err.Code == sqlite.ErrEmpty {
-			return ret, bizerr.New("tweet was not found", bizerr.ErrorNotFound)
-		}
+	row := ts.c.sq.QueryRowContext(ctx, `SELECT id,uid,created_at,text,reply_to,retweet_of,edited_at FROM tweets WHERE id = ? AND deleted = 0`, id)
+	err := row.Scan(&ret.ID, &ret.From, &ret.At, &ret.Text, &ret.InReplyTo, &ret.RetweetOf, &ret.EditedAt)
+	if err == sql.ErrNoRows {
+		return ret, bizerr.New("tweet was not found", bizerr.ErrorNotFound)
 	}
 	return ret, errors.Wrap(err, "error when scanning tweet")
 
 }
 
-func (ts *tweetStorage) GetPageForUser(ctx context.Context, user domain.UserID, fromTweetID uint64, len uint) ([]domain.TweetWithUsername, error) {
+func (ts *tweetStorage) ByIDs(ctx context.Context, ids []uint64) ([]domain.TweetWithUsername, error) {
+	if len(ids) == 0 {
+		return []domain.TweetWithUsername{}, nil
+	}
+	q, args, err := sqlx.In(`
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE t.id IN (?)
+AND t.deleted = 0
+ORDER BY t.id DESC`, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't build a query")
+	}
+	rows, err := ts.c.sq.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	return scanTweets(rows, storage.Page{Len: uint(len(ids))})
+}
+
+func (ts *tweetStorage) GetPageForUser(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
 	rows, err := ts.c.sq.QueryContext(ctx, `
-SELECT t.id,u.nickname,created_at,text
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
 FROM tweets t
 JOIN users u
  ON t.uid = u.id
 WHERE
 uid IN
- (SELECT sto FROM subs WHERE sfrom = ?)
-AND t.id > ?
-ORDER BY t.id
-LIMIT ?`, user, fromTweetID, len)
+ (SELECT sto FROM subs WHERE sfrom = ?1)
+AND uid NOT IN
+ (SELECT muted FROM mutes WHERE uid = ?1)
+AND t.id > ?2
+AND t.id < ?3
+AND t.deleted = 0
+ORDER BY t.id `+order(page)+`
+LIMIT ?4`, user, after, before, page.Len)
 
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanTweets(rows, page)
+}
 
-	ret := make([]domain.TweetWithUsername, 0, len)
+func (ts *tweetStorage) GetPageForProfile(ctx context.Context, user domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := ts.c.sq.QueryContext(ctx, `
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM tweets t
+JOIN users u
+ ON t.uid = u.id
+WHERE
+uid = ?
+AND t.id > ?
+AND t.id < ?
+AND t.deleted = 0
+ORDER BY t.id `+order(page)+`
+LIMIT ?`, user, after, before, page.Len)
 
-	for rows.Next() {
-		var tweet domain.TweetWithUsername
-		err := rows.Scan(&tweet.ID, &tweet.From, &tweet.At, &tweet.Text)
-		if err != nil {
-			return nil, errors.Wrap(err, "error when scanning rows from SQL")
-		}
-		ret = append(ret, tweet)
+	if err != nil {
+		return nil, err
 	}
-	return ret, nil
+	return scanTweets(rows, page)
 }
 
-func (ts *tweetStorage) GetPageForProfile(ctx context.Context, user domain.UserID, fromTweetID uint64, len uint) ([]domain.TweetWithUsername, error) {
+func (ts *tweetStorage) GetPage(ctx context.Context, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
 	rows, err := ts.c.sq.QueryContext(ctx, `
-SELECT t.id,u.nickname,created_at,text
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
 FROM tweets t
 JOIN users u
  ON t.uid = u.id
 WHERE
-uid = ?
-AND t.id > ?
-ORDER BY t.id
-LIMIT ?`, user, fromTweetID, len)
+t.id > ?
+AND t.id < ?
+AND t.deleted = 0
+ORDER BY t.id `+order(page)+`
+LIMIT ?`, after, before, page.Len)
 
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return scanTweets(rows, page)
+}
+
+func (ts *tweetStorage) Ancestors(ctx context.Context, viewer domain.UserID, tweetID uint64, len uint) ([]domain.TweetWithUsername, error) {
+	rows, err := ts.c.sq.QueryContext(ctx, `
+WITH RECURSIVE anc(id,depth) AS (
+ SELECT reply_to,1 FROM tweets WHERE id = ?1 AND reply_to != 0
+ UNION ALL
+ SELECT t.reply_to,anc.depth+1
+ FROM tweets t
+ JOIN anc
+  ON t.id = anc.id
+ WHERE t.reply_to != 0 AND anc.depth < ?2
+)
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM anc
+JOIN tweets t
+ ON t.id = anc.id
+JOIN users u
+ ON t.uid = u.id
+WHERE t.deleted = 0
+AND `+visibleTo("?3")+`
+ORDER BY anc.depth DESC`, tweetID, len, viewer)
+
+	if err != nil {
+		return nil, err
+	}
+	// ancestors are already ordered from the oldest one
+	return scanTweets(rows, storage.Page{Len: len})
+}
 
-	ret := make([]domain.TweetWithUsername, 0, len)
+func (ts *tweetStorage) Descendants(ctx context.Context, viewer domain.UserID, tweetID uint64, page storage.Page) ([]domain.TweetWithUsername, error) {
+	after, before := page.Bounds()
+	rows, err := ts.c.sq.QueryContext(ctx, `
+WITH RECURSIVE thread(id) AS (
+ SELECT id FROM tweets WHERE reply_to = ?1
+ UNION ALL
+ SELECT t.id
+ FROM tweets t
+ JOIN thread
+  ON t.reply_to = thread.id
+)
+SELECT t.id,u.nickname,t.created_at,t.text,t.reply_to,t.retweet_of,t.edited_at
+FROM thread
+JOIN tweets t
+ ON t.id = thread.id
+JOIN users u
+ ON t.uid = u.id
+WHERE
+t.id > ?2
+AND t.id < ?3
+AND t.deleted = 0
+AND `+visibleTo("?5")+`
+ORDER BY t.id `+order(page)+`
+LIMIT ?4`, tweetID, after, before, page.Len, viewer)
 
+	if err != nil {
+		return nil, err
+	}
+	return scanTweets(rows, page)
+}
+
+// visibleTo returns an SQL condition selecting tweets t of users u
+// the viewer passed as param can see: tweets of protected users are
+// visible to their authors and the authors' subscribers only.
+func visibleTo(param string) string {
+	return `(u.protected = 0 OR t.uid = ` + param + ` OR EXISTS (
+ SELECT 1 FROM subs s WHERE s.sfrom = ` + param + ` AND s.sto = t.uid))`
+}
+
+// order returns an SQL sort order to read the page in.
+func order(page storage.Page) string {
+	if page.Ascending() {
+		return "ASC"
+	}
+	return "DESC"
+}
+
+// scanTweets reads tweets selected as
+// (id,nickname,created_at,text,reply_to,retweet_of,edited_at)
+// and closes the rows.
+func scanTweets(rows *sql.Rows, page storage.Page) ([]domain.TweetWithUsername, error) {
+	defer rows.Close()
+
+	ret := make([]domain.TweetWithUsername, 0, page.Len)
 	for rows.Next() {
 		var tweet domain.TweetWithUsername
-		err := rows.Scan(&tweet.ID, &tweet.From, &tweet.At, &tweet.Text)
+		err := rows.Scan(&tweet.ID, &tweet.From, &tweet.At, &tweet.Text, &tweet.InReplyTo, &tweet.RetweetOf, &tweet.EditedAt)
 		if err != nil {
 			return nil, errors.Wrap(err, "error when scanning rows from SQL")
 		}
 		ret = append(ret, tweet)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "error when reading rows from SQL")
+	}
 	return ret, nil
 }
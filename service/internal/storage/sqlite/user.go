@@ -2,9 +2,10 @@ package sqlite
 
 import (
 	"context"
-	"fmt"
+	"database/sql"
 	"strings"
 
+	"github.com/jmoiron/sqlx"
 	sqlite "github.com/mattn/go-sqlite3"
 	"github.com/pkg/errors"
 	"github.com/utrack/woofer/domain"
@@ -28,7 +29,7 @@ func (us *userStorage) New(ctx context.Context, u domain.UserWithPassword) (doma
 	}
 
 	res, err := us.c.ExecContext(ctx,
-		`INSERT INTO users (name,nickname,password) VALUES (?,?,?)`, u.RealName, u.Nickname, pass)
+		`INSERT INTO users (name,nickname,password,protected) VALUES (?,?,?,?)`, u.RealName, u.Nickname, pass, u.Protected)
 	if err != nil {
 		if err, ok := err.(sqlite.Error); ok && err.Code == sqlite.ErrConstraint {
 			return 0, bizerr.New("this nickname is already taken", bizerr.ErrorConflict)
@@ -42,7 +43,7 @@ func (us *userStorage) New(ctx context.Context, u domain.UserWithPassword) (doma
 
 func (us *userStorage) Save(ctx context.Context, u domain.User) error {
 	_, err := us.c.ExecContext(ctx,
-		`UPDATE users SET (name) = (?) WHERE id = ?`, u.RealName, u.ID)
+		`UPDATE users SET (name,protected) = (?,?) WHERE id = ?`, u.RealName, u.Protected, u.ID)
 	return errors.Wrap(err, "error returned from sqlite")
 }
 
@@ -50,7 +51,7 @@ func (us *userStorage) PasswordCheck(ctx context.Context, nickname string, pass
 	var pwdHash []byte
 	err := us.c.GetContext(ctx, &pwdHash, `SELECT password FROM users WHERE nickname = ?`, nickname)
 	if err != nil {
-		if err, ok := err.(sqlite.Error); ok && err.Code == sqlite.ErrEmpty {
+		if err == sql.ErrNoRows {
 			return false, nil
 		}
 		return false, errors.Wrap(err, "error returned from sqlite")
@@ -67,28 +68,104 @@ func (us *userStorage) PasswordCheck(ctx context.Context, nickname string, pass
 
 func (us *userStorage) GetByNickname(ctx context.Context, n string) (domain.User, error) {
 	var ret domain.User
-	row := us.c.sq.QueryRowContext(ctx, `SELECT id,name,nickname FROM users WHERE nickname = ?`, n)
-	err := row.Scan(&ret.ID, &ret.RealName, &ret.Nickname)
-	if err != nil {
-		if err, ok := err.(sqlite.Error); ok && err.Code == sqlite.ErrEmpty {
-			return ret, bizerr.New("user was not found", bizerr.ErrorNotFound)
-		}
+	row := us.c.sq.QueryRowContext(ctx, `SELECT id,name,nickname,protected FROM users WHERE nickname = ?`, n)
+	err := row.Scan(&ret.ID, &ret.RealName, &ret.Nickname, &ret.Protected)
+	if err == sql.ErrNoRows {
+		return ret, bizerr.New("user was not found", bizerr.ErrorNotFound)
 	}
 	return ret, errors.Wrap(err, "error when scanning user")
 }
 
 func (us *userStorage) GetByIds(ctx context.Context, ids []domain.UserID) ([]domain.User, error) {
-	idsText := strings.Trim(strings.Join(strings.Fields(fmt.Sprint(ids)), ","), "[]")
-	rows, err := us.c.sq.QueryContext(ctx, `SELECT id,name,nickname FROM users WHERE id IN (?)`, idsText)
+	if len(ids) == 0 {
+		return []domain.User{}, nil
+	}
+	q, args, err := sqlx.In(`SELECT id,name,nickname,protected FROM users WHERE id IN (?)`, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't build a query")
+	}
+	return us.getUsers(ctx, q, args...)
+}
+
+func (us *userStorage) GetByNicknames(ctx context.Context, nicknames []string) ([]domain.User, error) {
+	if len(nicknames) == 0 {
+		return []domain.User{}, nil
+	}
+	q, args, err := sqlx.In(`SELECT id,name,nickname,protected FROM users WHERE nickname IN (?)`, nicknames)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't build a query")
+	}
+	return us.getUsers(ctx, q, args...)
+}
+
+func (us *userStorage) SearchUsers(ctx context.Context, viewer domain.UserID, query string, len uint) ([]domain.User, error) {
+	p := newSearchPatterns(query)
+	// ranks are storage.UserSearchRank
+	return us.getUsers(ctx, `
+SELECT u.id,u.name,u.nickname,u.protected
+FROM (
+ SELECT id,name,nickname,protected,
+  CASE
+   WHEN nickname LIKE ?1 ESCAPE '\' THEN 0
+   WHEN nickname LIKE ?2 ESCAPE '\' THEN 1
+   WHEN name LIKE ?2 ESCAPE '\' OR name LIKE ?3 ESCAPE '\' THEN 2
+   WHEN nickname LIKE ?4 ESCAPE '\' OR name LIKE ?4 ESCAPE '\' THEN 3
+   ELSE 4
+  END AS rank
+ FROM users
+ WHERE nickname LIKE ?5 ESCAPE '\' OR name LIKE ?5 ESCAPE '\'
+) u
+ORDER BY
+ u.rank,
+ CASE
+  WHEN EXISTS (SELECT 1 FROM subs WHERE sfrom = ?6 AND sto = u.id) THEN 0
+  WHEN EXISTS (SELECT 1 FROM subs WHERE sfrom = u.id AND sto = ?6) THEN 1
+  ELSE 2
+ END,
+ length(u.nickname),
+ u.nickname
+LIMIT ?7`, p.exact, p.prefix, p.wordPrefix, p.substring, p.fuzzy, viewer, len)
+}
+
+// searchPatterns are LIKE patterns matching a user search query.
+type searchPatterns struct {
+	exact      string
+	prefix     string
+	wordPrefix string
+	substring  string
+	fuzzy      string
+}
+
+func newSearchPatterns(query string) searchPatterns {
+	q := likeEscaper.Replace(query)
+	fuzzy := "%"
+	for _, r := range query {
+		fuzzy += likeEscaper.Replace(string(r)) + "%"
+	}
+	return searchPatterns{
+		exact:      q,
+		prefix:     q + "%",
+		wordPrefix: "% " + q + "%",
+		substring:  "%" + q + "%",
+		fuzzy:      fuzzy,
+	}
+}
+
+// likeEscaper escapes LIKE wildcards, backslash is the escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// getUsers reads users selected as (id,name,nickname,protected).
+func (us *userStorage) getUsers(ctx context.Context, q string, args ...interface{}) ([]domain.User, error) {
+	rows, err := us.c.sq.QueryContext(ctx, q, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	ret := make([]domain.User, 0, len(ids))
+	ret := []domain.User{}
 	for rows.Next() {
 		var user domain.User
-		err := rows.Scan(&user.ID, &user.RealName, &user.Nickname)
+		err := rows.Scan(&user.ID, &user.RealName, &user.Nickname, &user.Protected)
 		if err != nil {
 			return nil, errors.Wrap(err, "error when scanning rows from SQL")
 		}
@@ -4,23 +4,86 @@ package storage
 
 import (
 	"context"
+	"math"
+	"time"
 
 	"github.com/utrack/woofer/domain"
 )
 
+// Page is a window of a tweet list.
+// Tweet lists are returned in the order they're read, see Ascending.
+type Page struct {
+	// Before limits the page to tweets with IDs lower than Before if non-zero.
+	Before uint64
+	// After limits the page to tweets with IDs greater than After if non-zero.
+	After uint64
+	// Len is a max number of tweets on the page.
+	Len uint
+}
+
+// Ascending returns true if the page should be read starting from the
+// oldest tweets, which is the case when only After is set.
+// Page is read from the newest tweets otherwise.
+func (p Page) Ascending() bool {
+	return p.After != 0 && p.Before == 0
+}
+
+// Bounds returns exclusive bounds of tweet IDs for the page.
+func (p Page) Bounds() (after uint64, before uint64) {
+	before = p.Before
+	if before == 0 {
+		before = math.MaxInt64
+	}
+	return p.After, before
+}
+
 type TweetLister interface {
 	ByID(context.Context, uint64) (domain.Tweet, error)
-	GetPageForProfile(ctx context.Context, user domain.UserID, fromTweetID uint64, len uint) ([]domain.TweetWithUsername, error)
-	GetPageForUser(ctx context.Context, user domain.UserID, fromTweetID uint64, len uint) ([]domain.TweetWithUsername, error)
+	// ByIDs returns tweets found by their IDs in no particular order,
+	// skipping the missing ones.
+	ByIDs(context.Context, []uint64) ([]domain.TweetWithUsername, error)
+	GetPageForProfile(ctx context.Context, user domain.UserID, page Page) ([]domain.TweetWithUsername, error)
+	// GetPageForUser returns a page of the user's home timeline,
+	// skipping tweets of the users it muted.
+	GetPageForUser(ctx context.Context, user domain.UserID, page Page) ([]domain.TweetWithUsername, error)
+	// GetPage returns a page of all tweets.
+	GetPage(ctx context.Context, page Page) ([]domain.TweetWithUsername, error)
+	// Revisions returns previous versions of an edited tweet,
+	// starting from the oldest one.
+	Revisions(ctx context.Context, tweetID uint64) ([]domain.TweetRevision, error)
+}
+
+// ThreadLister lists conversations.
+type ThreadLister interface {
+	// Ancestors returns up to len closest tweets in a chain of tweets
+	// the tweet replies to, starting from the oldest one.
+	// Tweets of protected users are skipped unless the viewer is their
+	// author or subscribed to the author.
+	Ancestors(ctx context.Context, viewer domain.UserID, tweetID uint64, len uint) ([]domain.TweetWithUsername, error)
+	// Descendants returns a page of all replies to the tweet, including
+	// replies to replies. Protected tweets are skipped as in Ancestors.
+	Descendants(ctx context.Context, viewer domain.UserID, tweetID uint64, page Page) ([]domain.TweetWithUsername, error)
 }
 
 type TweetSaver interface {
+	// Tweet saves a tweet, returning its ID on success.
+	// It returns bizerr.ErrorConflict if a user has already made a plain
+	// retweet of the same tweet.
 	Tweet(context.Context, domain.Tweet) (uint64, error)
+	// Edit replaces the tweet's text, keeping the previous one
+	// as a revision.
+	// It returns bizerr.ErrorNotFound if there's no such tweet.
+	Edit(ctx context.Context, tweetID uint64, text string, at time.Time) error
+	// Delete removes the tweet from every list it appears in.
+	// Plain retweets of the tweet are deleted along with it.
+	// Deleting a missing tweet is not an error.
+	Delete(ctx context.Context, tweetID uint64) error
 }
 
 type TweetStorage interface {
 	TweetLister
 	TweetSaver
+	ThreadLister
 }
 
 type UserSaver interface {
@@ -33,8 +96,33 @@ type UserSaver interface {
 type UserLister interface {
 	GetByIds(context.Context, []domain.UserID) ([]domain.User, error)
 	GetByNickname(context.Context, string) (domain.User, error)
+	// GetByNicknames returns users found by their nicknames
+	// in no particular order, skipping the missing ones.
+	GetByNicknames(context.Context, []string) ([]domain.User, error)
+	// SearchUsers returns up to len users whose nickname or name matches
+	// the query, see UserSearchRank for their order.
+	// Users related to the viewer go first among equally matching ones.
+	SearchUsers(ctx context.Context, viewer domain.UserID, query string, len uint) ([]domain.User, error)
 }
 
+// UserSearchRank describes how well a user matches a search query,
+// from the best match to the worst one. Matching is case-insensitive.
+type UserSearchRank int
+
+const (
+	// RankExactNickname is a nickname equal to the query.
+	RankExactNickname UserSearchRank = iota
+	// RankNicknamePrefix is a nickname starting with the query.
+	RankNicknamePrefix
+	// RankNamePrefix is a name having a word starting with the query.
+	RankNamePrefix
+	// RankSubstring is a nickname or a name containing the query.
+	RankSubstring
+	// RankFuzzy is a nickname or a name containing all the query's
+	// characters in the same order.
+	RankFuzzy
+)
+
 type UserStorage interface {
 	UserLister
 	UserSaver
@@ -50,6 +138,9 @@ type SubsLister interface {
 	Subs(context.Context, domain.UserID) ([]domain.UserID, error)
 	// Subbed returns all users who's subscribed to a given user.
 	Subbed(context.Context, domain.UserID) ([]domain.UserID, error)
+	// IsSubscribed returns true if the first user is subscribed
+	// to the second one.
+	IsSubscribed(ctx context.Context, from domain.UserID, to domain.UserID) (bool, error)
 }
 
 // SubsSaver stores info about users' subscriptions.
@@ -64,3 +155,215 @@ type SubsStorage interface {
 	SubsLister
 	SubsSaver
 }
+
+// FollowRequestStorage stores pending subscriptions to protected accounts.
+type FollowRequestStorage interface {
+	// RequestFollow saves a request of the first user to subscribe
+	// to the second one. Requesting twice is not an error.
+	RequestFollow(ctx context.Context, from domain.UserID, to domain.UserID, at time.Time) error
+	// FollowRequests returns users requesting to subscribe to the user,
+	// the oldest requests first.
+	FollowRequests(ctx context.Context, to domain.UserID) ([]domain.UserID, error)
+	// ApproveFollow replaces the request with a subscription.
+	// It returns bizerr.ErrorNotFound if there's no such request.
+	ApproveFollow(ctx context.Context, from domain.UserID, to domain.UserID) error
+	// RejectFollow removes the request.
+	// It returns bizerr.ErrorNotFound if there's no such request.
+	RejectFollow(ctx context.Context, from domain.UserID, to domain.UserID) error
+}
+
+// BlockStorage stores users blocking each other.
+type BlockStorage interface {
+	// Block makes the first user block the second one, removing
+	// subscriptions and follow requests between them in both directions.
+	// Blocking a user twice is not an error.
+	Block(ctx context.Context, user domain.UserID, blocked domain.UserID) error
+	Unblock(ctx context.Context, user domain.UserID, blocked domain.UserID) error
+	// IsBlocked returns true if any of the users blocks the other one.
+	IsBlocked(ctx context.Context, a domain.UserID, b domain.UserID) (bool, error)
+	// Blocked returns users blocked by the user.
+	Blocked(ctx context.Context, user domain.UserID) ([]domain.UserID, error)
+}
+
+// MuteStorage stores users muted by other users.
+type MuteStorage interface {
+	// Mute hides the second user's tweets from the first user's
+	// home timeline. Muting a user twice is not an error.
+	Mute(ctx context.Context, user domain.UserID, muted domain.UserID) error
+	Unmute(ctx context.Context, user domain.UserID, muted domain.UserID) error
+	// Muted returns users muted by the user.
+	Muted(ctx context.Context, user domain.UserID) ([]domain.UserID, error)
+}
+
+// MentionStorage stores users mentioned in tweets.
+type MentionStorage interface {
+	// SetMentions replaces all mentions of the tweet.
+	SetMentions(ctx context.Context, tweetID uint64, mentions []domain.Mention) error
+	// Mentions returns mentions of the tweets ordered by their offsets.
+	// Tweets without mentions are absent from the result.
+	Mentions(ctx context.Context, tweetIDs []uint64) (map[uint64][]domain.Mention, error)
+	// GetMentionsPage returns a page of tweets mentioning the user.
+	// Tweets of protected users are skipped unless the user is
+	// subscribed to the author.
+	GetMentionsPage(ctx context.Context, user domain.UserID, page Page) ([]domain.TweetWithUsername, error)
+}
+
+// HashtagStorage indexes tweets by their hashtags.
+type HashtagStorage interface {
+	// SetHashtags replaces all hashtags of the tweet.
+	// Hashtags should be normalized by the caller.
+	SetHashtags(ctx context.Context, tweetID uint64, tags []string) error
+	// GetTagPage returns a page of tweets having the hashtag.
+	// Tweets of protected users are skipped unless the viewer is their
+	// author or subscribed to the author.
+	GetTagPage(ctx context.Context, viewer domain.UserID, tag string, page Page) ([]domain.TweetWithUsername, error)
+}
+
+// SearchQuery describes tweets to search for.
+type SearchQuery struct {
+	// Words should all be present in a tweet, in any order.
+	Words []string
+	// Phrases should all be present in a tweet as is.
+	Phrases []string
+	// From is a nickname of tweets' author, any author if it's empty.
+	From string
+	// Since and Until limit tweets' publication time, Until is exclusive.
+	// Zero time means no limit.
+	Since time.Time
+	Until time.Time
+	// Viewer is a user searching for tweets, zero for anonymous users.
+	// Tweets of protected users are found only if the viewer is their
+	// author or subscribed to the author.
+	Viewer domain.UserID
+}
+
+// HasText returns true if the query looks for some text in tweets.
+func (q SearchQuery) HasText() bool {
+	return len(q.Words) > 0 || len(q.Phrases) > 0
+}
+
+// TweetSearcher searches tweets by their text.
+type TweetSearcher interface {
+	// SearchTweets returns up to len tweets matching the query skipping
+	// offset first ones, the most relevant tweets first.
+	// Tweets are ordered from the newest ones if the query has no text.
+	SearchTweets(ctx context.Context, q SearchQuery, offset uint, len uint) ([]domain.TweetWithUsername, error)
+}
+
+// LikeStats describes likes of a tweet.
+type LikeStats struct {
+	// Count is a number of likes.
+	Count uint64
+	// Liked is true if the tweet is liked by the viewer.
+	Liked bool
+}
+
+// LikedTweet is a tweet liked by a user. Liked tweets are ordered
+// by their likes' IDs, so the IDs are page cursors of the list.
+type LikedTweet struct {
+	LikeID uint64
+	domain.TweetWithUsername
+}
+
+// LikeStorage stores likes of tweets.
+type LikeStorage interface {
+	// Like marks the tweet as liked by the user.
+	// Liking a tweet twice is not an error.
+	Like(ctx context.Context, user domain.UserID, tweetID uint64, at time.Time) error
+	// Unlike removes user's like from the tweet.
+	Unlike(ctx context.Context, user domain.UserID, tweetID uint64) error
+	// LikeStats returns likes of given tweets as seen by the viewer.
+	// Tweets without likes can be missing from the result.
+	LikeStats(ctx context.Context, viewer domain.UserID, tweetIDs []uint64) (map[uint64]LikeStats, error)
+	// GetLikedPage returns a page of tweets liked by the user, paged
+	// by like IDs, so tweets are ordered by the time they were liked.
+	// Tweets of protected users are skipped unless the viewer is their
+	// author or subscribed to the author.
+	GetLikedPage(ctx context.Context, viewer domain.UserID, user domain.UserID, page Page) ([]LikedTweet, error)
+}
+
+// InboxStorage stores home timelines precomputed on write (fan-out-on-write).
+type InboxStorage interface {
+	// Deliver puts a tweet to the inboxes of given users.
+	Deliver(ctx context.Context, tweetID uint64, to []domain.UserID) error
+	// Pull marks a tweet which is not delivered to inboxes, so that
+	// subscribers read it from its author directly.
+	Pull(ctx context.Context, tweetID uint64) error
+	// Backfill delivers up to len latest tweets of a user to the inbox
+	// of a subscriber.
+	Backfill(ctx context.Context, user domain.UserID, from domain.UserID, len uint) error
+	// GetInboxPage returns a page of user's home timeline read from the inbox.
+	// Pulled tweets are read from their authors directly, as well as tweets
+	// from subscriptions having more than pullThreshold subscribers, which
+	// covers tweets posted before pulled ones were marked.
+	// Tweets from users that are not subscribed to anymore or muted
+	// are skipped.
+	GetInboxPage(ctx context.Context, user domain.UserID, page Page, pullThreshold uint) ([]domain.TweetWithUsername, error)
+}
+
+// MessageStorage stores direct messages.
+type MessageStorage interface {
+	// NewConversation creates a conversation between the users,
+	// returning its ID.
+	NewConversation(ctx context.Context, members []domain.UserID, at time.Time) (uint64, error)
+	// DirectConversation returns an ID of a conversation between
+	// these two users only.
+	// It returns bizerr.ErrorNotFound if there's no such conversation.
+	DirectConversation(ctx context.Context, a domain.UserID, b domain.UserID) (uint64, error)
+	// Conversation returns a conversation as seen by the user.
+	// It returns bizerr.ErrorNotFound if there's no such conversation
+	// or the user is not its member.
+	Conversation(ctx context.Context, id uint64, user domain.UserID) (domain.Conversation, error)
+	// Conversations returns all conversations of the user,
+	// the most recently active ones first.
+	Conversations(ctx context.Context, user domain.UserID) ([]domain.Conversation, error)
+	// SendMessage saves a message, returning its ID on success.
+	// Sender's messages are read by the sender.
+	SendMessage(ctx context.Context, m domain.Message) (uint64, error)
+	// GetMessagePage returns a page of the conversation's messages.
+	GetMessagePage(ctx context.Context, conversationID uint64, page Page) ([]domain.Message, error)
+	// MarkRead marks all messages of the conversation read by the user.
+	MarkRead(ctx context.Context, conversationID uint64, user domain.UserID) error
+}
+
+// Notification is a new notification of a user.
+type Notification struct {
+	Kind domain.NotificationKind
+	// From is a user who caused the notification.
+	From    domain.UserID
+	TweetID uint64
+	At      time.Time
+}
+
+// NotificationStorage stores users' notifications.
+type NotificationStorage interface {
+	// Notify saves a notification for the user, returning its ID.
+	// Saving the same notification twice is not an error, it's kept once
+	// and 0 is returned.
+	Notify(ctx context.Context, user domain.UserID, n Notification) (uint64, error)
+	// GetNotificationsPage returns a page of user's notifications.
+	GetNotificationsPage(ctx context.Context, user domain.UserID, page Page) ([]domain.Notification, error)
+	// UnreadNotifications returns a number of user's unread notifications.
+	UnreadNotifications(ctx context.Context, user domain.UserID) (uint64, error)
+	// ReadNotifications marks user's notifications with IDs up to upTo
+	// as read, or all of them if upTo is 0.
+	ReadNotifications(ctx context.Context, user domain.UserID, upTo uint64) error
+}
+
+// Storage is a complete storage backend for the service.
+type Storage interface {
+	TweetStorage
+	UserStorage
+	SubsStorage
+	FollowRequestStorage
+	BlockStorage
+	MuteStorage
+	PasswordManager
+	InboxStorage
+	LikeStorage
+	MentionStorage
+	HashtagStorage
+	TweetSearcher
+	MessageStorage
+	NotificationStorage
+}
@@ -0,0 +1,1456 @@
+/*
+Package storagetest provides a behavioral test suite for storage backends.
+
+Every backend should pass it to be usable by the service:
+
+	func TestStorage(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T) storage.Storage {
+			return newEmptyStorage(t)
+		})
+	}
+*/
+package storagetest
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+// Factory creates a new empty storage for a single test.
+type Factory func(t *testing.T) storage.Storage
+
+// Run runs the whole suite against storages created by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	tests := []struct {
+		name string
+		fn   func(*testing.T, storage.Storage)
+	}{
+		{"UserNew", testUserNew},
+		{"UserDuplicateNickname", testUserDuplicateNickname},
+		{"UserNotFound", testUserNotFound},
+		{"UserSave", testUserSave},
+		{"UserGetByIds", testUserGetByIds},
+		{"UserGetByNicknames", testUserGetByNicknames},
+		{"UserSearch", testUserSearch},
+		{"PasswordCheck", testPasswordCheck},
+		{"Subs", testSubs},
+		{"FollowRequests", testFollowRequests},
+		{"Block", testBlock},
+		{"Mute", testMute},
+		{"TweetByID", testTweetByID},
+		{"TweetNotFound", testTweetNotFound},
+		{"TweetEdit", testTweetEdit},
+		{"TweetDelete", testTweetDelete},
+		{"TweetDeleteRetweets", testTweetDeleteRetweets},
+		{"PageForProfile", testPageForProfile},
+		{"PageForUser", testPageForUser},
+		{"PageForAll", testPageForAll},
+		{"Thread", testThread},
+		{"Retweet", testRetweet},
+		{"Likes", testLikes},
+		{"Mentions", testMentions},
+		{"Hashtags", testHashtags},
+		{"Search", testSearch},
+		{"ProtectedTweets", testProtectedTweets},
+		{"Messages", testMessages},
+		{"Notifications", testNotifications},
+		{"Inbox", testInbox},
+		{"InboxBackfill", testInboxBackfill},
+		{"InboxPulled", testInboxPulled},
+	}
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStorage(t))
+		})
+	}
+}
+
+func testUserNew(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	id := newUser(t, s, "alice")
+
+	got, err := s.GetByNickname(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetByNickname: %v", err)
+	}
+	if got.ID != id || got.Nickname != "alice" || got.RealName != "Real alice" {
+		t.Errorf("GetByNickname returned %+v, want ID %v and nickname alice", got, id)
+	}
+}
+
+func testUserDuplicateNickname(t *testing.T, s storage.Storage) {
+	newUser(t, s, "alice")
+	_, err := s.New(context.Background(), domain.UserWithPassword{
+		User:     domain.User{Nickname: "alice", RealName: "Other alice"},
+		Password: "password",
+	})
+	if got := bizerr.Type(err); got != bizerr.ErrorConflict {
+		t.Errorf("duplicate nickname: got error %v of type %v, want ErrorConflict", err, got)
+	}
+}
+
+func testUserNotFound(t *testing.T, s storage.Storage) {
+	_, err := s.GetByNickname(context.Background(), "nobody")
+	if got := bizerr.Type(err); got != bizerr.ErrorNotFound {
+		t.Errorf("GetByNickname: got error %v of type %v, want ErrorNotFound", err, got)
+	}
+}
+
+func testUserSave(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	id := newUser(t, s, "alice")
+
+	err := s.Save(ctx, domain.User{ID: id, RealName: "Alice Liddell"})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := s.GetByNickname(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetByNickname: %v", err)
+	}
+	if got.RealName != "Alice Liddell" {
+		t.Errorf("real name was not saved: got %q", got.RealName)
+	}
+
+	err = s.Save(ctx, domain.User{ID: id, RealName: "Alice Liddell", Protected: true})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err = s.GetByNickname(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetByNickname: %v", err)
+	}
+	if !got.Protected {
+		t.Errorf("protected flag was not saved")
+	}
+}
+
+func testUserGetByIds(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+
+	got, err := s.GetByIds(ctx, []domain.UserID{alice, carol})
+	if err != nil {
+		t.Fatalf("GetByIds: %v", err)
+	}
+	want := map[domain.UserID]string{alice: "alice", carol: "carol"}
+	if len(got) != len(want) {
+		t.Fatalf("GetByIds returned %v users, want %v", len(got), len(want))
+	}
+	for _, u := range got {
+		if want[u.ID] != u.Nickname {
+			t.Errorf("GetByIds returned unexpected user %+v", u)
+		}
+	}
+
+	got, err = s.GetByIds(ctx, nil)
+	if err != nil {
+		t.Fatalf("GetByIds with no IDs: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetByIds with no IDs returned %v users", len(got))
+	}
+}
+
+func testUserGetByNicknames(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	newUser(t, s, "carol")
+
+	got, err := s.GetByNicknames(ctx, []string{"bob", "alice", "dave"})
+	if err != nil {
+		t.Fatalf("GetByNicknames: %v", err)
+	}
+	want := map[domain.UserID]string{alice: "alice", bob: "bob"}
+	if len(got) != len(want) {
+		t.Fatalf("GetByNicknames returned %v users, want %v", len(got), len(want))
+	}
+	for _, u := range got {
+		if want[u.ID] != u.Nickname {
+			t.Errorf("GetByNicknames returned unexpected user %+v", u)
+		}
+	}
+}
+
+func testUserSearch(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	ids := map[string]domain.UserID{}
+	for _, u := range []domain.User{
+		{Nickname: "zed", RealName: "Zed"},
+		{Nickname: "alien_na", RealName: "Al"},
+		{Nickname: "joanna", RealName: "Jo"},
+		{Nickname: "bob", RealName: "Bob Annabelle"},
+		{Nickname: "annabel", RealName: "Bel"},
+		{Nickname: "annaxy", RealName: "Xy"},
+		{Nickname: "annamaria", RealName: "Maria"},
+		{Nickname: "Anna", RealName: "Anna Smith"},
+	} {
+		id, err := s.New(ctx, domain.UserWithPassword{User: u, Password: "password"})
+		if err != nil {
+			t.Fatalf("couldn't create user %v: %v", u.Nickname, err)
+		}
+		ids[u.Nickname] = id
+	}
+	subscribe(t, s, ids["zed"], ids["annamaria"])
+	subscribe(t, s, ids["annaxy"], ids["zed"])
+
+	search := func(query string, limit uint, want ...string) {
+		t.Helper()
+		got, err := s.SearchUsers(ctx, ids["zed"], query, limit)
+		if err != nil {
+			t.Fatalf("SearchUsers: %v", err)
+		}
+		var nicks []string
+		for _, u := range got {
+			nicks = append(nicks, u.Nickname)
+			if u.ID != ids[u.Nickname] {
+				t.Errorf("SearchUsers returned %+v, want ID %v", u, ids[u.Nickname])
+			}
+		}
+		if strings.Join(nicks, ",") != strings.Join(want, ",") {
+			t.Errorf("SearchUsers(%q) returned %v, want %v", query, nicks, want)
+		}
+	}
+	search("anna", 10, "Anna", "annamaria", "annaxy", "annabel", "bob", "joanna", "alien_na")
+	search("anna", 2, "Anna", "annamaria")
+	search("a%", 10)
+	search("_", 10, "alien_na")
+}
+
+func testPasswordCheck(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	newUser(t, s, "alice")
+
+	tests := []struct {
+		nickname string
+		pass     string
+		want     bool
+	}{
+		{"alice", "password", true},
+		{"alice", "wrong password", false},
+		{"nobody", "password", false},
+	}
+	for _, tc := range tests {
+		ok, err := s.PasswordCheck(ctx, tc.nickname, tc.pass)
+		if err != nil {
+			t.Errorf("PasswordCheck(%q,%q): %v", tc.nickname, tc.pass, err)
+		}
+		if ok != tc.want {
+			t.Errorf("PasswordCheck(%q,%q) = %v, want %v", tc.nickname, tc.pass, ok, tc.want)
+		}
+	}
+}
+
+func testSubs(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+
+	subscribe(t, s, alice, bob)
+	subscribe(t, s, alice, carol)
+	subscribe(t, s, carol, bob)
+	// subscribing twice should not fail
+	subscribe(t, s, carol, bob)
+
+	expectIDs(t, "Subs(alice)", s.Subs, alice, bob, carol)
+	expectIDs(t, "Subbed(bob)", s.Subbed, bob, alice, carol)
+
+	err := s.Unsubscribe(ctx, alice, bob)
+	if err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	expectIDs(t, "Subs(alice) after unsubscribe", s.Subs, alice, carol)
+	expectIDs(t, "Subbed(bob) after unsubscribe", s.Subbed, bob, carol)
+	expectIDs(t, "Subs(bob)", s.Subs, bob)
+}
+
+func testFollowRequests(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	dave := newUser(t, s, "dave")
+
+	now := time.Now()
+	for i, from := range []domain.UserID{carol, bob, dave} {
+		err := s.RequestFollow(ctx, from, alice, now.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("RequestFollow: %v", err)
+		}
+	}
+	// requesting twice keeps the original request
+	err := s.RequestFollow(ctx, carol, alice, now.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("RequestFollow: %v", err)
+	}
+
+	got, err := s.FollowRequests(ctx, alice)
+	if err != nil {
+		t.Fatalf("FollowRequests: %v", err)
+	}
+	want := []domain.UserID{carol, bob, dave}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("FollowRequests = %v, want %v", got, want)
+	}
+
+	expectSubscribed := func(from, to domain.UserID, want bool) {
+		t.Helper()
+		got, err := s.IsSubscribed(ctx, from, to)
+		if err != nil {
+			t.Fatalf("IsSubscribed: %v", err)
+		}
+		if got != want {
+			t.Errorf("IsSubscribed(%v, %v) = %v, want %v", from, to, got, want)
+		}
+	}
+	expectSubscribed(bob, alice, false)
+
+	err = s.ApproveFollow(ctx, bob, alice)
+	if err != nil {
+		t.Fatalf("ApproveFollow: %v", err)
+	}
+	expectSubscribed(bob, alice, true)
+	expectSubscribed(alice, bob, false)
+	expectIDs(t, "Subbed(alice)", s.Subbed, alice, bob)
+
+	err = s.RejectFollow(ctx, carol, alice)
+	if err != nil {
+		t.Fatalf("RejectFollow: %v", err)
+	}
+	expectSubscribed(carol, alice, false)
+	expectIDs(t, "FollowRequests(alice)", s.FollowRequests, alice, dave)
+
+	for _, fn := range []func(context.Context, domain.UserID, domain.UserID) error{s.ApproveFollow, s.RejectFollow} {
+		err = fn(ctx, carol, alice)
+		if bizerr.Type(err) != bizerr.ErrorNotFound {
+			t.Errorf("missing request: got error %v, want ErrorNotFound", err)
+		}
+	}
+
+	// blocks drop pending requests
+	err = s.Block(ctx, alice, dave)
+	if err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+	expectIDs(t, "FollowRequests(alice) after block", s.FollowRequests, alice)
+}
+
+func testBlock(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	subscribe(t, s, alice, bob)
+	subscribe(t, s, bob, alice)
+	subscribe(t, s, alice, carol)
+
+	expectBlocked := func(a, b domain.UserID, want bool) {
+		t.Helper()
+		got, err := s.IsBlocked(ctx, a, b)
+		if err != nil {
+			t.Fatalf("IsBlocked: %v", err)
+		}
+		if got != want {
+			t.Errorf("IsBlocked(%v, %v) = %v, want %v", a, b, got, want)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		err := s.Block(ctx, alice, bob)
+		if err != nil {
+			t.Fatalf("Block: %v", err)
+		}
+	}
+	expectBlocked(alice, bob, true)
+	expectBlocked(bob, alice, true)
+	expectBlocked(alice, carol, false)
+	expectIDs(t, "Blocked", s.Blocked, alice, bob)
+	expectIDs(t, "Subs", s.Subs, alice, carol)
+	expectIDs(t, "Subs", s.Subs, bob)
+	expectIDs(t, "Subbed", s.Subbed, alice)
+
+	err := s.Unblock(ctx, alice, bob)
+	if err != nil {
+		t.Fatalf("Unblock: %v", err)
+	}
+	expectBlocked(bob, alice, false)
+	expectIDs(t, "Blocked", s.Blocked, alice)
+}
+
+func testMute(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	subscribe(t, s, alice, bob)
+	subscribe(t, s, alice, carol)
+
+	var want []uint64
+	for i := 0; i < 3; i++ {
+		id := tweet(t, s, bob)
+		want = append(want, id)
+		deliver(t, s, id, alice)
+		deliver(t, s, tweet(t, s, carol), alice)
+	}
+	for i := 0; i < 2; i++ {
+		err := s.Mute(ctx, alice, carol)
+		if err != nil {
+			t.Fatalf("Mute: %v", err)
+		}
+	}
+	expectIDs(t, "Muted", s.Muted, alice, carol)
+
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetPageForUser(ctx, alice, page)
+	}, "bob", want)
+	expectInbox(t, s, alice, 0, 10, want[2], want[1], want[0])
+	expectInbox(t, s, alice, 0, 0, want[2], want[1], want[0])
+
+	err := s.Unmute(ctx, alice, carol)
+	if err != nil {
+		t.Fatalf("Unmute: %v", err)
+	}
+	expectIDs(t, "Muted", s.Muted, alice)
+}
+
+func testTweetByID(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	at := time.Now().UTC().Truncate(time.Second)
+
+	id, err := s.Tweet(ctx, domain.Tweet{From: alice, At: at, Text: "hello"})
+	if err != nil {
+		t.Fatalf("Tweet: %v", err)
+	}
+	got, err := s.ByID(ctx, id)
+	if err != nil {
+		t.Fatalf("ByID: %v", err)
+	}
+	if got.ID != id || got.From != alice || got.Text != "hello" || !got.At.Equal(at) {
+		t.Errorf("ByID returned %+v", got)
+	}
+}
+
+func testTweetNotFound(t *testing.T, s storage.Storage) {
+	_, err := s.ByID(context.Background(), 42)
+	if got := bizerr.Type(err); got != bizerr.ErrorNotFound {
+		t.Errorf("ByID: got error %v of type %v, want ErrorNotFound", err, got)
+	}
+}
+
+func testTweetEdit(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	at := time.Now().UTC().Truncate(time.Second)
+
+	id, err := s.Tweet(ctx, domain.Tweet{From: alice, At: at, Text: "helo"})
+	if err != nil {
+		t.Fatalf("Tweet: %v", err)
+	}
+	for i, text := range []string{"hello", "hello!"} {
+		err = s.Edit(ctx, id, text, at.Add(time.Duration(i+1)*time.Minute))
+		if err != nil {
+			t.Fatalf("Edit: %v", err)
+		}
+	}
+
+	got, err := s.ByID(ctx, id)
+	if err != nil {
+		t.Fatalf("ByID: %v", err)
+	}
+	if got.Text != "hello!" || !got.At.Equal(at) || got.EditedAt == nil || !got.EditedAt.Equal(at.Add(2*time.Minute)) {
+		t.Errorf("ByID returned %+v after edits", got)
+	}
+
+	revs, err := s.Revisions(ctx, id)
+	if err != nil {
+		t.Fatalf("Revisions: %v", err)
+	}
+	if len(revs) != 2 ||
+		revs[0].Text != "helo" || !revs[0].At.Equal(at) ||
+		revs[1].Text != "hello" || !revs[1].At.Equal(at.Add(time.Minute)) {
+		t.Errorf("Revisions returned %+v", revs)
+	}
+
+	err = s.Edit(ctx, 42, "woof", at)
+	if got := bizerr.Type(err); got != bizerr.ErrorNotFound {
+		t.Errorf("Edit: got error %v of type %v for a missing tweet, want ErrorNotFound", err, got)
+	}
+}
+
+func testTweetDelete(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	subscribe(t, s, bob, alice)
+
+	var want []uint64
+	for i := 0; i < 6; i++ {
+		id := tweet(t, s, alice)
+		deliver(t, s, id, bob)
+		if i%2 == 1 {
+			want = append(want, id)
+			continue
+		}
+		err := s.Delete(ctx, id)
+		if err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+	}
+	// deleting twice is fine
+	err := s.Delete(ctx, want[0]-1)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	_, err = s.ByID(ctx, want[0]-1)
+	if got := bizerr.Type(err); got != bizerr.ErrorNotFound {
+		t.Errorf("ByID: got error %v of type %v for a deleted tweet, want ErrorNotFound", err, got)
+	}
+	got, err := s.ByIDs(ctx, []uint64{want[0] - 1, want[0]})
+	if err != nil {
+		t.Fatalf("ByIDs: %v", err)
+	}
+	if len(got) != 1 || got[0].ID != want[0] {
+		t.Errorf("ByIDs returned %+v, want only tweet %v", got, want[0])
+	}
+
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetPageForProfile(ctx, alice, page)
+	}, "alice", want)
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetPageForUser(ctx, bob, page)
+	}, "alice", want)
+	expectInbox(t, s, bob, 0, 10, want[2], want[1], want[0])
+}
+
+func testTweetDeleteRetweets(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	subscribe(t, s, carol, bob)
+
+	orig := tweet(t, s, alice)
+	plain, err := s.Tweet(ctx, domain.Tweet{From: bob, At: time.Now(), RetweetOf: orig})
+	if err != nil {
+		t.Fatalf("retweet: %v", err)
+	}
+	quote, err := s.Tweet(ctx, domain.Tweet{From: bob, At: time.Now(), Text: "quote", RetweetOf: orig})
+	if err != nil {
+		t.Fatalf("retweet: %v", err)
+	}
+	deliver(t, s, plain, carol)
+	deliver(t, s, quote, carol)
+
+	err = s.Delete(ctx, orig)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	_, err = s.ByID(ctx, plain)
+	if got := bizerr.Type(err); got != bizerr.ErrorNotFound {
+		t.Errorf("ByID: got error %v of type %v for a retweet of a deleted tweet, want ErrorNotFound", err, got)
+	}
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetPageForProfile(ctx, bob, page)
+	}, "bob", []uint64{quote})
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetPageForUser(ctx, carol, page)
+	}, "bob", []uint64{quote})
+}
+
+func testPageForProfile(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+
+	var want []uint64
+	for i := 0; i < 5; i++ {
+		want = append(want, tweet(t, s, alice))
+		tweet(t, s, bob)
+	}
+
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetPageForProfile(ctx, alice, page)
+	}, "alice", want)
+}
+
+func testPageForUser(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	subscribe(t, s, alice, bob)
+
+	var want []uint64
+	for i := 0; i < 5; i++ {
+		want = append(want, tweet(t, s, bob))
+		tweet(t, s, carol)
+		tweet(t, s, alice)
+	}
+
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetPageForUser(ctx, alice, page)
+	}, "bob", want)
+
+	got, err := s.GetPageForUser(ctx, carol, storage.Page{Len: 10})
+	if err != nil {
+		t.Fatalf("GetPageForUser: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("GetPageForUser returned %v tweets for a user without subscriptions", len(got))
+	}
+}
+
+func testPageForAll(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+
+	var want []uint64
+	for i := 0; i < 5; i++ {
+		want = append(want, tweet(t, s, alice), tweet(t, s, bob))
+	}
+
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetPage(ctx, page)
+	}, "", want)
+}
+
+func testThread(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+
+	root := tweet(t, s, alice)
+	reply := replyTo(t, s, bob, root)
+	tweet(t, s, alice)
+	nested := replyTo(t, s, alice, reply)
+	other := replyTo(t, s, bob, root)
+
+	got, err := s.ByID(ctx, nested)
+	if err != nil {
+		t.Fatalf("ByID: %v", err)
+	}
+	if got.InReplyTo != reply {
+		t.Errorf("ByID returned InReplyTo %v, want %v", got.InReplyTo, reply)
+	}
+
+	expectTweets := func(what string, tweets []domain.TweetWithUsername, err error, want ...uint64) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%v: %v", what, err)
+		}
+		var ids []uint64
+		for _, tw := range tweets {
+			ids = append(ids, tw.ID)
+		}
+		expectSame(t, what, ids, want)
+	}
+
+	tweets, err := s.Ancestors(ctx, 0, nested, 10)
+	expectTweets("Ancestors", tweets, err, root, reply)
+	tweets, err = s.Ancestors(ctx, 0, nested, 1)
+	expectTweets("Ancestors with len 1", tweets, err, reply)
+	tweets, err = s.Ancestors(ctx, 0, root, 10)
+	expectTweets("Ancestors of the root", tweets, err)
+
+	tweets, err = s.Descendants(ctx, 0, root, storage.Page{Len: 10})
+	expectTweets("Descendants", tweets, err, other, nested, reply)
+	tweets, err = s.Descendants(ctx, 0, root, storage.Page{Before: nested, Len: 10})
+	expectTweets("Descendants before nested reply", tweets, err, reply)
+	tweets, err = s.Descendants(ctx, 0, reply, storage.Page{Len: 10})
+	expectTweets("Descendants of the reply", tweets, err, nested)
+	tweets, err = s.Descendants(ctx, 0, other, storage.Page{Len: 10})
+	expectTweets("Descendants of the leaf", tweets, err)
+}
+
+func testRetweet(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+
+	orig := tweet(t, s, alice)
+	other := tweet(t, s, alice)
+	retweet := func(from domain.UserID, id uint64, comment string) (uint64, error) {
+		return s.Tweet(ctx, domain.Tweet{From: from, At: time.Now(), Text: comment, RetweetOf: id})
+	}
+
+	rt, err := retweet(bob, orig, "")
+	if err != nil {
+		t.Fatalf("retweet: %v", err)
+	}
+	_, err = retweet(bob, orig, "")
+	if got := bizerr.Type(err); got != bizerr.ErrorConflict {
+		t.Errorf("second retweet: got error %v of type %v, want ErrorConflict", err, got)
+	}
+	for _, tc := range []struct {
+		from    domain.UserID
+		id      uint64
+		comment string
+	}{
+		{bob, orig, "quote"},
+		{bob, orig, "another quote"},
+		{bob, other, ""},
+		{alice, orig, ""},
+	} {
+		_, err = retweet(tc.from, tc.id, tc.comment)
+		if err != nil {
+			t.Errorf("retweet of %v by %v with comment %q: %v", tc.id, tc.from, tc.comment, err)
+		}
+	}
+
+	got, err := s.ByIDs(ctx, []uint64{rt, orig, 100500})
+	if err != nil {
+		t.Fatalf("ByIDs: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("ByIDs returned %v tweets, want 2", len(got))
+	}
+	for _, tw := range got {
+		switch tw.ID {
+		case rt:
+			if tw.RetweetOf != orig || tw.From != "bob" {
+				t.Errorf("ByIDs returned wrong retweet %+v", tw)
+			}
+		case orig:
+			if tw.RetweetOf != 0 || tw.From != "alice" {
+				t.Errorf("ByIDs returned wrong tweet %+v", tw)
+			}
+		default:
+			t.Errorf("ByIDs returned unexpected tweet %+v", tw)
+		}
+	}
+}
+
+func testLikes(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+
+	first := tweet(t, s, alice)
+	second := tweet(t, s, alice)
+	third := tweet(t, s, bob)
+	notLiked := tweet(t, s, bob)
+
+	like := func(user domain.UserID, tweetID uint64) {
+		t.Helper()
+		err := s.Like(ctx, user, tweetID, time.Now())
+		if err != nil {
+			t.Fatalf("Like: %v", err)
+		}
+	}
+	like(bob, third)
+	like(carol, first)
+	like(bob, first)
+	// liking twice should not fail
+	like(carol, first)
+	like(bob, second)
+	// nor should it move the tweet up the list
+	like(bob, third)
+
+	stats, err := s.LikeStats(ctx, carol, []uint64{first, second, notLiked})
+	if err != nil {
+		t.Fatalf("LikeStats: %v", err)
+	}
+	want := map[uint64]storage.LikeStats{
+		first:    {Count: 2, Liked: true},
+		second:   {Count: 1, Liked: false},
+		notLiked: {},
+	}
+	for id, st := range want {
+		if stats[id] != st {
+			t.Errorf("LikeStats for tweet %v = %+v, want %+v", id, stats[id], st)
+		}
+	}
+
+	// liked tweets are ordered by the time they were liked
+	liked := func(page storage.Page, want ...uint64) []storage.LikedTweet {
+		t.Helper()
+		got, err := s.GetLikedPage(ctx, 0, bob, page)
+		if err != nil {
+			t.Fatalf("GetLikedPage: %v", err)
+		}
+		var ids []uint64
+		for i, l := range got {
+			ids = append(ids, l.ID)
+			if i > 0 && (got[i-1].LikeID < l.LikeID) != page.Ascending() {
+				t.Errorf("page %+v is not ordered in the reading order", page)
+			}
+		}
+		expectSame(t, "GetLikedPage", ids, want)
+		return got
+	}
+	all := liked(storage.Page{Len: 10}, second, first, third)
+	liked(storage.Page{Before: all[1].LikeID, Len: 10}, third)
+	liked(storage.Page{After: all[2].LikeID, Len: 1}, first)
+	liked(storage.Page{After: all[2].LikeID, Before: all[0].LikeID, Len: 10}, first)
+
+	err = s.Unlike(ctx, bob, first)
+	if err != nil {
+		t.Fatalf("Unlike: %v", err)
+	}
+	liked(storage.Page{Len: 10}, second, third)
+	// liking again moves the tweet up
+	like(bob, first)
+	liked(storage.Page{Len: 10}, first, second, third)
+}
+
+func testMentions(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+
+	var want []uint64
+	for i := 0; i < 5; i++ {
+		id := tweet(t, s, bob)
+		want = append(want, id)
+		err := s.SetMentions(ctx, id, []domain.Mention{
+			{UserID: bob, Start: 10, End: 14},
+			{UserID: alice, Start: 0, End: 6},
+		})
+		if err != nil {
+			t.Fatalf("SetMentions: %v", err)
+		}
+		tweet(t, s, alice)
+	}
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetMentionsPage(ctx, alice, page)
+	}, "bob", want)
+
+	// mentions are replaced on edits
+	err := s.SetMentions(ctx, want[0], []domain.Mention{{UserID: bob, Start: 0, End: 4}})
+	if err != nil {
+		t.Fatalf("SetMentions: %v", err)
+	}
+	got, err := s.Mentions(ctx, []uint64{want[0], want[1], want[1] + 1})
+	if err != nil {
+		t.Fatalf("Mentions: %v", err)
+	}
+	first, second := got[want[0]], got[want[1]]
+	if len(got) != 2 || len(first) != 1 || len(second) != 2 {
+		t.Fatalf("Mentions returned %+v", got)
+	}
+	if first[0] != (domain.Mention{UserID: bob, Nickname: "bob", Start: 0, End: 4}) {
+		t.Errorf("Mentions returned %+v for an edited tweet", first)
+	}
+	if second[0] != (domain.Mention{UserID: alice, Nickname: "alice", Start: 0, End: 6}) ||
+		second[1] != (domain.Mention{UserID: bob, Nickname: "bob", Start: 10, End: 14}) {
+		t.Errorf("Mentions returned %+v, want them ordered by offsets", second)
+	}
+}
+
+func testHashtags(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+
+	var want []uint64
+	for i := 0; i < 5; i++ {
+		id := tweet(t, s, alice)
+		want = append(want, id)
+		err := s.SetHashtags(ctx, id, []string{"go", "woof"})
+		if err != nil {
+			t.Fatalf("SetHashtags: %v", err)
+		}
+		tweet(t, s, alice)
+	}
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetTagPage(ctx, 0, "go", page)
+	}, "alice", want)
+
+	// hashtags are replaced on edits
+	err := s.SetHashtags(ctx, want[0], []string{"woof"})
+	if err != nil {
+		t.Fatalf("SetHashtags: %v", err)
+	}
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetTagPage(ctx, 0, "go", page)
+	}, "alice", want[1:])
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		return s.GetTagPage(ctx, 0, "woof", page)
+	}, "alice", want)
+}
+
+func testSearch(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	day := time.Date(2018, 3, 7, 0, 0, 0, 0, time.UTC)
+
+	post := func(from domain.UserID, at time.Time, text string) uint64 {
+		t.Helper()
+		id, err := s.Tweet(ctx, domain.Tweet{From: from, At: at, Text: text})
+		if err != nil {
+			t.Fatalf("couldn't post a tweet: %v", err)
+		}
+		return id
+	}
+	quick := post(alice, day, "The quick brown fox")
+	lazy := post(bob, day.Add(time.Hour), "A lazy dog, not a fox")
+	brownDog := post(bob, day.AddDate(0, 0, 1), "brown dog jumps over a quick fox")
+	post(alice, day.AddDate(0, 0, 2), "nothing to see here")
+	deleted := post(alice, day, "deleted fox")
+	err := s.Delete(ctx, deleted)
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	edited := post(alice, day, "edited")
+	err = s.Edit(ctx, edited, "edited cat", day)
+	if err != nil {
+		t.Fatalf("Edit: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		q    storage.SearchQuery
+		want []uint64
+	}{
+		{"word", storage.SearchQuery{Words: []string{"fox"}}, []uint64{quick, lazy, brownDog}},
+		{"words", storage.SearchQuery{Words: []string{"Quick", "brown"}}, []uint64{quick, brownDog}},
+		{"phrase", storage.SearchQuery{Phrases: []string{"quick brown"}}, []uint64{quick}},
+		{"from", storage.SearchQuery{Words: []string{"fox"}, From: "bob"}, []uint64{lazy, brownDog}},
+		{"since", storage.SearchQuery{Words: []string{"fox"}, Since: day.Add(time.Minute)}, []uint64{lazy, brownDog}},
+		{"until", storage.SearchQuery{Words: []string{"fox"}, Until: day.AddDate(0, 0, 1)}, []uint64{quick, lazy}},
+		{"edited", storage.SearchQuery{Words: []string{"cat"}}, []uint64{edited}},
+		{"no text", storage.SearchQuery{From: "bob"}, []uint64{lazy, brownDog}},
+	}
+	for _, tc := range tests {
+		got, err := s.SearchTweets(ctx, tc.q, 0, 10)
+		if err != nil {
+			t.Fatalf("%v: SearchTweets: %v", tc.name, err)
+		}
+		ids := make([]uint64, 0, len(got))
+		for _, tw := range got {
+			ids = append(ids, tw.ID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		expectSame(t, tc.name, ids, tc.want)
+	}
+
+	// pages don't overlap
+	var got []uint64
+	for offset := uint(0); offset < 4; offset += 2 {
+		tweets, err := s.SearchTweets(ctx, storage.SearchQuery{Words: []string{"fox"}}, offset, 2)
+		if err != nil {
+			t.Fatalf("SearchTweets: %v", err)
+		}
+		for _, tw := range tweets {
+			got = append(got, tw.ID)
+		}
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	expectSame(t, "pages", got, []uint64{quick, lazy, brownDog})
+}
+
+func testProtectedTweets(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	dave := newUser(t, s, "dave")
+	err := s.Save(ctx, domain.User{ID: alice, RealName: "Real alice", Protected: true})
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	subscribe(t, s, bob, alice)
+
+	root := tweet(t, s, carol)
+	reply := replyTo(t, s, alice, root)
+	nested := replyTo(t, s, carol, reply)
+	for _, id := range []uint64{root, reply, nested} {
+		err = s.SetHashtags(ctx, id, []string{"go"})
+		if err != nil {
+			t.Fatalf("SetHashtags: %v", err)
+		}
+		err = s.Like(ctx, dave, id, time.Now())
+		if err != nil {
+			t.Fatalf("Like: %v", err)
+		}
+	}
+
+	expectTweets := func(what string, tweets []domain.TweetWithUsername, err error, want ...uint64) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%v: %v", what, err)
+		}
+		ids := make([]uint64, 0, len(tweets))
+		for _, tw := range tweets {
+			ids = append(ids, tw.ID)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		expectSame(t, what, ids, want)
+	}
+	page := storage.Page{Len: 10}
+	viewers := []struct {
+		name string
+		id   domain.UserID
+		want []uint64
+	}{
+		{"author", alice, []uint64{root, reply, nested}},
+		{"subscriber", bob, []uint64{root, reply, nested}},
+		{"stranger", carol, []uint64{root, nested}},
+		{"anonymous", 0, []uint64{root, nested}},
+	}
+	// mentions are listed only if the mentioned user can see them
+	err = s.SetMentions(ctx, reply, []domain.Mention{{UserID: bob, Start: 0, End: 4}, {UserID: carol, Start: 5, End: 11}})
+	if err != nil {
+		t.Fatalf("SetMentions: %v", err)
+	}
+	tweets, err := s.GetMentionsPage(ctx, bob, page)
+	expectTweets("subscriber: GetMentionsPage", tweets, err, reply)
+	tweets, err = s.GetMentionsPage(ctx, carol, page)
+	expectTweets("stranger: GetMentionsPage", tweets, err)
+
+	for _, v := range viewers {
+		tweets, err := s.GetTagPage(ctx, v.id, "go", page)
+		expectTweets(v.name+": GetTagPage", tweets, err, v.want...)
+		tweets, err = s.SearchTweets(ctx, storage.SearchQuery{Words: []string{"woof"}, Viewer: v.id}, 0, 10)
+		expectTweets(v.name+": SearchTweets", tweets, err, v.want...)
+		tweets, err = likedTweets(s.GetLikedPage(ctx, v.id, dave, page))
+		expectTweets(v.name+": GetLikedPage", tweets, err, v.want...)
+		tweets, err = s.Descendants(ctx, v.id, root, page)
+		expectTweets(v.name+": Descendants", tweets, err, v.want[1:]...)
+		tweets, err = s.Ancestors(ctx, v.id, nested, 10)
+		expectTweets(v.name+": Ancestors", tweets, err, v.want[:len(v.want)-1]...)
+	}
+}
+
+func testMessages(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	now := time.Now()
+
+	_, err := s.DirectConversation(ctx, alice, bob)
+	if bizerr.Type(err) != bizerr.ErrorNotFound {
+		t.Fatalf("DirectConversation of strangers: got error %v, want ErrorNotFound", err)
+	}
+	group, err := s.NewConversation(ctx, []domain.UserID{alice, bob, carol}, now)
+	if err != nil {
+		t.Fatalf("NewConversation: %v", err)
+	}
+	direct, err := s.NewConversation(ctx, []domain.UserID{bob, alice}, now)
+	if err != nil {
+		t.Fatalf("NewConversation: %v", err)
+	}
+	got, err := s.DirectConversation(ctx, alice, bob)
+	if err != nil || got != direct {
+		t.Fatalf("DirectConversation = %v, %v; want %v", got, err, direct)
+	}
+
+	send := func(conv uint64, from domain.UserID) uint64 {
+		t.Helper()
+		id, err := s.SendMessage(ctx, domain.Message{ConversationID: conv, From: from, At: now, Text: "hi"})
+		if err != nil {
+			t.Fatalf("SendMessage: %v", err)
+		}
+		return id
+	}
+	var want []uint64
+	for i := 0; i < 5; i++ {
+		want = append(want, send(direct, alice))
+	}
+	send(group, carol)
+	last := send(group, bob)
+
+	expectPages(t, func(page storage.Page) ([]domain.TweetWithUsername, error) {
+		msgs, err := s.GetMessagePage(ctx, direct, page)
+		ret := make([]domain.TweetWithUsername, len(msgs))
+		for i, m := range msgs {
+			if m.ConversationID != direct || m.From != alice || m.Text != "hi" {
+				t.Errorf("unexpected message %+v", m)
+			}
+			ret[i].ID = m.ID
+		}
+		return ret, err
+	}, "", want)
+
+	convs, err := s.Conversations(ctx, alice)
+	if err != nil {
+		t.Fatalf("Conversations: %v", err)
+	}
+	if len(convs) != 2 || convs[0].ID != group || convs[1].ID != direct {
+		t.Fatalf("Conversations = %+v, want [%v %v]", convs, group, direct)
+	}
+	c := convs[0]
+	if len(c.Members) != 3 || c.LastMessage == nil || c.LastMessage.ID != last || c.LastMessage.From != bob {
+		t.Errorf("unexpected group conversation %+v", c)
+	}
+	if c.Unread != 2 || c.LastRead != 0 {
+		t.Errorf("alice's group conversation: unread %v, last read %v; want 2, 0", c.Unread, c.LastRead)
+	}
+	if convs[1].Unread != 0 {
+		t.Errorf("own messages should be read, got %v unread", convs[1].Unread)
+	}
+
+	c, err = s.Conversation(ctx, group, bob)
+	if err != nil {
+		t.Fatalf("Conversation: %v", err)
+	}
+	// sending a message reads all the previous ones
+	if c.Unread != 0 || c.LastRead != last {
+		t.Errorf("bob's group conversation: unread %v, last read %v; want 0, %v", c.Unread, c.LastRead, last)
+	}
+
+	err = s.MarkRead(ctx, group, alice)
+	if err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	c, err = s.Conversation(ctx, group, alice)
+	if err != nil {
+		t.Fatalf("Conversation: %v", err)
+	}
+	if c.Unread != 0 || c.LastRead != last {
+		t.Errorf("after MarkRead: unread %v, last read %v; want 0, %v", c.Unread, c.LastRead, last)
+	}
+
+	_, err = s.Conversation(ctx, direct, carol)
+	if bizerr.Type(err) != bizerr.ErrorNotFound {
+		t.Errorf("Conversation of a non-member: got error %v, want ErrorNotFound", err)
+	}
+	convs, err = s.Conversations(ctx, carol)
+	if err != nil || len(convs) != 1 {
+		t.Errorf("Conversations(carol) = %+v, %v; want a single one", convs, err)
+	}
+}
+
+func testNotifications(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	now := time.Now()
+
+	notify := func(user domain.UserID, n storage.Notification) uint64 {
+		t.Helper()
+		id, err := s.Notify(ctx, user, n)
+		if err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+		return id
+	}
+	if id := notify(alice, storage.Notification{Kind: domain.NotifySubscribed, From: bob, At: now}); id == 0 {
+		t.Errorf("Notify returned zero ID")
+	}
+	// duplicates are ignored
+	if id := notify(alice, storage.Notification{Kind: domain.NotifySubscribed, From: bob, At: now}); id != 0 {
+		t.Errorf("Notify of a duplicate returned ID %v, want 0", id)
+	}
+	notify(bob, storage.Notification{Kind: domain.NotifySubscribed, From: alice, At: now})
+	for i := uint64(1); i <= 5; i++ {
+		notify(alice, storage.Notification{Kind: domain.NotifyLiked, From: carol, TweetID: i, At: now})
+	}
+
+	var all []domain.Notification
+	page := storage.Page{Len: 2}
+	for {
+		ns, err := s.GetNotificationsPage(ctx, alice, page)
+		if err != nil {
+			t.Fatalf("GetNotificationsPage: %v", err)
+		}
+		all = append(all, ns...)
+		if uint(len(ns)) < page.Len {
+			break
+		}
+		page.Before = ns[len(ns)-1].ID
+	}
+	if len(all) != 6 {
+		t.Fatalf("got %v notifications, want 6: %+v", len(all), all)
+	}
+	for i, n := range all[:5] {
+		if n.Kind != domain.NotifyLiked || n.From != "carol" || n.TweetID != uint64(5-i) || n.Read {
+			t.Errorf("unexpected notification %+v", n)
+		}
+	}
+	if n := all[5]; n.Kind != domain.NotifySubscribed || n.From != "bob" || n.TweetID != 0 {
+		t.Errorf("unexpected notification %+v", n)
+	}
+
+	ns, err := s.GetNotificationsPage(ctx, alice, storage.Page{After: all[3].ID, Len: 2})
+	if err != nil {
+		t.Fatalf("GetNotificationsPage: %v", err)
+	}
+	// ascending pages are read from the oldest notifications
+	if len(ns) != 2 || ns[0].ID != all[2].ID || ns[1].ID != all[1].ID {
+		t.Errorf("ascending page = %+v, want %v and %v", ns, all[2].ID, all[1].ID)
+	}
+
+	expectUnread := func(user domain.UserID, want uint64) {
+		t.Helper()
+		got, err := s.UnreadNotifications(ctx, user)
+		if err != nil {
+			t.Fatalf("UnreadNotifications: %v", err)
+		}
+		if got != want {
+			t.Errorf("UnreadNotifications = %v, want %v", got, want)
+		}
+	}
+	expectUnread(alice, 6)
+	err = s.ReadNotifications(ctx, alice, all[2].ID)
+	if err != nil {
+		t.Fatalf("ReadNotifications: %v", err)
+	}
+	expectUnread(alice, 2)
+	expectUnread(bob, 1)
+	err = s.ReadNotifications(ctx, alice, 0)
+	if err != nil {
+		t.Fatalf("ReadNotifications: %v", err)
+	}
+	expectUnread(alice, 0)
+	expectUnread(bob, 1)
+}
+
+func testInbox(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	subscribe(t, s, alice, bob)
+	subscribe(t, s, alice, carol)
+
+	first := tweet(t, s, bob)
+	deliver(t, s, first, alice)
+	notDelivered := tweet(t, s, carol)
+	second := tweet(t, s, bob)
+	deliver(t, s, second, alice)
+
+	expectInbox(t, s, alice, 0, 10, second, first)
+	expectInbox(t, s, alice, first, 10, second)
+	expectInbox(t, s, alice, 0, 10, second)
+	// carol has more subscribers than the threshold
+	expectInbox(t, s, alice, 0, 0, second, notDelivered, first)
+
+	err := s.Unsubscribe(ctx, alice, bob)
+	if err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	expectInbox(t, s, alice, 0, 10)
+}
+
+func testInboxBackfill(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+
+	tweet(t, s, bob)
+	second := tweet(t, s, bob)
+	third := tweet(t, s, bob)
+	subscribe(t, s, alice, bob)
+
+	err := s.Backfill(ctx, alice, bob, 2)
+	if err != nil {
+		t.Fatalf("Backfill: %v", err)
+	}
+	expectInbox(t, s, alice, 0, 10, third, second)
+}
+
+func testInboxPulled(t *testing.T, s storage.Storage) {
+	ctx := context.Background()
+	alice := newUser(t, s, "alice")
+	bob := newUser(t, s, "bob")
+	carol := newUser(t, s, "carol")
+	subscribe(t, s, alice, bob)
+	subscribe(t, s, carol, bob)
+
+	// bob is above the threshold, so his tweet is pulled rather than delivered
+	pulled := tweet(t, s, bob)
+	err := s.Pull(ctx, pulled)
+	if err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	expectInbox(t, s, alice, 0, 1, pulled)
+
+	// bob drops below the threshold, the pulled tweet stays in the timeline
+	err = s.Unsubscribe(ctx, carol, bob)
+	if err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+	delivered := tweet(t, s, bob)
+	deliver(t, s, delivered, alice)
+	expectInbox(t, s, alice, 0, 1, delivered, pulled)
+	expectInbox(t, s, alice, pulled, 1, delivered)
+}
+
+// likedTweets returns liked tweets without their likes.
+func likedTweets(liked []storage.LikedTweet, err error) ([]domain.TweetWithUsername, error) {
+	ret := make([]domain.TweetWithUsername, len(liked))
+	for i := range liked {
+		ret[i] = liked[i].TweetWithUsername
+	}
+	return ret, err
+}
+
+// expectInbox checks that an inbox page contains exactly the tweets wanted
+// in the page's reading order. Page length is the number of tweets wanted.
+func expectInbox(t *testing.T, s storage.Storage, user domain.UserID, after uint64, threshold uint, want ...uint64) {
+	t.Helper()
+	page := storage.Page{After: after, Len: uint(len(want))}
+	if page.Len == 0 {
+		page.Len = 10
+	}
+	got, err := s.GetInboxPage(context.Background(), user, page, threshold)
+	if err != nil {
+		t.Fatalf("GetInboxPage: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GetInboxPage after %v with threshold %v returned %v tweets, want %v", after, threshold, len(got), want)
+	}
+	for i := range want {
+		if got[i].ID != want[i] {
+			t.Fatalf("GetInboxPage after %v with threshold %v returned tweet %v at %v, want %v", after, threshold, got[i].ID, i, want)
+		}
+	}
+}
+
+// expectPages walks through every page of size 2 from the newest tweets
+// to the oldest ones and back, checking that pages contain tweets from want
+// and nothing else.
+// want should be ordered from the oldest tweet to the newest one.
+// Every tweet should be posted by from, unless it is empty.
+func expectPages(t *testing.T, list func(storage.Page) ([]domain.TweetWithUsername, error), from string, want []uint64) {
+	t.Helper()
+	const pageLen = 2
+
+	readPage := func(page storage.Page) []domain.TweetWithUsername {
+		t.Helper()
+		tweets, err := list(page)
+		if err != nil {
+			t.Fatalf("page %+v: %v", page, err)
+		}
+		if len(tweets) > pageLen {
+			t.Fatalf("page %+v has %v tweets, want at most %v", page, len(tweets), pageLen)
+		}
+		for i, tw := range tweets {
+			if from != "" && tw.From != from {
+				t.Errorf("tweet %v is from %q, want %q", tw.ID, tw.From, from)
+			}
+			if i > 0 && (tweets[i-1].ID < tw.ID) != page.Ascending() {
+				t.Errorf("page %+v is not ordered in the reading order", page)
+			}
+		}
+		return tweets
+	}
+
+	// walk to the oldest tweets
+	var got []uint64
+	page := storage.Page{Len: pageLen}
+	for i := 0; i <= len(want); i++ {
+		tweets := readPage(page)
+		if len(tweets) == 0 {
+			break
+		}
+		for _, tw := range tweets {
+			got = append([]uint64{tw.ID}, got...)
+		}
+		page.Before = tweets[len(tweets)-1].ID
+	}
+	expectSame(t, "walking to the oldest tweets", got, want)
+
+	// walk back to the newest ones
+	got = nil
+	page = storage.Page{After: want[0], Len: pageLen}
+	for i := 0; i <= len(want); i++ {
+		tweets := readPage(page)
+		if len(tweets) == 0 {
+			break
+		}
+		for _, tw := range tweets {
+			got = append(got, tw.ID)
+		}
+		page.After = tweets[len(tweets)-1].ID
+	}
+	expectSame(t, "walking to the newest tweets", got, want[1:])
+}
+
+func expectSame(t *testing.T, what string, got []uint64, want []uint64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%v: got tweets %v, want %v", what, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("%v: got tweets %v, want %v", what, got, want)
+		}
+	}
+}
+
+func expectIDs(t *testing.T, what string, list func(context.Context, domain.UserID) ([]domain.UserID, error), user domain.UserID, want ...domain.UserID) {
+	t.Helper()
+	got, err := list(context.Background(), user)
+	if err != nil {
+		t.Fatalf("%v: %v", what, err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("%v = %v, want %v", what, got, want)
+	}
+	set := map[domain.UserID]bool{}
+	for _, id := range got {
+		set[id] = true
+	}
+	for _, id := range want {
+		if !set[id] {
+			t.Fatalf("%v = %v, want %v", what, got, want)
+		}
+	}
+}
+
+func newUser(t *testing.T, s storage.Storage, nickname string) domain.UserID {
+	t.Helper()
+	id, err := s.New(context.Background(), domain.UserWithPassword{
+		User:     domain.User{Nickname: nickname, RealName: "Real " + nickname},
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatalf("couldn't create user %v: %v", nickname, err)
+	}
+	return id
+}
+
+func subscribe(t *testing.T, s storage.Storage, from, to domain.UserID) {
+	t.Helper()
+	err := s.Subscribe(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("couldn't subscribe %v to %v: %v", from, to, err)
+	}
+}
+
+func deliver(t *testing.T, s storage.Storage, tweetID uint64, to ...domain.UserID) {
+	t.Helper()
+	err := s.Deliver(context.Background(), tweetID, to)
+	if err != nil {
+		t.Fatalf("couldn't deliver a tweet: %v", err)
+	}
+}
+
+func replyTo(t *testing.T, s storage.Storage, from domain.UserID, parent uint64) uint64 {
+	t.Helper()
+	id, err := s.Tweet(context.Background(), domain.Tweet{From: from, At: time.Now(), Text: "woof", InReplyTo: parent})
+	if err != nil {
+		t.Fatalf("couldn't post a reply: %v", err)
+	}
+	return id
+}
+
+func tweet(t *testing.T, s storage.Storage, from domain.UserID) uint64 {
+	t.Helper()
+	id, err := s.Tweet(context.Background(), domain.Tweet{From: from, At: time.Now(), Text: "woof"})
+	if err != nil {
+		t.Fatalf("couldn't post a tweet: %v", err)
+	}
+	return id
+}
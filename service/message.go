@@ -0,0 +1,237 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/auth"
+	"github.com/utrack/woofer/lib/bizerr"
+)
+
+// maxConversationMembers is a max number of users in a conversation,
+// including its creator.
+const maxConversationMembers = 10
+
+// StartConversation returns an ID of a new conversation between
+// the current user and given ones.
+// Direct conversation with a single user is created once,
+// its existing ID is returned on subsequent calls.
+//
+// Users blocking the current user (or blocked by it) can't be messaged.
+// Protected users accept messages only from users they are subscribed to.
+func (w Woofer) StartConversation(ctx context.Context, nicknames []string) (uint64, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't get UserID for request")
+	}
+
+	wanted := map[string]bool{}
+	for _, n := range nicknames {
+		wanted[n] = true
+	}
+	users, err := w.userStorage.GetByNicknames(ctx, nicknames)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't retrieve users")
+	}
+	if len(users) < len(wanted) {
+		return 0, bizerr.New("some of the users were not found", bizerr.ErrorNotFound)
+	}
+
+	members := []domain.UserID{userID}
+	seen := map[domain.UserID]bool{userID: true}
+	for _, u := range users {
+		if seen[u.ID] {
+			continue
+		}
+		seen[u.ID] = true
+		members = append(members, u.ID)
+
+		err = w.canMessage(ctx, userID, u.ID)
+		if err != nil {
+			return 0, err
+		}
+		if !u.Protected {
+			continue
+		}
+		subbed, err := w.subStorage.IsSubscribed(ctx, u.ID, userID)
+		if err != nil {
+			return 0, errors.Wrap(err, "couldn't check subscription")
+		}
+		if !subbed {
+			return 0, bizerr.New("can't message protected user "+u.Nickname, bizerr.ErrorUnauthorized)
+		}
+	}
+	if len(members) < 2 {
+		return 0, bizerr.New("conversation needs someone besides you", bizerr.ErrorUserInput)
+	}
+	if len(members) > maxConversationMembers {
+		return 0, bizerr.New("too many users in a conversation", bizerr.ErrorUserInput)
+	}
+
+	if len(members) == 2 {
+		id, err := w.messages.DirectConversation(ctx, members[0], members[1])
+		if bizerr.Type(err) != bizerr.ErrorNotFound {
+			return id, errors.Wrap(err, "couldn't find a conversation")
+		}
+	}
+	id, err := w.messages.NewConversation(ctx, members, time.Now())
+	return id, errors.Wrap(err, "couldn't create a conversation")
+}
+
+// Conversations returns all conversations of the current user,
+// the most recently active ones first.
+func (w Woofer) Conversations(ctx context.Context) ([]domain.ConversationWithUsers, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get UserID for request")
+	}
+
+	convs, err := w.messages.Conversations(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't retrieve conversations")
+	}
+	return w.withMembers(ctx, convs)
+}
+
+// Conversation returns a single conversation of the current user.
+func (w Woofer) Conversation(ctx context.Context, id uint64) (domain.ConversationWithUsers, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return domain.ConversationWithUsers{}, errors.Wrap(err, "couldn't get UserID for request")
+	}
+
+	conv, err := w.messages.Conversation(ctx, id, userID)
+	if err != nil {
+		return domain.ConversationWithUsers{}, err
+	}
+	ret, err := w.withMembers(ctx, []domain.Conversation{conv})
+	if err != nil {
+		return domain.ConversationWithUsers{}, err
+	}
+	return ret[0], nil
+}
+
+// withMembers fills conversations' members' profiles.
+func (w Woofer) withMembers(ctx context.Context, convs []domain.Conversation) ([]domain.ConversationWithUsers, error) {
+	var ids []domain.UserID
+	seen := map[domain.UserID]bool{}
+	for _, c := range convs {
+		for _, id := range c.Members {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	users, err := w.userStorage.GetByIds(ctx, ids)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't retrieve conversation members")
+	}
+	byID := make(map[domain.UserID]domain.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	ret := make([]domain.ConversationWithUsers, len(convs))
+	for i, c := range convs {
+		ret[i].Conversation = c
+		ret[i].Members = make([]domain.User, 0, len(c.Members))
+		for _, id := range c.Members {
+			if u, ok := byID[id]; ok {
+				ret[i].Members = append(ret[i].Members, u)
+			}
+		}
+		sort.Slice(ret[i].Members, func(a, b int) bool {
+			return ret[i].Members[a].Nickname < ret[i].Members[b].Nickname
+		})
+	}
+	return ret, nil
+}
+
+// GetMessages returns a page of the conversation's messages.
+func (w Woofer) GetMessages(ctx context.Context, conversationID uint64, q PageQuery) (domain.MessagePage, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return domain.MessagePage{}, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	page, err := q.page()
+	if err != nil {
+		return domain.MessagePage{}, err
+	}
+
+	// checks membership
+	_, err = w.messages.Conversation(ctx, conversationID, userID)
+	if err != nil {
+		return domain.MessagePage{}, err
+	}
+	ret, err := w.messages.GetMessagePage(ctx, conversationID, page)
+	if err != nil {
+		return domain.MessagePage{}, errors.Wrap(err, "couldn't retrieve messages")
+	}
+	return newMessagePage(ret, page), nil
+}
+
+// SendMessage sends a message to the conversation, returning its ID.
+// Message can't be sent if the sender and any other member
+// block each other.
+func (w Woofer) SendMessage(ctx context.Context, conversationID uint64, text string) (uint64, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	if len(text) == 0 {
+		return 0, bizerr.New("message cannot be empty", bizerr.ErrorUserInput)
+	}
+
+	conv, err := w.messages.Conversation(ctx, conversationID, userID)
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range conv.Members {
+		if id == userID {
+			continue
+		}
+		err = w.canMessage(ctx, userID, id)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	id, err := w.messages.SendMessage(ctx, domain.Message{
+		ConversationID: conversationID,
+		From:           userID,
+		At:             time.Now(),
+		Text:           text,
+	})
+	return id, errors.Wrap(err, "couldn't send a message")
+}
+
+// ReadConversation marks all messages of the conversation read
+// by the current user.
+func (w Woofer) ReadConversation(ctx context.Context, conversationID uint64) error {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return errors.Wrap(err, "couldn't get UserID for request")
+	}
+	_, err = w.messages.Conversation(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	err = w.messages.MarkRead(ctx, conversationID, userID)
+	return errors.Wrap(err, "couldn't mark conversation as read")
+}
+
+// canMessage checks if the sender can message another user.
+func (w Woofer) canMessage(ctx context.Context, from, to domain.UserID) error {
+	blocked, err := w.blocks.IsBlocked(ctx, from, to)
+	if err != nil {
+		return errors.Wrap(err, "couldn't check blocks")
+	}
+	if blocked {
+		return bizerr.New("can't message this user", bizerr.ErrorUnauthorized)
+	}
+	return nil
+}
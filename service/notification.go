@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/auth"
+	"github.com/utrack/woofer/service/internal/events"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+// notificationKinds maps events users are notified about
+// to kinds of their notifications.
+// New notifications are added by publishing new events and listing them here.
+var notificationKinds = map[events.Kind]domain.NotificationKind{
+	events.Subscribed:      domain.NotifySubscribed,
+	events.FollowRequested: domain.NotifyFollowRequested,
+	events.Mentioned:       domain.NotifyMentioned,
+	events.Replied:         domain.NotifyReplied,
+	events.Liked:           domain.NotifyLiked,
+}
+
+// publish publishes an event of one user's action towards the other one.
+// Actions towards oneself are not published.
+func (w Woofer) publish(ctx context.Context, kind events.Kind, from, to domain.UserID, tweetID uint64) {
+	if from == to {
+		return
+	}
+	w.events.Publish(ctx, events.Event{
+		Kind:    kind,
+		From:    from,
+		To:      to,
+		TweetID: tweetID,
+		At:      time.Now(),
+	})
+}
+
+// notify is an event handler saving notifications about the events.
+// Users blocking each other are not notified about each other's actions.
+func (w Woofer) notify(ctx context.Context, e events.Event) error {
+	kind, ok := notificationKinds[e.Kind]
+	if !ok {
+		return nil
+	}
+	blocked, err := w.blocks.IsBlocked(ctx, e.From, e.To)
+	if err != nil {
+		return errors.Wrap(err, "couldn't check blocks")
+	}
+	if blocked {
+		return nil
+	}
+	id, err := w.notes.Notify(ctx, e.To, storage.Notification{
+		Kind:    kind,
+		From:    e.From,
+		TweetID: e.TweetID,
+		At:      e.At,
+	})
+	if err != nil {
+		return errors.Wrap(err, "couldn't save a notification")
+	}
+	if id == 0 || !w.hub.Connected(e.To) {
+		return nil
+	}
+
+	from, err := w.userStorage.GetByIds(ctx, []domain.UserID{e.From})
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve notification's author")
+	}
+	n := domain.Notification{ID: id, Kind: kind, TweetID: e.TweetID, At: e.At}
+	if len(from) > 0 {
+		n.From = from[0].Nickname
+	}
+	w.hub.Publish(e.To, domain.StreamEvent{Notification: &n})
+	return nil
+}
+
+// GetNotifications returns a page of the current user's notifications.
+func (w Woofer) GetNotifications(ctx context.Context, q PageQuery) (domain.NotificationPage, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return domain.NotificationPage{}, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	page, err := q.page()
+	if err != nil {
+		return domain.NotificationPage{}, err
+	}
+
+	ret, err := w.notes.GetNotificationsPage(ctx, userID, page)
+	if err != nil {
+		return domain.NotificationPage{}, errors.Wrap(err, "couldn't retrieve notifications")
+	}
+	unread, err := w.notes.UnreadNotifications(ctx, userID)
+	if err != nil {
+		return domain.NotificationPage{}, errors.Wrap(err, "couldn't count unread notifications")
+	}
+	return newNotificationPage(ret, unread, page), nil
+}
+
+// ReadNotifications marks the current user's notifications with IDs
+// up to upTo as read, or all of them if upTo is 0.
+func (w Woofer) ReadNotifications(ctx context.Context, upTo uint64) error {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return errors.Wrap(err, "couldn't get UserID for request")
+	}
+	err = w.notes.ReadNotifications(ctx, userID, upTo)
+	return errors.Wrap(err, "couldn't mark notifications as read")
+}
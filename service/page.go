@@ -0,0 +1,155 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"reflect"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+const (
+	// defaultPageLen is a number of tweets returned per page by default.
+	defaultPageLen = 30
+	// maxPageLen is a max number of tweets that can be requested per page.
+	maxPageLen = 100
+	// maxThreadDepth is a max number of ancestors returned for a tweet.
+	maxThreadDepth = 100
+)
+
+// ErrBadCursor is returned if a page cursor can't be parsed.
+var ErrBadCursor = bizerr.New("malformed page cursor", bizerr.ErrorUserInput)
+
+// PageQuery requests a page of a tweet list.
+// Cursors are taken from the domain.TweetPage returned previously.
+type PageQuery struct {
+	// Before requests tweets older than the cursor.
+	Before string
+	// After requests tweets newer than the cursor.
+	After string
+	// Limit is a max number of tweets per page.
+	// Default is used if it's zero.
+	Limit uint
+}
+
+// page converts a query to the storage's page.
+func (q PageQuery) page() (storage.Page, error) {
+	var ret storage.Page
+	var err error
+	if q.Before != "" {
+		ret.Before, err = decodeCursor(q.Before)
+		if err != nil {
+			return ret, err
+		}
+	}
+	if q.After != "" {
+		ret.After, err = decodeCursor(q.After)
+		if err != nil {
+			return ret, err
+		}
+	}
+
+	ret.Len = q.Limit
+	if ret.Len == 0 {
+		ret.Len = defaultPageLen
+	}
+	if ret.Len > maxPageLen {
+		ret.Len = maxPageLen
+	}
+	return ret, nil
+}
+
+// newTweetPage creates a page with cursors pointing to its neighbours.
+func newTweetPage(tweets []domain.TweetWithUsername, page storage.Page) domain.TweetPage {
+	newestFirst(tweets, page)
+	ret := domain.TweetPage{Tweets: tweets}
+	var first, last uint64
+	if len(tweets) > 0 {
+		first, last = tweets[0].ID, tweets[len(tweets)-1].ID
+	}
+	ret.Prev, ret.Next = pageCursors(len(tweets), first, last, page)
+	return ret
+}
+
+// newLikedPage creates a page of liked tweets with cursors pointing to its
+// neighbours. Liked tweets are paged by their likes, not by the tweets.
+func newLikedPage(tweets []domain.TweetWithUsername, liked []storage.LikedTweet, page storage.Page) domain.TweetPage {
+	ret := domain.TweetPage{Tweets: tweets}
+	var first, last uint64
+	if len(liked) > 0 {
+		first, last = liked[0].LikeID, liked[len(liked)-1].LikeID
+	}
+	ret.Prev, ret.Next = pageCursors(len(liked), first, last, page)
+	return ret
+}
+
+// newMessagePage creates a page with cursors pointing to its neighbours.
+func newMessagePage(msgs []domain.Message, page storage.Page) domain.MessagePage {
+	newestFirst(msgs, page)
+	ret := domain.MessagePage{Messages: msgs}
+	var first, last uint64
+	if len(msgs) > 0 {
+		first, last = msgs[0].ID, msgs[len(msgs)-1].ID
+	}
+	ret.Prev, ret.Next = pageCursors(len(msgs), first, last, page)
+	return ret
+}
+
+// newNotificationPage creates a page with cursors pointing to its neighbours.
+func newNotificationPage(ns []domain.Notification, unread uint64, page storage.Page) domain.NotificationPage {
+	newestFirst(ns, page)
+	ret := domain.NotificationPage{Notifications: ns, Unread: unread}
+	var first, last uint64
+	if len(ns) > 0 {
+		first, last = ns[0].ID, ns[len(ns)-1].ID
+	}
+	ret.Prev, ret.Next = pageCursors(len(ns), first, last, page)
+	return ret
+}
+
+// newestFirst reorders a slice of items read in the page's order
+// so the newest item comes first.
+func newestFirst(items interface{}, page storage.Page) {
+	if !page.Ascending() {
+		return
+	}
+	swap := reflect.Swapper(items)
+	for i, j := 0, reflect.ValueOf(items).Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
+
+// pageCursors returns cursors pointing to neighbours of a page having
+// n items with IDs from first (the newest one) to last.
+func pageCursors(n int, first, last uint64, page storage.Page) (prev string, next string) {
+	if n == 0 {
+		// nothing new yet, client should come back with the same cursor
+		if page.After != 0 {
+			prev = encodeCursor(page.After)
+		}
+		return prev, ""
+	}
+
+	prev = encodeCursor(first)
+	// page was read starting from After, so there's more below it
+	if uint(n) == page.Len || page.Ascending() {
+		next = encodeCursor(last)
+	}
+	return prev, next
+}
+
+func encodeCursor(id uint64) string {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], id)
+	return base64.RawURLEncoding.EncodeToString(buf[:])
+}
+
+func decodeCursor(c string) (uint64, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil || len(buf) != 8 {
+		return 0, ErrBadCursor
+	}
+	return binary.BigEndian.Uint64(buf), nil
+}
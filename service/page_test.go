@@ -0,0 +1,41 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+func TestPagesAreNewestFirst(t *testing.T) {
+	tweets := func(ids ...uint64) []domain.TweetWithUsername {
+		ret := make([]domain.TweetWithUsername, len(ids))
+		for i, id := range ids {
+			ret[i].ID = id
+		}
+		return ret
+	}
+	tests := []struct {
+		name string
+		page storage.Page
+		read []domain.TweetWithUsername
+	}{
+		{"descending", storage.Page{Before: 5, Len: 3}, tweets(4, 3, 2)},
+		{"ascending", storage.Page{After: 1, Len: 3}, tweets(2, 3, 4)},
+	}
+	for _, tc := range tests {
+		got := newTweetPage(tc.read, tc.page).Tweets
+		if len(got) != 3 || got[0].ID != 4 || got[1].ID != 3 || got[2].ID != 2 {
+			t.Errorf("%v: got tweets %+v, want 4, 3, 2", tc.name, got)
+		}
+	}
+
+	msgs := newMessagePage([]domain.Message{{ID: 2}, {ID: 3}}, storage.Page{After: 1, Len: 2}).Messages
+	if msgs[0].ID != 3 || msgs[1].ID != 2 {
+		t.Errorf("got messages %+v, want 3, 2", msgs)
+	}
+	ns := newNotificationPage([]domain.Notification{{ID: 2}, {ID: 3}}, 0, storage.Page{After: 1, Len: 2}).Notifications
+	if ns[0].ID != 3 || ns[1].ID != 2 {
+		t.Errorf("got notifications %+v, want 3, 2", ns)
+	}
+}
@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/auth"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+const (
+	// searchDateFormat is a format of since: and until: search operators.
+	searchDateFormat = "2006-01-02"
+
+	// defaultUserSearchLen is a number of users found by default.
+	defaultUserSearchLen = 10
+	// maxUserSearchLen is a max number of users that can be requested.
+	maxUserSearchLen = 50
+)
+
+// SearchTweets returns tweets matching the search query, the most relevant
+// ones first.
+//
+// Query consists of words which should all be present in a tweet,
+// "quoted phrases" and operators:
+//
+//	from:nickname      tweets of a single user
+//	since:2006-01-02   tweets published on the date or later
+//	until:2006-01-02   tweets published on the date or earlier
+//
+// Results are ranked, so their page cursors are offsets in the result
+// list instead of tweet IDs; they should be used with the same query only.
+func (w Woofer) SearchTweets(ctx context.Context, query string, q PageQuery) (domain.TweetPage, error) {
+	sq, err := parseSearchQuery(query)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+	page, err := q.page()
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+
+	offset, limit := searchWindow(page)
+	sq.Viewer, _ = auth.UserID(ctx)
+	ret, err := w.search.SearchTweets(ctx, sq, uint(offset), limit)
+	if err != nil {
+		return domain.TweetPage{}, errors.Wrap(err, "couldn't search tweets")
+	}
+	err = w.hydrate(ctx, ret)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+
+	res := domain.TweetPage{Tweets: ret}
+	if offset > 0 {
+		res.Prev = encodeCursor(offset)
+	}
+	if uint(len(ret)) == limit {
+		res.Next = encodeCursor(offset + uint64(len(ret)))
+	}
+	return res, nil
+}
+
+// searchWindow returns an offset and a length of the search results page.
+// Before cursor points to the start of the next page, After - to the end
+// of the previous one, which may be shorter than the page if it's the first.
+func searchWindow(page storage.Page) (offset uint64, limit uint) {
+	if page.After == 0 {
+		return page.Before, page.Len
+	}
+	if page.After < uint64(page.Len) {
+		return 0, uint(page.After)
+	}
+	return page.After - uint64(page.Len), page.Len
+}
+
+// parseSearchQuery parses a query of SearchTweets.
+func parseSearchQuery(query string) (storage.SearchQuery, error) {
+	var ret storage.SearchQuery
+	for {
+		query = strings.TrimSpace(query)
+		if query == "" {
+			break
+		}
+
+		if query[0] == '"' {
+			// unterminated phrase lasts till the end of the query
+			phrase := query[1:]
+			query = ""
+			if end := strings.IndexByte(phrase, '"'); end != -1 {
+				phrase, query = phrase[:end], phrase[end+1:]
+			}
+			if phrase = strings.TrimSpace(phrase); phrase != "" {
+				ret.Phrases = append(ret.Phrases, phrase)
+			}
+			continue
+		}
+
+		word := query
+		if end := strings.IndexAny(query, " \t\n\""); end != -1 {
+			word = query[:end]
+		}
+		query = query[len(word):]
+
+		var err error
+		switch {
+		case strings.HasPrefix(word, "from:"):
+			ret.From = strings.TrimPrefix(strings.TrimPrefix(word, "from:"), "@")
+			if ret.From == "" {
+				err = bizerr.New("from: needs a nickname", bizerr.ErrorUserInput)
+			}
+		case strings.HasPrefix(word, "since:"):
+			ret.Since, err = parseSearchDate(strings.TrimPrefix(word, "since:"))
+		case strings.HasPrefix(word, "until:"):
+			ret.Until, err = parseSearchDate(strings.TrimPrefix(word, "until:"))
+			// until: is inclusive for users
+			ret.Until = ret.Until.AddDate(0, 0, 1)
+		default:
+			ret.Words = append(ret.Words, word)
+		}
+		if err != nil {
+			return ret, err
+		}
+	}
+
+	if !ret.HasText() && ret.From == "" && ret.Since.IsZero() && ret.Until.IsZero() {
+		return ret, bizerr.New("search query cannot be empty", bizerr.ErrorUserInput)
+	}
+	return ret, nil
+}
+
+func parseSearchDate(date string) (time.Time, error) {
+	ret, err := time.Parse(searchDateFormat, date)
+	if err != nil {
+		return ret, bizerr.Wrap(errors.Wrapf(err, "couldn't parse date %q", date), bizerr.ErrorUserInput)
+	}
+	return ret, nil
+}
+
+// SearchUsers returns users whose nickname or name matches the query,
+// the best matches first. Users the caller follows or is followed by
+// go first among equally matching ones.
+// Leading '@' of the query is ignored, so it can be used to autocomplete
+// mentions as they're typed.
+func (w Woofer) SearchUsers(ctx context.Context, query string, limit uint) ([]domain.User, error) {
+	query = strings.TrimPrefix(strings.TrimSpace(query), "@")
+	if query == "" {
+		return nil, bizerr.New("search query cannot be empty", bizerr.ErrorUserInput)
+	}
+	if limit == 0 {
+		limit = defaultUserSearchLen
+	}
+	if limit > maxUserSearchLen {
+		limit = maxUserSearchLen
+	}
+
+	// anonymous users get results without relationships
+	viewer, _ := auth.UserID(ctx)
+	ret, err := w.userStorage.SearchUsers(ctx, viewer, query, limit)
+	return ret, errors.Wrap(err, "couldn't search users")
+}
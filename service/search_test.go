@@ -0,0 +1,74 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2018, time.May, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		query string
+		want  storage.SearchQuery
+	}{
+		{"good  dog", storage.SearchQuery{Words: []string{"good", "dog"}}},
+		{`a "good dog" b`, storage.SearchQuery{Words: []string{"a", "b"}, Phrases: []string{"good dog"}}},
+		{`dog"good boy"`, storage.SearchQuery{Words: []string{"dog"}, Phrases: []string{"good boy"}}},
+		// unterminated phrase lasts till the end of the query
+		{`dog "good boy`, storage.SearchQuery{Words: []string{"dog"}, Phrases: []string{"good boy"}}},
+		{`dog " `, storage.SearchQuery{Words: []string{"dog"}}},
+		{"from:@alice dog", storage.SearchQuery{Words: []string{"dog"}, From: "alice"}},
+		{"from:alice", storage.SearchQuery{From: "alice"}},
+		{"since:2018-05-02", storage.SearchQuery{Since: day(2)}},
+		// until: is inclusive, storage's Until isn't
+		{"until:2018-05-02", storage.SearchQuery{Until: day(3)}},
+		{"since:2018-05-02 until:2018-05-02", storage.SearchQuery{Since: day(2), Until: day(3)}},
+	}
+	for _, tc := range tests {
+		got, err := parseSearchQuery(tc.query)
+		if err != nil {
+			t.Errorf("%q: %v", tc.query, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%q: got %+v, want %+v", tc.query, got, tc.want)
+		}
+	}
+
+	for _, query := range []string{"", "  ", `""`, "from:", "from:@", "from: dog", "since:yesterday", "until:2018-13-01"} {
+		_, err := parseSearchQuery(query)
+		if bizerr.Type(err) != bizerr.ErrorUserInput {
+			t.Errorf("%q: got %v, want user input error", query, err)
+		}
+	}
+}
+
+func TestSearchWindow(t *testing.T) {
+	tests := []struct {
+		name   string
+		page   storage.Page
+		offset uint64
+		limit  uint
+	}{
+		{"first", storage.Page{Len: 10}, 0, 10},
+		{"next", storage.Page{Before: 10, Len: 10}, 10, 10},
+		{"previous", storage.Page{After: 20, Len: 10}, 10, 10},
+		{"previous with larger limit", storage.Page{After: 20, Len: 15}, 5, 15},
+		{"previous with smaller limit", storage.Page{After: 20, Len: 5}, 15, 5},
+		// the first page is shorter, results after the cursor aren't repeated
+		{"previous first", storage.Page{After: 5, Len: 10}, 0, 5},
+		{"previous first exactly", storage.Page{After: 10, Len: 10}, 0, 10},
+	}
+	for _, tc := range tests {
+		offset, limit := searchWindow(tc.page)
+		if offset != tc.offset || limit != tc.limit {
+			t.Errorf("%v: got offset %v, limit %v; want %v, %v", tc.name, offset, limit, tc.offset, tc.limit)
+		}
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"context"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/pkg/errors"
 	"github.com/utrack/woofer/domain"
 	"github.com/utrack/woofer/lib/auth"
 	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/events"
+	"github.com/utrack/woofer/service/internal/hub"
 	"github.com/utrack/woofer/service/internal/storage"
 )
 
@@ -18,7 +21,27 @@ type Woofer struct {
 	tweetStorage storage.TweetStorage
 	userStorage  storage.UserStorage
 	subStorage   storage.SubsStorage
+	follows      storage.FollowRequestStorage
+	blocks       storage.BlockStorage
+	mutes        storage.MuteStorage
 	passCheck    storage.PasswordManager
+	inbox        storage.InboxStorage
+	likes        storage.LikeStorage
+	mentions     storage.MentionStorage
+	hashtags     storage.HashtagStorage
+	search       storage.TweetSearcher
+	messages     storage.MessageStorage
+	notes        storage.NotificationStorage
+
+	// events notifies other parts of the service about users' actions.
+	events *events.Bus
+	// hub pushes updates to users' streams.
+	hub *hub.Hub
+
+	// fanoutLimit is a max number of subscribers a user can have
+	// to get its tweets delivered to their inboxes on write.
+	// Fan-out-on-write is disabled if it's zero.
+	fanoutLimit uint
 }
 
 var (
@@ -26,7 +49,8 @@ var (
 )
 
 // Tweet posts a new tweet.
-func (w Woofer) Tweet(ctx context.Context, text string) (uint64, error) {
+// inReplyTo is an ID of a tweet being replied to, or 0.
+func (w Woofer) Tweet(ctx context.Context, text string, inReplyTo uint64) (uint64, error) {
 	userID, err := auth.UserID(ctx)
 	if err != nil {
 		return 0, errors.Wrap(err, "couldn't get UserID for request")
@@ -36,50 +60,717 @@ func (w Woofer) Tweet(ctx context.Context, text string) (uint64, error) {
 		return 0, bizerr.New("tweet cannot be empty", bizerr.ErrorUserInput)
 	}
 
+	var parent domain.Tweet
+	if inReplyTo != 0 {
+		parent, err = w.tweetStorage.ByID(ctx, inReplyTo)
+		if bizerr.Type(err) == bizerr.ErrorNotFound {
+			return 0, bizerr.New("tweet being replied to does not exist", bizerr.ErrorUserInput)
+		}
+		if err != nil {
+			return 0, errors.Wrap(err, "couldn't retrieve a tweet being replied to")
+		}
+		_, err = w.canViewTweet(ctx, userID, parent)
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	t.From = userID
 	t.At = time.Now()
 	t.Text = text
+	t.InReplyTo = inReplyTo
+	ret, err := w.post(ctx, t)
+	if err != nil {
+		return ret, err
+	}
+	if inReplyTo != 0 {
+		w.publish(ctx, events.Replied, userID, parent.From, ret)
+	}
+	return ret, nil
+}
+
+// Retweet reshares a tweet. Retweet with a non-empty comment is a quote tweet.
+// User can make a single plain retweet of every tweet.
+func (w Woofer) Retweet(ctx context.Context, tweetID uint64, comment string) (uint64, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	orig, err := w.tweetStorage.ByID(ctx, tweetID)
+	if err != nil {
+		return 0, err
+	}
+	// retweeting a plain retweet reshares the original tweet
+	if orig.IsPlainRetweet() {
+		tweetID = orig.RetweetOf
+		orig, err = w.tweetStorage.ByID(ctx, tweetID)
+		if err != nil {
+			return 0, err
+		}
+	}
+	author, err := w.canViewTweet(ctx, userID, orig)
+	if err != nil {
+		return 0, err
+	}
+	// retweets are shown to retweeter's subscribers along with the original
+	if author.Protected && author.ID != userID {
+		return 0, bizerr.New("protected tweets cannot be retweeted", bizerr.ErrorUnauthorized)
+	}
+
+	return w.post(ctx, domain.Tweet{
+		From:      userID,
+		At:        time.Now(),
+		Text:      comment,
+		RetweetOf: tweetID,
+	})
+}
+
+// EditTweet replaces the text of a current user's tweet.
+// Previous versions can be read via TweetHistory.
+func (w Woofer) EditTweet(ctx context.Context, tweetID uint64, text string) error {
+	t, err := w.ownTweet(ctx, tweetID)
+	if err != nil {
+		return err
+	}
+	if len(text) == 0 {
+		return bizerr.New("tweet cannot be empty", bizerr.ErrorUserInput)
+	}
+	if t.IsPlainRetweet() {
+		return bizerr.New("plain retweets cannot be edited", bizerr.ErrorUserInput)
+	}
+	err = w.tweetStorage.Edit(ctx, tweetID, text, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "couldn't edit a tweet")
+	}
+	// the edit is saved, failing to index it mustn't make the client retry
+	w.index(ctx, t.From, tweetID, text)
+	return nil
+}
+
+// DeleteTweet deletes a current user's tweet.
+func (w Woofer) DeleteTweet(ctx context.Context, tweetID uint64) error {
+	_, err := w.ownTweet(ctx, tweetID)
+	if err != nil {
+		return err
+	}
+	err = w.tweetStorage.Delete(ctx, tweetID)
+	return errors.Wrap(err, "couldn't delete a tweet")
+}
+
+// ownTweet returns a tweet if it was posted by the current user.
+func (w Woofer) ownTweet(ctx context.Context, tweetID uint64) (domain.Tweet, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return domain.Tweet{}, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	t, err := w.tweetStorage.ByID(ctx, tweetID)
+	if err != nil {
+		return domain.Tweet{}, err
+	}
+	if t.From != userID {
+		return domain.Tweet{}, bizerr.New("only the author can change a tweet", bizerr.ErrorUnauthorized)
+	}
+	return t, nil
+}
+
+// TweetHistory returns all versions of a tweet, starting from the original
+// one and ending with the current one.
+func (w Woofer) TweetHistory(ctx context.Context, tweetID uint64) ([]domain.TweetRevision, error) {
+	t, err := w.tweetStorage.ByID(ctx, tweetID)
+	if err != nil {
+		return nil, err
+	}
+	viewer, _ := auth.UserID(ctx)
+	_, err = w.canViewTweet(ctx, viewer, t)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := w.tweetStorage.Revisions(ctx, tweetID)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't retrieve tweet's revisions")
+	}
+	cur := domain.TweetRevision{Text: t.Text, At: t.At}
+	if t.EditedAt != nil {
+		cur.At = *t.EditedAt
+	}
+	return append(ret, cur), nil
+}
 
+// post saves a tweet and delivers it to the subscribers.
+func (w Woofer) post(ctx context.Context, t domain.Tweet) (uint64, error) {
 	ret, err := w.tweetStorage.Tweet(ctx, t)
-	return ret, errors.Wrap(err, "couldn't post a tweet")
+	if bizerr.Type(err) == bizerr.ErrorConflict {
+		return 0, err
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "couldn't post a tweet")
+	}
+	// the tweet is saved already, so the errors below are only logged:
+	// returning them would make the client post it again
+	w.index(ctx, t.From, ret, t.Text)
+	err = w.deliver(ctx, ret, t.From)
+	if err != nil {
+		logrus.Error(err)
+	}
+	w.publish(ctx, events.Tweeted, t.From, 0, ret)
+	return ret, nil
+}
+
+// index saves the mentions and hashtags of a tweet, logging the errors.
+func (w Woofer) index(ctx context.Context, author domain.UserID, tweetID uint64, text string) {
+	err := w.mention(ctx, author, tweetID, text)
+	if err != nil {
+		logrus.Error(err)
+	}
+	err = w.tag(ctx, tweetID, text)
+	if err != nil {
+		logrus.Error(err)
+	}
+}
+
+// deliver puts a new tweet to the inboxes of the author's subscribers
+// if fan-out-on-write is enabled.
+func (w Woofer) deliver(ctx context.Context, tweetID uint64, author domain.UserID) error {
+	if w.fanoutLimit == 0 {
+		return nil
+	}
+
+	subs, err := w.subStorage.Subbed(ctx, author)
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve subscribers list")
+	}
+	if uint(len(subs)) > w.fanoutLimit {
+		// too many subscribers, they'll read tweets on request
+		err = w.inbox.Pull(ctx, tweetID)
+		return errors.Wrap(err, "couldn't mark a tweet as pulled")
+	}
+	err = w.inbox.Deliver(ctx, tweetID, subs)
+	return errors.Wrap(err, "couldn't deliver a tweet to subscribers")
+}
+
+// hydrate fills tweets' fields that are not stored with the tweets.
+func (w Woofer) hydrate(ctx context.Context, tweets []domain.TweetWithUsername) error {
+	err := w.withOriginals(ctx, tweets)
+	if err != nil {
+		return err
+	}
+	err = w.withLikes(ctx, tweets)
+	if err != nil {
+		return err
+	}
+	return w.withMentions(ctx, tweets)
+}
+
+// withOriginals attaches reshared tweets to the retweets.
+// Tweets the viewer can't see are not attached.
+func (w Woofer) withOriginals(ctx context.Context, tweets []domain.TweetWithUsername) error {
+	var ids []uint64
+	for _, t := range tweets {
+		if t.RetweetOf != 0 {
+			ids = append(ids, t.RetweetOf)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	orig, err := w.tweetStorage.ByIDs(ctx, ids)
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve retweeted tweets")
+	}
+
+	// authors of reshared tweets could become protected since then
+	authors := make([]domain.UserID, 0, len(orig))
+	for _, t := range orig {
+		authors = append(authors, t.Tweet.From)
+	}
+	users, err := w.userStorage.GetByIds(ctx, authors)
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve authors of retweeted tweets")
+	}
+	viewer, _ := auth.UserID(ctx)
+	visible := make(map[domain.UserID]bool, len(users))
+	for _, u := range users {
+		err = w.canView(ctx, viewer, u)
+		if err == errProtected {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		visible[u.ID] = true
+	}
+
+	byID := make(map[uint64]*domain.TweetWithUsername, len(orig))
+	for i := range orig {
+		if visible[orig[i].Tweet.From] {
+			byID[orig[i].ID] = &orig[i]
+		}
+	}
+	for i := range tweets {
+		if tweets[i].RetweetOf != 0 {
+			tweets[i].Original = byID[tweets[i].RetweetOf]
+		}
+	}
+	return nil
+}
+
+// withLikes attaches like stats to the tweets and the tweets they reshare.
+func (w Woofer) withLikes(ctx context.Context, tweets []domain.TweetWithUsername) error {
+	ids := make([]uint64, 0, len(tweets))
+	for _, t := range tweets {
+		ids = append(ids, t.ID)
+		if t.Original != nil {
+			ids = append(ids, t.Original.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// anonymous users see like counts only
+	viewer, _ := auth.UserID(ctx)
+	stats, err := w.likes.LikeStats(ctx, viewer, ids)
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve likes")
+	}
+	for i := range tweets {
+		st := stats[tweets[i].ID]
+		tweets[i].Likes, tweets[i].LikedByMe = st.Count, st.Liked
+		if orig := tweets[i].Original; orig != nil {
+			st = stats[orig.ID]
+			orig.Likes, orig.LikedByMe = st.Count, st.Liked
+		}
+	}
+	return nil
+}
+
+// withMentions attaches mentioned users to the tweets and the tweets
+// they reshare.
+func (w Woofer) withMentions(ctx context.Context, tweets []domain.TweetWithUsername) error {
+	ids := make([]uint64, 0, len(tweets))
+	for _, t := range tweets {
+		ids = append(ids, t.ID)
+		if t.Original != nil {
+			ids = append(ids, t.Original.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	mentions, err := w.mentions.Mentions(ctx, ids)
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve mentions")
+	}
+	for i := range tweets {
+		tweets[i].Mentions = mentions[tweets[i].ID]
+		if orig := tweets[i].Original; orig != nil {
+			orig.Mentions = mentions[orig.ID]
+		}
+	}
+	return nil
 }
 
 // GetTweetPage returns a page of tweets for the current user.
-// User can request next pages giving us a tweet ID to start the page from.
-func (w Woofer) GetTweetPage(ctx context.Context, from uint64) ([]domain.TweetWithUsername, error) {
+func (w Woofer) GetTweetPage(ctx context.Context, q PageQuery) (domain.TweetPage, error) {
 	userID, err := auth.UserID(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "couldn't get UserID for request")
+		return domain.TweetPage{}, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	page, err := q.page()
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+
+	ret, err := w.timeline(ctx, userID, page)
+	if err != nil {
+		return domain.TweetPage{}, err
 	}
+	return newTweetPage(ret, page), nil
+}
 
-	ret, err := w.tweetStorage.GetPageForUser(ctx, userID, from, 30)
-	return ret, errors.Wrap(err, "couldn't retrieve tweets")
+// timeline returns a hydrated page of user's home timeline.
+func (w Woofer) timeline(ctx context.Context, userID domain.UserID, page storage.Page) ([]domain.TweetWithUsername, error) {
+	var ret []domain.TweetWithUsername
+	var err error
+	if w.fanoutLimit > 0 {
+		ret, err = w.inbox.GetInboxPage(ctx, userID, page, w.fanoutLimit)
+	} else {
+		ret, err = w.tweetStorage.GetPageForUser(ctx, userID, page)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't retrieve tweets")
+	}
+	return ret, w.hydrate(ctx, ret)
 }
 
 // GetTweetsForProfile returns a tweet list for given user.
-func (w Woofer) GetTweetsForProfile(ctx context.Context, user string, from uint64) ([]domain.TweetWithUsername, error) {
+func (w Woofer) GetTweetsForProfile(ctx context.Context, user string, q PageQuery) (domain.TweetPage, error) {
+	page, err := q.page()
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
 
 	tgt, err := w.userStorage.GetByNickname(ctx, user)
 	if err != nil {
-		return nil, err
+		return domain.TweetPage{}, err
+	}
+	// blocked users don't see each other's tweets
+	viewer, _ := auth.UserID(ctx)
+	blocked, err := w.blocks.IsBlocked(ctx, viewer, tgt.ID)
+	if err != nil {
+		return domain.TweetPage{}, errors.Wrap(err, "couldn't check blocks")
+	}
+	if blocked {
+		return newTweetPage([]domain.TweetWithUsername{}, page), nil
+	}
+	err = w.canView(ctx, viewer, tgt)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+
+	ret, err := w.tweetStorage.GetPageForProfile(ctx, tgt.ID, page)
+	if err != nil {
+		return domain.TweetPage{}, errors.Wrap(err, "couldn't retrieve tweets")
 	}
+	err = w.hydrate(ctx, ret)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+	return newTweetPage(ret, page), nil
+}
 
-	ret, err := w.tweetStorage.GetPageForProfile(ctx, tgt.ID, from, 30)
-	return ret, errors.Wrap(err, "couldn't retrieve tweets")
+// errProtected is returned if the viewer isn't allowed to see
+// protected user's tweets.
+var errProtected = bizerr.New("this account's tweets are protected", bizerr.ErrorUnauthorized)
 
+// canView checks if the viewer can see tweets of the target user.
+// Protected users' tweets are visible to themselves and their
+// subscribers only.
+func (w Woofer) canView(ctx context.Context, viewer domain.UserID, tgt domain.User) error {
+	if !tgt.Protected || viewer == tgt.ID {
+		return nil
+	}
+	if viewer == 0 {
+		return errProtected
+	}
+	subbed, err := w.subStorage.IsSubscribed(ctx, viewer, tgt.ID)
+	if err != nil {
+		return errors.Wrap(err, "couldn't check subscription")
+	}
+	if !subbed {
+		return errProtected
+	}
+	return nil
 }
 
-// Subscribe subscribes current user to another one.
-func (w Woofer) Subscribe(ctx context.Context, targetNickname string) error {
+// canViewTweet checks if the viewer can see the tweet, returning
+// tweet's author.
+func (w Woofer) canViewTweet(ctx context.Context, viewer domain.UserID, t domain.Tweet) (domain.User, error) {
+	author, err := w.userStorage.GetByIds(ctx, []domain.UserID{t.From})
+	if err != nil {
+		return domain.User{}, errors.Wrap(err, "couldn't retrieve tweet's author")
+	}
+	if len(author) == 0 {
+		return domain.User{}, errors.Errorf("author %v of tweet %v not found", t.From, t.ID)
+	}
+	return author[0], w.canView(ctx, viewer, author[0])
+}
+
+// GetThread returns a conversation around the tweet.
+func (w Woofer) GetThread(ctx context.Context, tweetID uint64, q PageQuery) (domain.Thread, error) {
+	page, err := q.page()
+	if err != nil {
+		return domain.Thread{}, err
+	}
+
+	t, err := w.tweetStorage.ByID(ctx, tweetID)
+	if err != nil {
+		return domain.Thread{}, err
+	}
+	viewer, _ := auth.UserID(ctx)
+	author, err := w.canViewTweet(ctx, viewer, t)
+	if err != nil {
+		return domain.Thread{}, err
+	}
+	ret := domain.Thread{Tweet: domain.TweetWithUsername{Tweet: t, From: author.Nickname}}
+
+	ret.Ancestors, err = w.tweetStorage.Ancestors(ctx, viewer, tweetID, maxThreadDepth)
+	if err != nil {
+		return domain.Thread{}, errors.Wrap(err, "couldn't retrieve thread ancestors")
+	}
+	replies, err := w.tweetStorage.Descendants(ctx, viewer, tweetID, page)
+	if err != nil {
+		return domain.Thread{}, errors.Wrap(err, "couldn't retrieve replies")
+	}
+
+	// hydrate everything at once
+	all := make([]domain.TweetWithUsername, 0, 1+len(ret.Ancestors)+len(replies))
+	all = append(all, ret.Tweet)
+	all = append(all, ret.Ancestors...)
+	all = append(all, replies...)
+	err = w.hydrate(ctx, all)
+	if err != nil {
+		return domain.Thread{}, err
+	}
+	ret.Tweet = all[0]
+	copy(ret.Ancestors, all[1:])
+	copy(replies, all[1+len(ret.Ancestors):])
+
+	ret.Replies = newTweetPage(replies, page)
+	return ret, nil
+}
+
+// Like marks a tweet as liked by the current user.
+func (w Woofer) Like(ctx context.Context, tweetID uint64) error {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return errors.Wrap(err, "couldn't get UserID for request")
+	}
+	t, err := w.tweetStorage.ByID(ctx, tweetID)
+	if err != nil {
+		return err
+	}
+	_, err = w.canViewTweet(ctx, userID, t)
+	if err != nil {
+		return err
+	}
+	err = w.likes.Like(ctx, userID, tweetID, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "couldn't like a tweet")
+	}
+	w.publish(ctx, events.Liked, userID, t.From, tweetID)
+	return nil
+}
+
+// Unlike removes current user's like from a tweet.
+func (w Woofer) Unlike(ctx context.Context, tweetID uint64) error {
 	userID, err := auth.UserID(ctx)
 	if err != nil {
 		return errors.Wrap(err, "couldn't get UserID for request")
 	}
+	err = w.likes.Unlike(ctx, userID, tweetID)
+	return errors.Wrap(err, "couldn't unlike a tweet")
+}
+
+// GetLikedTweets returns a list of tweets liked by given user,
+// the most recently liked first.
+func (w Woofer) GetLikedTweets(ctx context.Context, user string, q PageQuery) (domain.TweetPage, error) {
+	page, err := q.page()
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+
+	tgt, err := w.userStorage.GetByNickname(ctx, user)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+	viewer, _ := auth.UserID(ctx)
+	err = w.canView(ctx, viewer, tgt)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+
+	liked, err := w.likes.GetLikedPage(ctx, viewer, tgt.ID, page)
+	if err != nil {
+		return domain.TweetPage{}, errors.Wrap(err, "couldn't retrieve tweets")
+	}
+	newestFirst(liked, page)
+	ret := make([]domain.TweetWithUsername, len(liked))
+	for i := range liked {
+		ret[i] = liked[i].TweetWithUsername
+	}
+	err = w.hydrate(ctx, ret)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+	return newLikedPage(ret, liked, page), nil
+}
+
+// GetMentions returns a list of tweets mentioning the current user.
+func (w Woofer) GetMentions(ctx context.Context, q PageQuery) (domain.TweetPage, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return domain.TweetPage{}, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	page, err := q.page()
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+
+	ret, err := w.mentions.GetMentionsPage(ctx, userID, page)
+	if err != nil {
+		return domain.TweetPage{}, errors.Wrap(err, "couldn't retrieve tweets")
+	}
+	err = w.hydrate(ctx, ret)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+	return newTweetPage(ret, page), nil
+}
+
+// GetTaggedTweets returns a list of tweets having the hashtag.
+func (w Woofer) GetTaggedTweets(ctx context.Context, tag string, q PageQuery) (domain.TweetPage, error) {
+	page, err := q.page()
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+	tag = normalizeTag(tag)
+	if tag == "" {
+		return domain.TweetPage{}, bizerr.New("hashtag cannot be empty", bizerr.ErrorUserInput)
+	}
+
+	viewer, _ := auth.UserID(ctx)
+	ret, err := w.hashtags.GetTagPage(ctx, viewer, tag, page)
+	if err != nil {
+		return domain.TweetPage{}, errors.Wrap(err, "couldn't retrieve tweets")
+	}
+	err = w.hydrate(ctx, ret)
+	if err != nil {
+		return domain.TweetPage{}, err
+	}
+	return newTweetPage(ret, page), nil
+}
+
+// IndexHashtags indexes hashtags of all existing tweets, returning
+// the number of tweets processed.
+// It's meant for tweets posted before hashtags were indexed.
+func (w Woofer) IndexHashtags(ctx context.Context) (uint, error) {
+	var count uint
+	page := storage.Page{Len: maxPageLen}
+	for {
+		tweets, err := w.tweetStorage.GetPage(ctx, page)
+		if err != nil {
+			return count, errors.Wrap(err, "couldn't retrieve tweets")
+		}
+		for _, t := range tweets {
+			err = w.tag(ctx, t.ID, t.Text)
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		if uint(len(tweets)) < page.Len {
+			return count, nil
+		}
+		page.Before = tweets[len(tweets)-1].ID
+	}
+}
+
+// Subscribe subscribes current user to another one.
+// Subscribing to a protected user creates a follow request instead,
+// pending is true then.
+func (w Woofer) Subscribe(ctx context.Context, targetNickname string) (pending bool, err error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return false, errors.Wrap(err, "couldn't get UserID for request")
+	}
 	tgt, err := w.userStorage.GetByNickname(ctx, targetNickname)
+	if err != nil {
+		return false, err
+	}
+	blocked, err := w.blocks.IsBlocked(ctx, userID, tgt.ID)
+	if err != nil {
+		return false, errors.Wrap(err, "couldn't check blocks")
+	}
+	if blocked {
+		return false, bizerr.New("can't subscribe to this user", bizerr.ErrorUnauthorized)
+	}
+	if tgt.Protected && tgt.ID != userID {
+		subbed, err := w.subStorage.IsSubscribed(ctx, userID, tgt.ID)
+		if err != nil {
+			return false, errors.Wrap(err, "couldn't check subscription")
+		}
+		if subbed {
+			return false, nil
+		}
+		err = w.follows.RequestFollow(ctx, userID, tgt.ID, time.Now())
+		if err != nil {
+			return true, errors.Wrap(err, "couldn't request to follow")
+		}
+		w.publish(ctx, events.FollowRequested, userID, tgt.ID, 0)
+		return true, nil
+	}
+	err = w.subStorage.Subscribe(ctx, userID, tgt.ID)
+	if err != nil {
+		return false, err
+	}
+	err = w.backfill(ctx, userID, tgt.ID)
+	if err != nil {
+		return false, err
+	}
+	w.publish(ctx, events.Subscribed, userID, tgt.ID, 0)
+	return false, nil
+}
+
+// backfill fills the subscriber's inbox with recent tweets, otherwise
+// they'll appear in the timeline only after the next tweet.
+func (w Woofer) backfill(ctx context.Context, from, to domain.UserID) error {
+	if w.fanoutLimit == 0 {
+		return nil
+	}
+	err := w.inbox.Backfill(ctx, from, to, defaultPageLen)
+	return errors.Wrap(err, "couldn't fill the inbox")
+}
+
+// FollowRequests returns users waiting for the current user to approve
+// their subscriptions, oldest requests first.
+func (w Woofer) FollowRequests(ctx context.Context) ([]domain.User, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get UserID for request")
+	}
+
+	ids, err := w.follows.FollowRequests(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't retrieve follow requests")
+	}
+	users, err := w.userStorage.GetByIds(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	// GetByIds doesn't keep the order
+	byID := make(map[domain.UserID]domain.User, len(users))
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+	ret := make([]domain.User, 0, len(users))
+	for _, id := range ids {
+		if u, ok := byID[id]; ok {
+			ret = append(ret, u)
+		}
+	}
+	return ret, nil
+}
+
+// ApproveFollow approves a follow request, subscribing its author
+// to the current user.
+func (w Woofer) ApproveFollow(ctx context.Context, requesterNickname string) error {
+	userID, tgt, err := w.relationTarget(ctx, requesterNickname)
+	if err != nil {
+		return err
+	}
+	err = w.follows.ApproveFollow(ctx, tgt, userID)
+	if err != nil {
+		return errors.Wrap(err, "couldn't approve a follow request")
+	}
+	err = w.backfill(ctx, tgt, userID)
+	if err != nil {
+		return err
+	}
+	w.publish(ctx, events.Subscribed, tgt, userID, 0)
+	return nil
+}
+
+// RejectFollow rejects a follow request.
+func (w Woofer) RejectFollow(ctx context.Context, requesterNickname string) error {
+	userID, tgt, err := w.relationTarget(ctx, requesterNickname)
 	if err != nil {
 		return err
 	}
-	return w.subStorage.Subscribe(ctx, userID, tgt.ID)
+	err = w.follows.RejectFollow(ctx, tgt, userID)
+	return errors.Wrap(err, "couldn't reject a follow request")
 }
 
 // Unsubscribe unsubscribes current user from some other user.
@@ -95,6 +786,92 @@ func (w Woofer) Unsubscribe(ctx context.Context, targetNickname string) error {
 	return w.subStorage.Unsubscribe(ctx, userID, tgt.ID)
 }
 
+// Block blocks another user for the current one. Blocked users can't
+// subscribe to each other and don't see each other's tweets.
+func (w Woofer) Block(ctx context.Context, targetNickname string) error {
+	userID, tgt, err := w.relationTarget(ctx, targetNickname)
+	if err != nil {
+		return err
+	}
+	err = w.blocks.Block(ctx, userID, tgt)
+	return errors.Wrap(err, "couldn't block a user")
+}
+
+// Unblock unblocks a user blocked by the current one.
+func (w Woofer) Unblock(ctx context.Context, targetNickname string) error {
+	userID, tgt, err := w.relationTarget(ctx, targetNickname)
+	if err != nil {
+		return err
+	}
+	err = w.blocks.Unblock(ctx, userID, tgt)
+	return errors.Wrap(err, "couldn't unblock a user")
+}
+
+// Mute hides another user's tweets from the current user's home timeline.
+func (w Woofer) Mute(ctx context.Context, targetNickname string) error {
+	userID, tgt, err := w.relationTarget(ctx, targetNickname)
+	if err != nil {
+		return err
+	}
+	err = w.mutes.Mute(ctx, userID, tgt)
+	return errors.Wrap(err, "couldn't mute a user")
+}
+
+// Unmute unmutes a user muted by the current one.
+func (w Woofer) Unmute(ctx context.Context, targetNickname string) error {
+	userID, tgt, err := w.relationTarget(ctx, targetNickname)
+	if err != nil {
+		return err
+	}
+	err = w.mutes.Unmute(ctx, userID, tgt)
+	return errors.Wrap(err, "couldn't unmute a user")
+}
+
+// relationTarget returns IDs of the current user and another user
+// it wants to block, mute or approve.
+func (w Woofer) relationTarget(ctx context.Context, targetNickname string) (domain.UserID, domain.UserID, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	tgt, err := w.userStorage.GetByNickname(ctx, targetNickname)
+	if err != nil {
+		return 0, 0, err
+	}
+	if tgt.ID == userID {
+		return 0, 0, bizerr.New("can't do this to yourself", bizerr.ErrorUserInput)
+	}
+	return userID, tgt.ID, nil
+}
+
+// BlockedUsers returns all users blocked by the current user.
+func (w Woofer) BlockedUsers(ctx context.Context) ([]domain.User, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get UserID for request")
+	}
+
+	ids, err := w.blocks.Blocked(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't retrieve blocked users")
+	}
+	return w.userStorage.GetByIds(ctx, ids)
+}
+
+// MutedUsers returns all users muted by the current user.
+func (w Woofer) MutedUsers(ctx context.Context) ([]domain.User, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get UserID for request")
+	}
+
+	ids, err := w.mutes.Muted(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't retrieve muted users")
+	}
+	return w.userStorage.GetByIds(ctx, ids)
+}
+
 // Subscriptions returns all user IDs to which a current user is subscribed to.
 func (w Woofer) Subscriptions(ctx context.Context) ([]domain.User, error) {
 	userID, err := auth.UserID(ctx)
@@ -0,0 +1,234 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/auth"
+	"github.com/utrack/woofer/lib/bizerr"
+	"github.com/utrack/woofer/service/internal/events"
+)
+
+func newTestService(t *testing.T) *Woofer {
+	w, err := Bootstrap(Config{Storage: StorageInmem})
+	if err != nil {
+		t.Fatalf("Bootstrap: %v", err)
+	}
+	return w
+}
+
+// newTestUser creates a user and returns a context it's logged in with.
+func newTestUser(t *testing.T, w *Woofer, nickname string, protected bool) context.Context {
+	id, err := w.UserCreate(context.Background(), domain.UserWithPassword{
+		User:     domain.User{Nickname: nickname, Protected: protected},
+		Password: "password",
+	})
+	if err != nil {
+		t.Fatalf("UserCreate: %v", err)
+	}
+	return auth.SetUserID(context.Background(), id)
+}
+
+func TestBlockedProfileIsEmpty(t *testing.T) {
+	w := newTestService(t)
+	alice := newTestUser(t, w, "alice", false)
+	bob := newTestUser(t, w, "bob", false)
+
+	_, err := w.Tweet(alice, "hello", 0)
+	if err != nil {
+		t.Fatalf("Tweet: %v", err)
+	}
+	err = w.Block(alice, "bob")
+	if err != nil {
+		t.Fatalf("Block: %v", err)
+	}
+
+	page, err := w.GetTweetsForProfile(bob, "alice", PageQuery{})
+	if err != nil {
+		t.Fatalf("GetTweetsForProfile: %v", err)
+	}
+	// empty pages are rendered the same way everywhere
+	got, _ := json.Marshal(page.Tweets)
+	if string(got) != "[]" {
+		t.Errorf("blocked profile's tweets are rendered as %s, want []", got)
+	}
+}
+
+func TestProtectedTweetsAreHidden(t *testing.T) {
+	w := newTestService(t)
+	alice := newTestUser(t, w, "alice", true)
+	bob := newTestUser(t, w, "bob", false)
+	carol := newTestUser(t, w, "carol", false)
+
+	_, err := w.Subscribe(bob, "alice")
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	err = w.ApproveFollow(alice, "bob")
+	if err != nil {
+		t.Fatalf("ApproveFollow: %v", err)
+	}
+	id, err := w.Tweet(alice, "secret #woof for @bob and @carol", 0)
+	if err != nil {
+		t.Fatalf("Tweet: %v", err)
+	}
+
+	expectDenied := func(what string, err error) {
+		t.Helper()
+		if bizerr.Type(err) != bizerr.ErrorUnauthorized {
+			t.Errorf("%v: got error %v, want ErrorUnauthorized", what, err)
+		}
+	}
+	_, err = w.GetThread(carol, id, PageQuery{})
+	expectDenied("GetThread", err)
+	_, err = w.TweetHistory(carol, id)
+	expectDenied("TweetHistory", err)
+	expectDenied("Like", w.Like(carol, id))
+	_, err = w.Tweet(carol, "reply", id)
+	expectDenied("reply", err)
+	_, err = w.Retweet(carol, id, "")
+	expectDenied("Retweet", err)
+	// even subscribers can't reshare protected tweets to their followers
+	_, err = w.Retweet(bob, id, "look")
+	expectDenied("Retweet by subscriber", err)
+
+	for name, ctx := range map[string]context.Context{"stranger": carol, "anonymous": context.Background()} {
+		page, err := w.GetTaggedTweets(ctx, "woof", PageQuery{})
+		if err != nil {
+			t.Fatalf("GetTaggedTweets: %v", err)
+		}
+		if len(page.Tweets) != 0 {
+			t.Errorf("%v: GetTaggedTweets returned %+v", name, page.Tweets)
+		}
+		page, err = w.SearchTweets(ctx, "secret", PageQuery{})
+		if err != nil {
+			t.Fatalf("SearchTweets: %v", err)
+		}
+		if len(page.Tweets) != 0 {
+			t.Errorf("%v: SearchTweets returned %+v", name, page.Tweets)
+		}
+	}
+
+	// subscribers see everything
+	_, err = w.GetThread(bob, id, PageQuery{})
+	if err != nil {
+		t.Errorf("GetThread by subscriber: %v", err)
+	}
+	err = w.Like(bob, id)
+	if err != nil {
+		t.Errorf("Like by subscriber: %v", err)
+	}
+	_, err = w.Tweet(bob, "reply", id)
+	if err != nil {
+		t.Errorf("reply by subscriber: %v", err)
+	}
+
+	// only mentioned users who can see the tweet learn about it
+	mentions, err := w.GetMentions(carol, PageQuery{})
+	if err != nil {
+		t.Fatalf("GetMentions: %v", err)
+	}
+	if len(mentions.Tweets) != 0 {
+		t.Errorf("stranger's mentions are %+v", mentions.Tweets)
+	}
+	notes, err := w.GetNotifications(carol, PageQuery{})
+	if err != nil {
+		t.Fatalf("GetNotifications: %v", err)
+	}
+	if len(notes.Notifications) != 0 {
+		t.Errorf("stranger's notifications are %+v", notes.Notifications)
+	}
+	mentions, err = w.GetMentions(bob, PageQuery{})
+	if err != nil {
+		t.Fatalf("GetMentions: %v", err)
+	}
+	if len(mentions.Tweets) != 1 {
+		t.Errorf("subscriber's mentions are %+v", mentions.Tweets)
+	}
+
+	// likes of public users don't reveal protected tweets
+	for name, ctx := range map[string]context.Context{"stranger": carol, "anonymous": context.Background()} {
+		page, err := w.GetLikedTweets(ctx, "bob", PageQuery{})
+		if err != nil {
+			t.Fatalf("GetLikedTweets: %v", err)
+		}
+		if len(page.Tweets) != 0 {
+			t.Errorf("%v: GetLikedTweets returned %+v", name, page.Tweets)
+		}
+	}
+	page, err := w.SearchTweets(bob, "secret", PageQuery{})
+	if err != nil {
+		t.Fatalf("SearchTweets: %v", err)
+	}
+	if len(page.Tweets) != 1 {
+		t.Errorf("SearchTweets by subscriber returned %+v", page.Tweets)
+	}
+}
+
+func TestFailingEventHandlerDoesntFailWrites(t *testing.T) {
+	w := newTestService(t)
+	alice := newTestUser(t, w, "alice", false)
+	bob := newTestUser(t, w, "bob", false)
+	var handled []events.Kind
+	w.events.Subscribe(func(ctx context.Context, e events.Event) error {
+		handled = append(handled, e.Kind)
+		return errors.New("handler failed")
+	})
+
+	id, err := w.Tweet(alice, "hello", 0)
+	if err != nil {
+		t.Fatalf("Tweet: %v", err)
+	}
+	_, err = w.Tweet(bob, "hi @alice", id)
+	if err != nil {
+		t.Fatalf("reply: %v", err)
+	}
+	err = w.Like(bob, id)
+	if err != nil {
+		t.Fatalf("Like: %v", err)
+	}
+	if len(handled) != 5 {
+		t.Errorf("handler got events %v, want tweet, mention, tweet, reply and like", handled)
+	}
+}
+
+func TestRetweetsHideOriginalsTurnedProtected(t *testing.T) {
+	w := newTestService(t)
+	alice := newTestUser(t, w, "alice", false)
+	bob := newTestUser(t, w, "bob", false)
+	carol := newTestUser(t, w, "carol", false)
+
+	id, err := w.Tweet(alice, "hello", 0)
+	if err != nil {
+		t.Fatalf("Tweet: %v", err)
+	}
+	_, err = w.Retweet(bob, id, "look")
+	if err != nil {
+		t.Fatalf("Retweet: %v", err)
+	}
+	err = w.UserModify(alice, domain.User{Protected: true})
+	if err != nil {
+		t.Fatalf("UserModify: %v", err)
+	}
+
+	original := func(ctx context.Context) *domain.TweetWithUsername {
+		t.Helper()
+		page, err := w.GetTweetsForProfile(ctx, "bob", PageQuery{})
+		if err != nil {
+			t.Fatalf("GetTweetsForProfile: %v", err)
+		}
+		if len(page.Tweets) != 1 {
+			t.Fatalf("bob's tweets are %+v, want a single retweet", page.Tweets)
+		}
+		return page.Tweets[0].Original
+	}
+	if got := original(carol); got != nil {
+		t.Errorf("stranger sees the protected original %+v", got)
+	}
+	if got := original(alice); got == nil || got.ID != id {
+		t.Errorf("author sees the original %+v, want tweet %v", got, id)
+	}
+}
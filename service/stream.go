@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/utrack/woofer/domain"
+	"github.com/utrack/woofer/lib/auth"
+	"github.com/utrack/woofer/service/internal/events"
+	"github.com/utrack/woofer/service/internal/storage"
+)
+
+// maxStreamBacklog is a max number of missed tweets, and separately
+// notifications, replayed when a stream is resumed.
+const maxStreamBacklog = maxPageLen
+
+// streamCursor is a position in a stream: IDs of the latest tweet
+// and notification sent to it.
+type streamCursor struct {
+	tweet uint64
+	note  uint64
+}
+
+func (c streamCursor) String() string {
+	return encodeCursor(c.tweet) + "." + encodeCursor(c.note)
+}
+
+func parseStreamCursor(s string) (streamCursor, error) {
+	var ret streamCursor
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return ret, ErrBadCursor
+	}
+	var err error
+	ret.tweet, err = decodeCursor(parts[0])
+	if err != nil {
+		return ret, err
+	}
+	ret.note, err = decodeCursor(parts[1])
+	return ret, err
+}
+
+// Stream returns a live feed of new tweets of the current user's home
+// timeline and its new notifications.
+//
+// Every event has an ID to resume the stream from with lastEventID;
+// up to maxStreamBacklog latest missed tweets and notifications are sent
+// first then. Stream starts with new events if lastEventID is empty.
+//
+// The channel is closed when ctx is done or the reader lags behind
+// too much, so the stream should be resumed.
+func (w Woofer) Stream(ctx context.Context, lastEventID string) (<-chan domain.StreamEvent, error) {
+	userID, err := auth.UserID(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't get UserID for request")
+	}
+	var cur streamCursor
+	if lastEventID != "" {
+		cur, err = parseStreamCursor(lastEventID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// subscribe before reading the backlog, so nothing is missed
+	// in between; duplicates are skipped by their IDs
+	sub := w.hub.Subscribe(userID)
+	var backlog []domain.StreamEvent
+	if lastEventID != "" {
+		backlog, err = w.streamBacklog(ctx, userID, &cur)
+	} else {
+		cur, err = w.streamHead(ctx, userID)
+	}
+	if err != nil {
+		sub.Close()
+		return nil, err
+	}
+
+	ret := make(chan domain.StreamEvent)
+	go func() {
+		defer close(ret)
+		defer sub.Close()
+
+		for _, e := range backlog {
+			select {
+			case ret <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+		for {
+			var e domain.StreamEvent
+			var ok bool
+			select {
+			case e, ok = <-sub.C():
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			switch {
+			case e.Tweet != nil && e.Tweet.ID > cur.tweet:
+				cur.tweet = e.Tweet.ID
+			case e.Notification != nil && e.Notification.ID > cur.note:
+				cur.note = e.Notification.ID
+			default:
+				continue
+			}
+			e.ID = cur.String()
+			select {
+			case ret <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ret, nil
+}
+
+// streamHead returns a cursor pointing to the latest user's tweet
+// and notification.
+func (w Woofer) streamHead(ctx context.Context, userID domain.UserID) (streamCursor, error) {
+	var ret streamCursor
+	tweets, err := w.timeline(ctx, userID, storage.Page{Len: 1})
+	if err != nil {
+		return ret, err
+	}
+	if len(tweets) > 0 {
+		ret.tweet = tweets[0].ID
+	}
+	notes, err := w.notes.GetNotificationsPage(ctx, userID, storage.Page{Len: 1})
+	if err != nil {
+		return ret, errors.Wrap(err, "couldn't retrieve notifications")
+	}
+	if len(notes) > 0 {
+		ret.note = notes[0].ID
+	}
+	return ret, nil
+}
+
+// streamBacklog returns events missed since the cursor, oldest first,
+// moving the cursor to the latest one.
+func (w Woofer) streamBacklog(ctx context.Context, userID domain.UserID, cur *streamCursor) ([]domain.StreamEvent, error) {
+	// Before is set to get the latest events instead of the oldest ones
+	tweets, err := w.timeline(ctx, userID, storage.Page{After: cur.tweet, Before: math.MaxInt64, Len: maxStreamBacklog})
+	if err != nil {
+		return nil, err
+	}
+	notes, err := w.notes.GetNotificationsPage(ctx, userID, storage.Page{After: cur.note, Before: math.MaxInt64, Len: maxStreamBacklog})
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't retrieve notifications")
+	}
+
+	ret := make([]domain.StreamEvent, 0, len(tweets)+len(notes))
+	for i := len(tweets) - 1; i >= 0; i-- {
+		cur.tweet = tweets[i].ID
+		ret = append(ret, domain.StreamEvent{ID: cur.String(), Tweet: &tweets[i]})
+	}
+	for i := len(notes) - 1; i >= 0; i-- {
+		cur.note = notes[i].ID
+		ret = append(ret, domain.StreamEvent{ID: cur.String(), Notification: &notes[i]})
+	}
+	return ret, nil
+}
+
+// push is an event handler pushing new tweets to the streams
+// of their authors' subscribers.
+// Tweets are pushed as seen by anonymous users, so likes of reshared
+// tweets are not marked as the subscriber's ones.
+func (w Woofer) push(ctx context.Context, e events.Event) error {
+	if e.Kind != events.Tweeted {
+		return nil
+	}
+
+	subs, err := w.subStorage.Subbed(ctx, e.From)
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve subscribers list")
+	}
+	var to []domain.UserID
+	for _, id := range subs {
+		if !w.hub.Connected(id) {
+			continue
+		}
+		muted, err := w.mutes.Muted(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "couldn't retrieve muted users")
+		}
+		if !containsUser(muted, e.From) {
+			to = append(to, id)
+		}
+	}
+	if len(to) == 0 {
+		return nil
+	}
+
+	tweets, err := w.tweetStorage.ByIDs(ctx, []uint64{e.TweetID})
+	if err != nil {
+		return errors.Wrap(err, "couldn't retrieve a tweet")
+	}
+	if len(tweets) == 0 {
+		return nil
+	}
+	err = w.hydrate(auth.SetUserID(ctx, 0), tweets)
+	if err != nil {
+		return err
+	}
+	for _, id := range to {
+		w.hub.Publish(id, domain.StreamEvent{Tweet: &tweets[0]})
+	}
+	return nil
+}
+
+func containsUser(ids []domain.UserID, id domain.UserID) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}